@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// logAccess records every API request in the access log once it has been
+// handled. The query string is kept, except for access tokens, but never the
+// body, which may hold passwords.
+func (s *Server) logAccess(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	entry := &models.APIAccessLog{
+		Endpoint:   truncate(c.Request.URL.Path, 255),
+		Method:     c.Request.Method,
+		StatusCode: c.Writer.Status(),
+		IPAddress:  sql.NullString{String: c.ClientIP(), Valid: true},
+		UserAgent:  sql.NullString{String: truncate(c.Request.UserAgent(), 255), Valid: c.Request.UserAgent() != ""},
+		DurationMs: int(time.Since(start).Milliseconds()),
+	}
+	if user := currentUser(c); user != nil {
+		entry.UserID = sql.NullInt64{Int64: int64(user.UserID), Valid: true}
+	}
+	query := c.Request.URL.Query()
+	query.Del(accessTokenParam)
+	if len(query) > 0 {
+		if data, err := json.Marshal(query); err == nil {
+			entry.RequestParams = sql.NullString{String: string(data), Valid: true}
+		}
+	}
+
+	// Write in the background so logging never slows down the response
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.auditService.RecordAccess(ctx, entry)
+	}()
+}
+
+func (s *Server) getAuditLogs(c *gin.Context) {
+	var filter models.AuditFilter
+	var err error
+
+	if value := c.Query("user_id"); value != "" {
+		if filter.UserID, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+	}
+	if value := c.Query("target_id"); value != "" {
+		if filter.TargetID, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
+			return
+		}
+	}
+	if value := c.Query("since"); value != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since, expected RFC 3339"})
+			return
+		}
+	}
+	if value := c.Query("until"); value != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until, expected RFC 3339"})
+			return
+		}
+	}
+	filter.ActionType = c.Query("action")
+	filter.TargetType = c.Query("target_type")
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	logs, err := s.auditService.GetAuditLogs(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if logs == nil {
+		logs = []*models.AuditLog{}
+	}
+	c.JSON(http.StatusOK, gin.H{"audit_logs": logs})
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
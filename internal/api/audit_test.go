@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/internal/services"
+)
+
+// accessRecorder passes access log entries on to the test as they are
+// written.
+type accessRecorder struct {
+	*database.MemoryStore
+	entries chan *models.APIAccessLog
+}
+
+func (r *accessRecorder) CreateAPIAccessLog(ctx context.Context, entry *models.APIAccessLog) error {
+	r.entries <- entry
+	return r.MemoryStore.CreateAPIAccessLog(ctx, entry)
+}
+
+func TestLogAccess(t *testing.T) {
+	ts := newTestServer(t)
+	viewer, token := ts.addUser(t, "viewer", models.RoleViewer)
+	recorder := &accessRecorder{MemoryStore: ts.store, entries: make(chan *models.APIAccessLog, 1)}
+	ts.auditService = services.NewAuditService(recorder)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		userID int64
+		params string
+	}{
+		{"bearer token", "/api/v1/events?channel=1", token, http.StatusOK, int64(viewer.UserID), `{"channel":["1"]}`},
+		{"access_token parameter", "/api/v1/events?access_token=" + token + "&channel=1", "", http.StatusOK, int64(viewer.UserID), `{"channel":["1"]}`},
+		{"only an access_token parameter", "/api/v1/events?access_token=" + token, "", http.StatusOK, int64(viewer.UserID), ""},
+		{"anonymous", "/api/v1/events", "", http.StatusUnauthorized, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.get(t, tt.path, tt.token)
+
+			var entry *models.APIAccessLog
+			select {
+			case entry = <-recorder.entries:
+			case <-time.After(5 * time.Second):
+				t.Fatal("request was not logged")
+			}
+
+			if entry.Endpoint != "/api/v1/events" || entry.Method != http.MethodGet || entry.StatusCode != tt.status {
+				t.Errorf("logged %s %s %d, want GET /api/v1/events %d", entry.Method, entry.Endpoint, entry.StatusCode, tt.status)
+			}
+			if entry.UserID.Int64 != tt.userID {
+				t.Errorf("logged user %d, want %d", entry.UserID.Int64, tt.userID)
+			}
+			if strings.Contains(entry.RequestParams.String, token) || entry.RequestParams.String != tt.params {
+				t.Errorf("logged parameters %q, want %q", entry.RequestParams.String, tt.params)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const userContextKey = "user"
+
+// accessTokenParam carries a session token in the query string, for clients
+// such as browser EventSource that cannot set headers.
+const accessTokenParam = "access_token"
+
+// authenticate identifies the caller from an X-API-Key header, an
+// "Authorization: Bearer <token>" session token or an access_token query
+// parameter.
+func (s *Server) authenticate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var user *models.User
+	var err error
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		user, err = s.authService.AuthenticateAPIKey(ctx, key)
+	} else if token := bearerToken(c); token != "" {
+		user, err = s.authService.AuthenticateToken(ctx, token)
+	} else if token := c.Query(accessTokenParam); token != "" {
+		user, err = s.authService.AuthenticateToken(ctx, token)
+	} else {
+		err = services.ErrUnauthorized
+	}
+
+	if err != nil {
+		if errors.Is(err, services.ErrUnauthorized) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(userContextKey, user)
+	c.Next()
+}
+
+// requireRole returns a handler that rejects callers below the given role.
+func (s *Server) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentUser(c)
+		if user == nil || !models.RoleAllows(user.Role, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func currentUser(c *gin.Context) *models.User {
+	if value, ok := c.Get(userContextKey); ok {
+		if user, ok := value.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+func (s *Server) login(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, expiresAt, err := s.authService.Login(s.actorContext(c), req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrUnauthorized) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expiresAt,
+		"user":       user,
+	})
+}
+
+func (s *Server) logout(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not logged in with a session"})
+		return
+	}
+
+	if err := s.authService.Logout(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func (s *Server) me(c *gin.Context) {
+	c.JSON(http.StatusOK, currentUser(c))
+}
+
+// userRequest is the body of user create and update requests.
+type userRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	IsActive *bool  `json:"is_active"`
+}
+
+func (r *userRequest) user() *models.User {
+	return &models.User{
+		Username: r.Username,
+		Email:    sql.NullString{String: r.Email, Valid: r.Email != ""},
+		Role:     r.Role,
+		IsActive: r.IsActive == nil || *r.IsActive,
+	}
+}
+
+func (s *Server) listUsers(c *gin.Context) {
+	users, err := s.authService.ListUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+func (s *Server) getUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	user, err := s.authService.GetUser(c.Request.Context(), id)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) createUser(c *gin.Context) {
+	var req userRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := s.authService.CreateUser(s.actorContext(c), req.user(), req.Password)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+func (s *Server) updateUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req userRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := req.user()
+	user.UserID = id
+
+	updated, err := s.authService.UpdateUser(s.actorContext(c), user, req.Password)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (s *Server) deleteUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := s.authService.DeleteUser(s.actorContext(c), id); err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+}
+
+func (s *Server) regenerateAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	key, err := s.authService.RegenerateAPIKey(s.actorContext(c), id)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_key": key})
+}
+
+func (s *Server) revokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := s.authService.RevokeAPIKey(s.actorContext(c), id); err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// userError writes the response for an error returned by the auth service.
+func userError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidUser):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
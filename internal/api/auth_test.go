@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ts := newTestServer(t)
+	viewer, _ := ts.addUser(t, "viewer", models.RoleViewer)
+	ts.addSession(t, viewer, "expired-token", time.Now().Add(-time.Minute))
+	inactive, _ := ts.addUser(t, "inactive", models.RoleViewer)
+	inactive.IsActive = false
+	if err := ts.store.UpdateUser(context.Background(), inactive); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"no token", "/api/v1/auth/me", "", http.StatusUnauthorized},
+		{"wrong token", "/api/v1/auth/me", "wrong-token", http.StatusUnauthorized},
+		{"expired token", "/api/v1/auth/me", "expired-token", http.StatusUnauthorized},
+		{"inactive user", "/api/v1/auth/me", "inactive-token", http.StatusUnauthorized},
+		{"bearer token", "/api/v1/auth/me", "viewer-token", http.StatusOK},
+		{"access_token parameter", "/api/v1/auth/me?access_token=viewer-token", "", http.StatusOK},
+		{"wrong access_token parameter", "/api/v1/auth/me?access_token=wrong-token", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ts.get(t, tt.path, tt.token); w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	ts := newTestServer(t)
+	for _, role := range []string{models.RoleViewer, models.RoleOperator, models.RoleAdmin} {
+		ts.addUser(t, role, role)
+	}
+
+	tests := []struct {
+		name      string
+		role      string
+		method    string
+		path      string
+		forbidden bool
+	}{
+		{"viewer on viewer route", models.RoleViewer, http.MethodGet, "/api/v1/channels", false},
+		{"viewer on operator route", models.RoleViewer, http.MethodPost, "/api/v1/channels/1/stop", true},
+		{"operator on operator route", models.RoleOperator, http.MethodPost, "/api/v1/channels/1/stop", false},
+		{"operator on admin route", models.RoleOperator, http.MethodGet, "/api/v1/users", true},
+		{"admin on admin route", models.RoleAdmin, http.MethodGet, "/api/v1/users", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(t, tt.method, tt.path, tt.role+"-token", "")
+			if forbidden := w.Code == http.StatusForbidden; forbidden != tt.forbidden {
+				t.Errorf("status = %d, want forbidden %v: %s", w.Code, tt.forbidden, w.Body)
+			}
+		})
+	}
+}
+
+func TestLastAdminIsKept(t *testing.T) {
+	ts := newTestServer(t)
+	admin, token := ts.addUser(t, "admin", models.RoleAdmin)
+	path := fmt.Sprintf("/api/v1/users/%d", admin.UserID)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{"demote", http.MethodPut, `{"username": "admin", "role": "operator"}`},
+		{"deactivate", http.MethodPut, `{"username": "admin", "role": "admin", "is_active": false}`},
+		{"delete", http.MethodDelete, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.do(t, tt.method, path, token, tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "last active admin") {
+				t.Errorf("status = %d, want 400 for the last admin: %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	// With another active admin the first one can go
+	ts.addUser(t, "second", models.RoleAdmin)
+	if w := ts.do(t, http.MethodDelete, path, token, ""); w.Code != http.StatusOK {
+		t.Errorf("deleting an admin with another left: status = %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getEvents(c *gin.Context) {
+	var filter models.EventFilter
+	var err error
+
+	if value := c.Query("channel"); value != "" {
+		if filter.ChannelID, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel"})
+			return
+		}
+	}
+	if value := c.Query("since"); value != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since, expected RFC 3339"})
+			return
+		}
+	}
+	filter.EventName = c.Query("type")
+	filter.EventType = c.Query("severity")
+	filter.Category = c.Query("category")
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	events, err := s.events.Query(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if events == nil {
+		events = []*models.EventLog{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// streamEvents pushes events to the client as Server-Sent Events as they
+// happen. The channel and type query parameters take comma separated lists
+// to receive only some channels or event types.
+func (s *Server) streamEvents(c *gin.Context) {
+	channels := make(map[int]bool)
+	for _, value := range splitQuery(c.Query("channel")) {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel"})
+			return
+		}
+		channels[id] = true
+	}
+	types := make(map[string]bool)
+	for _, value := range splitQuery(c.Query("type")) {
+		types[value] = true
+	}
+
+	stream, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// Send the headers now so the client sees the stream open before the
+	// first event, which may be a while
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			// A comment line keeps proxies from closing an idle connection
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		case event := <-stream:
+			if len(channels) > 0 && !channels[event.ChannelID] {
+				return true
+			}
+			if len(types) > 0 && !types[event.Type] {
+				return true
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		}
+	})
+}
+
+func splitQuery(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
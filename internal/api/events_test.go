@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func TestStreamEvents(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser(t, "viewer", models.RoleViewer)
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/events/stream?channel=1,3&type=item_started", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// The headers arrive before any event
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream did not open: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	ts.bus.Notify(events.Event{ChannelID: 2, Type: events.ItemStarted, Message: "other channel"})
+	ts.bus.Notify(events.Event{ChannelID: 1, Type: events.FFmpegExited, Message: "other type"})
+	ts.bus.Notify(events.Event{ChannelID: 3, Type: events.ItemStarted, Message: "wanted"})
+
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for data == "" && scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "event:"); ok {
+			event = value
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = value
+		}
+	}
+	if event != events.ItemStarted || !strings.Contains(data, `"message":"wanted"`) {
+		t.Errorf("first event = %s %s, want the wanted item_started", event, data)
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser(t, "viewer", models.RoleViewer)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for _, entry := range []*models.EventLog{
+		{ChannelID: sql.NullInt64{Int64: 1, Valid: true}, EventName: events.ItemStarted, CreatedAt: now.Add(-2 * time.Hour)},
+		{ChannelID: sql.NullInt64{Int64: 1, Valid: true}, EventName: events.FFmpegExited, CreatedAt: now.Add(-time.Hour)},
+		{ChannelID: sql.NullInt64{Int64: 2, Valid: true}, EventName: events.ItemStarted, CreatedAt: now},
+	} {
+		if err := ts.store.CreateEventLog(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   int
+	}{
+		{"everything", "", http.StatusOK, 3},
+		{"channel", "?channel=1", http.StatusOK, 2},
+		{"type", "?type=item_started", http.StatusOK, 2},
+		{"since", "?since=" + now.Add(-90*time.Minute).Format(time.RFC3339), http.StatusOK, 2},
+		{"invalid channel", "?channel=one", http.StatusBadRequest, 0},
+		{"invalid since", "?since=yesterday", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ts.get(t, "/api/v1/events"+tt.query, token)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var body struct {
+				Events []*models.EventLog `json:"events"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Events) != tt.want {
+				t.Errorf("returned %d events, want %d", len(body.Events), tt.want)
+			}
+		})
+	}
+}
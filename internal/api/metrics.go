@@ -0,0 +1,19 @@
+package api
+
+import (
+	"log"
+
+	"github.com/euacreations/tvheadend/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// metrics serves all metrics in the Prometheus text exposition format.
+func (s *Server) metrics(c *gin.Context) {
+	if err := s.channelService.CollectMetrics(c.Request.Context()); err != nil {
+		// Still serve the counters and histograms
+		log.Printf("Failed to collect channel metrics: %v", err)
+	}
+
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteText(c.Writer)
+}
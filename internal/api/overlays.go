@@ -0,0 +1,263 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAssetSize limits the size of uploaded overlay images and fonts.
+const maxAssetSize = 32 << 20
+
+func (s *Server) listOverlays(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	overlays, err := s.overlayService.ListOverlays(c.Request.Context(), channelID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if overlays == nil {
+		overlays = []*models.Overlay{}
+	}
+	c.JSON(http.StatusOK, gin.H{"overlays": overlays})
+}
+
+func (s *Server) createChannelOverlay(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	var overlay models.Overlay
+	if err := c.ShouldBindJSON(&overlay); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	overlay.ChannelID = channelID
+
+	created, err := s.overlayService.CreateOverlay(s.actorContext(c), &overlay)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+func (s *Server) getOverlay(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid overlay ID"})
+		return
+	}
+
+	overlay, err := s.overlayService.GetOverlay(c.Request.Context(), id)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, overlay)
+}
+
+func (s *Server) deleteOverlay(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid overlay ID"})
+		return
+	}
+
+	if err := s.overlayService.DeleteOverlay(s.actorContext(c), id); err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "overlay deleted"})
+}
+
+func (s *Server) reorderOverlays(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	var req struct {
+		OverlayIDs []int `json:"overlay_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	overlays, err := s.overlayService.ReorderOverlays(s.actorContext(c), channelID, req.OverlayIDs)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"overlays": overlays})
+}
+
+func (s *Server) uploadOverlayAsset(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAssetSize)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	path, err := s.overlayService.SaveAsset(s.actorContext(c), channelID, header.Filename, file)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"file_path": path})
+}
+
+func (s *Server) previewOverlays(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	var req struct {
+		MediaID    int     `json:"media_id" binding:"required"`
+		Position   float64 `json:"position"`
+		OverlayIDs []int   `json:"overlay_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	position := time.Duration(req.Position * float64(time.Second))
+	frame, err := s.overlayService.Preview(c.Request.Context(), channelID, req.MediaID, position, req.OverlayIDs)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", frame)
+}
+
+func (s *Server) getTitleStyles(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	styles, err := s.overlayService.GetTitleStyles(c.Request.Context(), channelID)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	if styles == nil {
+		styles = []*models.TitleStyle{}
+	}
+	c.JSON(http.StatusOK, gin.H{"title_styles": styles})
+}
+
+func (s *Server) setTitleStyles(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	var req struct {
+		TitleStyles []struct {
+			LanguageID *int   `json:"language_id"`
+			Enabled    *bool  `json:"enabled"`
+			FontFile   string `json:"font_file"`
+			FontSize   string `json:"font_size"`
+			FontColor  string `json:"font_color"`
+			PositionX  string `json:"position_x"`
+			PositionY  string `json:"position_y"`
+		} `json:"title_styles"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	styles := make([]*models.TitleStyle, 0, len(req.TitleStyles))
+	for _, r := range req.TitleStyles {
+		style := &models.TitleStyle{
+			Enabled:   r.Enabled == nil || *r.Enabled,
+			FontFile:  r.FontFile,
+			FontSize:  r.FontSize,
+			FontColor: r.FontColor,
+			PositionX: r.PositionX,
+			PositionY: r.PositionY,
+		}
+		if r.LanguageID != nil {
+			style.LanguageID = sql.NullInt64{Int64: int64(*r.LanguageID), Valid: true}
+		}
+		styles = append(styles, style)
+	}
+
+	updated, err := s.overlayService.SetTitleStyles(s.actorContext(c), channelID, styles)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"title_styles": updated})
+}
+
+func (s *Server) listLanguages(c *gin.Context) {
+	languages, err := s.overlayService.ListLanguages(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"languages": languages})
+}
+
+// overlayError writes the response for an error returned by the overlay service.
+func overlayError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidOverlay), errors.Is(err, services.ErrNotTicker):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
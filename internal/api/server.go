@@ -1,37 +1,57 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log"
 	"math"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/euacreations/tvheadend/internal/events"
 	"github.com/euacreations/tvheadend/internal/models"
 	"github.com/euacreations/tvheadend/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	router           *gin.Engine
-	channelService   *services.ChannelService
-	mediaScanner     *services.MediaScanner
-	PlaylistExecutor *services.PlaylistExecutor
-	overlayService   *services.OverlayService
+	router          *gin.Engine
+	httpServer      *http.Server
+	channelService  *services.ChannelService
+	mediaScanner    *services.MediaScanner
+	playlistService *services.PlaylistService
+	overlayService  *services.OverlayService
+	authService     *services.AuthService
+	auditService    *services.AuditService
+	settingsService *services.SettingsService
+	events          *events.Bus
 }
 
 func NewServer(
 	channelService *services.ChannelService,
 	mediaScanner *services.MediaScanner,
-	PlaylistExecutor *services.PlaylistExecutor,
+	playlistService *services.PlaylistService,
 	overlayService *services.OverlayService,
+	authService *services.AuthService,
+	auditService *services.AuditService,
+	settingsService *services.SettingsService,
+	bus *events.Bus,
 ) *Server {
 	router := gin.Default()
 	s := &Server{
-		router:           router,
-		channelService:   channelService,
-		mediaScanner:     mediaScanner,
-		PlaylistExecutor: PlaylistExecutor,
-		overlayService:   overlayService,
+		router:          router,
+		channelService:  channelService,
+		mediaScanner:    mediaScanner,
+		playlistService: playlistService,
+		overlayService:  overlayService,
+		authService:     authService,
+		auditService:    auditService,
+		settingsService: settingsService,
+		events:          bus,
 	}
 
 	s.setupRoutes()
@@ -39,18 +59,66 @@ func NewServer(
 }
 
 func (s *Server) setupRoutes() {
+	// Scraped by Prometheus, which sits on the internal network
+	s.router.GET("/metrics", s.metrics)
+
 	api := s.router.Group("/api/v1")
+	api.Use(s.logAccess)
+	api.POST("/auth/login", s.login)
+
+	// Viewers can read everything, operators can also run channels and
+	// update on-air content, admins can also change configuration and users
+	viewer := s.requireRole(models.RoleViewer)
+	operator := s.requireRole(models.RoleOperator)
+	admin := s.requireRole(models.RoleAdmin)
+
+	api.Use(s.authenticate)
 	{
-		api.GET("/channels", s.listChannels)
-		api.GET("/channels/:id", s.getChannel)
-		api.GET("/channels/:id/start", s.startChannel)
-		api.POST("/channels/:id/stop", s.stopChannel)
-		api.GET("/channels/:id/status", s.channelStatus)
-		api.POST("/channels/:id/scan", s.scanMedia)
-		api.GET("/channels/:id/playlists", s.getPlaylists)
-		api.GET("/channels/:id/playlists/:playlistId", s.getPlaylist)
-		api.GET("/channels/:id/media", s.getMediaFiles)
-		api.POST("/overlays", s.createOverlay)
+		api.POST("/auth/logout", s.logout)
+		api.GET("/auth/me", s.me)
+
+		api.GET("/channels", viewer, s.listChannels)
+		api.GET("/channels/:id", viewer, s.getChannel)
+		api.GET("/channels/:id/start", operator, s.startChannel)
+		api.POST("/channels/:id/stop", operator, s.stopChannel)
+		api.POST("/channels/:id/control", operator, s.controlChannel)
+		api.GET("/channels/:id/status", viewer, s.channelStatus)
+		api.GET("/capacity", viewer, s.getCapacity)
+		api.GET("/channels/:id/logs", viewer, s.channelLogs)
+		api.GET("/channels/:id/as-run", viewer, s.asRunLog)
+		api.POST("/channels/:id/scan", operator, s.scanMedia)
+		api.GET("/channels/:id/playlists", viewer, s.getPlaylists)
+		api.GET("/channels/:id/playlists/:playlistId", viewer, s.getPlaylist)
+		api.GET("/channels/:id/media", viewer, s.getMediaFiles)
+		api.GET("/channels/:id/overlays", viewer, s.listOverlays)
+		api.POST("/channels/:id/overlays", admin, s.createChannelOverlay)
+		api.PUT("/channels/:id/overlays/order", admin, s.reorderOverlays)
+		api.POST("/channels/:id/overlays/assets", admin, s.uploadOverlayAsset)
+		api.POST("/channels/:id/overlays/preview", operator, s.previewOverlays)
+		api.GET("/channels/:id/title-styles", viewer, s.getTitleStyles)
+		api.PUT("/channels/:id/title-styles", admin, s.setTitleStyles)
+		api.GET("/languages", viewer, s.listLanguages)
+		api.POST("/overlays", admin, s.createOverlay)
+		api.GET("/overlays/:id", viewer, s.getOverlay)
+		api.PUT("/overlays/:id", admin, s.updateOverlay)
+		api.DELETE("/overlays/:id", admin, s.deleteOverlay)
+		api.GET("/overlays/:id/headlines", viewer, s.getHeadlines)
+		api.PUT("/overlays/:id/headlines", operator, s.setHeadlines)
+
+		api.GET("/users", admin, s.listUsers)
+		api.POST("/users", admin, s.createUser)
+		api.GET("/users/:id", admin, s.getUser)
+		api.PUT("/users/:id", admin, s.updateUser)
+		api.DELETE("/users/:id", admin, s.deleteUser)
+		api.POST("/users/:id/api-key", admin, s.regenerateAPIKey)
+		api.DELETE("/users/:id/api-key", admin, s.revokeAPIKey)
+
+		api.GET("/settings", admin, s.getSettings)
+		api.PUT("/settings", admin, s.updateSettings)
+
+		api.GET("/audit", admin, s.getAuditLogs)
+		api.GET("/events", viewer, s.getEvents)
+		api.GET("/events/stream", viewer, s.streamEvents)
 	}
 }
 
@@ -107,7 +175,11 @@ func (s *Server) startChannel(c *gin.Context) {
 		return
 	}
 
-	if err := s.channelService.StartChannel(c.Request.Context(), id); err != nil {
+	if err := s.channelService.StartChannel(s.actorContext(c), id); err != nil {
+		if errors.Is(err, services.ErrChannelQueued) {
+			c.JSON(http.StatusAccepted, gin.H{"message": err.Error(), "capacity": s.channelService.GetCapacity()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -115,6 +187,10 @@ func (s *Server) startChannel(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "channel started"})
 }
 
+func (s *Server) getCapacity(c *gin.Context) {
+	c.JSON(http.StatusOK, s.channelService.GetCapacity())
+}
+
 func (s *Server) stopChannel(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -122,7 +198,7 @@ func (s *Server) stopChannel(c *gin.Context) {
 		return
 	}
 
-	if err := s.channelService.StopChannel(c.Request.Context(), id); err != nil {
+	if err := s.channelService.StopChannel(s.actorContext(c), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -130,6 +206,40 @@ func (s *Server) stopChannel(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "channel stopped"})
 }
 
+// controlChannel takes an operator's transport control for a channel on
+// air: skip, restart, seek, jump or the emergency slate.
+func (s *Server) controlChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	var req services.ControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.channelService.Control(s.actorContext(c), id, req); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCommand):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrChannelNotRunning), errors.Is(err, services.ErrPlayoutStopped):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, context.DeadlineExceeded):
+			// The executor did not take the command in time, e.g. while
+			// FFmpeg was slow to start
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "control applied", "action": req.Action})
+}
+
 func (s *Server) channelStatus(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -170,7 +280,7 @@ func (s *Server) channelStatus(c *gin.Context) {
 		statusResponse["current_playlist_id"] = state.CurrentPlaylistID
 
 		// Try to get playlist name if available
-		playlist, _ := s.PlaylistExecutor.GetPlaylist(c.Request.Context(), state.CurrentPlaylistID)
+		playlist, _ := s.playlistService.GetPlaylist(c.Request.Context(), state.CurrentPlaylistID)
 		if playlist != nil {
 			statusResponse["playlist_name"] = playlist.PlaylistName
 		}
@@ -182,9 +292,75 @@ func (s *Server) channelStatus(c *gin.Context) {
 		// Could add logic here to get the current item's details if needed
 	}
 
+	if progress, running := s.channelService.GetChannelProgress(id); running {
+		statusResponse["progress"] = progress
+	}
+	if report, monitored := s.channelService.GetOutputReport(id); monitored {
+		statusResponse["output"] = report
+	}
+
 	c.JSON(http.StatusOK, statusResponse)
 }
 
+func (s *Server) channelLogs(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	if _, err := s.channelService.GetChannel(c.Request.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, s.channelService.GetChannelLogs(id, limit))
+}
+
+// asRunLog lists what went to air on a channel, newest first.
+func (s *Server) asRunLog(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid channel ID"})
+		return
+	}
+
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since, expected RFC 3339"})
+			return
+		}
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	entries, err := s.channelService.GetAsRunLog(c.Request.Context(), id, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []*models.AsRunEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"as_run": entries})
+}
+
 // func (s *Server) channelStatus(c *gin.Context) {
 // 	id, err := strconv.Atoi(c.Param("id"))
 // 	if err != nil {
@@ -202,8 +378,38 @@ func (s *Server) channelStatus(c *gin.Context) {
 // 	c.JSON(http.StatusOK, gin.H{"running": running})
 // }
 
+// Start listens on addr and serves the API in the background.
 func (s *Server) Start(addr string) error {
-	return s.router.Run(addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	// Long-lived requests such as event streams end when shutdown begins,
+	// rather than holding it up until they time out
+	baseCtx, cancel := context.WithCancel(context.Background())
+	s.httpServer = &http.Server{
+		Handler:           s.router,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+	s.httpServer.RegisterOnShutdown(cancel)
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server stopped: %v", err)
+		}
+	}()
+	log.Printf("Listening on %s", listener.Addr())
+	return nil
+}
+
+// Shutdown stops accepting requests and waits for those in progress.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Shutdown(ctx)
 }
 
 func (s *Server) createOverlay(c *gin.Context) {
@@ -213,15 +419,88 @@ func (s *Server) createOverlay(c *gin.Context) {
 		return
 	}
 
-	created, err := s.overlayService.CreateOverlay(c.Request.Context(), &overlay)
+	created, err := s.overlayService.CreateOverlay(s.actorContext(c), &overlay)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		overlayError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, created)
 }
 
+func (s *Server) updateOverlay(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid overlay ID"})
+		return
+	}
+
+	var overlay models.Overlay
+	if err := c.ShouldBindJSON(&overlay); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	overlay.OverlayID = id
+
+	updated, err := s.overlayService.UpdateOverlay(s.actorContext(c), &overlay)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (s *Server) getHeadlines(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid overlay ID"})
+		return
+	}
+
+	headlines, err := s.overlayService.GetHeadlines(c.Request.Context(), id)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"headlines": headlines})
+}
+
+func (s *Server) setHeadlines(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid overlay ID"})
+		return
+	}
+
+	var req struct {
+		Headlines []string `json:"headlines"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	headlines, err := s.overlayService.SetHeadlines(s.actorContext(c), id, req.Headlines)
+	if err != nil {
+		overlayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"headlines": headlines})
+}
+
+// actorContext returns the request context annotated with who is making the
+// request, for the audit trail.
+func (s *Server) actorContext(c *gin.Context) context.Context {
+	actor := services.Actor{IPAddress: c.ClientIP()}
+	if user := currentUser(c); user != nil {
+		actor.UserID = user.UserID
+	}
+	return services.WithActor(c.Request.Context(), actor)
+}
+
 func (s *Server) getPlaylist1(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -229,13 +508,13 @@ func (s *Server) getPlaylist1(c *gin.Context) {
 		return
 	}
 
-	playlist, err := s.PlaylistExecutor.GetPlaylist(c.Request.Context(), id)
+	playlist, err := s.playlistService.GetPlaylist(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	items, err := s.PlaylistExecutor.GetPlaylistItems(c.Request.Context(), id)
+	items, err := s.playlistService.GetPlaylistItems(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -273,7 +552,7 @@ func (s *Server) getPlaylists(c *gin.Context) {
 	}
 
 	// Fetch the playlists
-	playlists, err := s.PlaylistExecutor.GetPlaylists(c.Request.Context(), id)
+	playlists, err := s.playlistService.GetPlaylists(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -324,7 +603,7 @@ func (s *Server) getPlaylist(c *gin.Context) {
 	}
 
 	// Fetch the playlist
-	playlist, err := s.PlaylistExecutor.GetPlaylist(c.Request.Context(), playlistID)
+	playlist, err := s.playlistService.GetPlaylist(c.Request.Context(), playlistID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -350,7 +629,7 @@ func (s *Server) getPlaylist(c *gin.Context) {
 	}
 
 	// Fetch playlist items
-	items, err := s.PlaylistExecutor.GetPlaylistItems(c.Request.Context(), playlistID)
+	items, err := s.playlistService.GetPlaylistItems(c.Request.Context(), playlistID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -402,7 +681,7 @@ func (s *Server) getMediaFiles(c *gin.Context) {
 
 	// Fetch the Media Files
 
-	mediafiles, err := s.PlaylistExecutor.GetMediaFiles(c.Request.Context(), id)
+	mediafiles, err := s.playlistService.GetMediaFiles(c.Request.Context(), id)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -461,7 +740,7 @@ func (s *Server) getMediaFiles(c *gin.Context) {
 	}
 
 	// Get paginated media files
-	mediafiles, err := s.PlaylistExecutor.GetMediaFiles(c.Request.Context(), id, page, pageSize)
+	mediafiles, err := s.playlistService.GetMediaFiles(c.Request.Context(), id, page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -471,7 +750,7 @@ func (s *Server) getMediaFiles(c *gin.Context) {
 	}
 
 	// Get total count for pagination metadata
-	total, err := s.PlaylistExecutor.CountMediaFiles(c.Request.Context(), id)
+	total, err := s.playlistService.CountMediaFiles(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// testServer is the API on an in-memory store.
+type testServer struct {
+	*Server
+	store *database.MemoryStore
+	bus   *events.Bus
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := database.NewMemoryStore()
+	settings := config.DefaultSettings()
+	bus := events.NewBus(store, settings)
+	server := NewServer(
+		services.NewChannelService(store, settings, bus),
+		services.NewMediaScanner(store, bus),
+		services.NewPlaylistService(store),
+		services.NewOverlayService(store),
+		services.NewAuthService(store, time.Hour),
+		services.NewAuditService(store),
+		services.NewSettingsService(store, settings),
+		bus,
+	)
+	return &testServer{Server: server, store: store, bus: bus}
+}
+
+// addUser creates an active user with the role and a session, without the
+// password hashing of a real login, and returns the session token.
+func (ts *testServer) addUser(t *testing.T, username, role string) (*models.User, string) {
+	t.Helper()
+
+	user := &models.User{Username: username, Role: role, IsActive: true}
+	if err := ts.store.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	token := username + "-token"
+	ts.addSession(t, user, token, time.Now().Add(time.Hour))
+	return user, token
+}
+
+// addSession stores a session for the token the way Login does.
+func (ts *testServer) addSession(t *testing.T, user *models.User, token string, expiresAt time.Time) {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(token))
+	session := &models.UserSession{UserID: user.UserID, TokenHash: hex.EncodeToString(sum[:]), ExpiresAt: expiresAt}
+	if err := ts.store.CreateUserSession(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// do sends a request with a JSON body and a bearer token, leaving out
+// whichever is empty.
+func (ts *testServer) do(t *testing.T, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *testServer) get(t *testing.T, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	return ts.do(t, http.MethodGet, path, token, "")
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"settings": s.settingsService.ListSettings()})
+}
+
+// updateSettings changes runtime settings, given as an object of keys and
+// values. Values may be JSON strings, numbers or booleans.
+func (s *Server) updateSettings(c *gin.Context) {
+	var req struct {
+		Settings map[string]json.RawMessage `json:"settings"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Settings) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no settings given"})
+		return
+	}
+
+	changes := make(map[string]string, len(req.Settings))
+	for key, raw := range req.Settings {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		changes[key] = value
+	}
+
+	updated, err := s.settingsService.UpdateSettings(s.actorContext(c), changes)
+	if err != nil {
+		if errors.Is(err, config.ErrInvalidSetting) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": updated})
+}
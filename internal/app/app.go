@@ -2,16 +2,26 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/euacreations/tvheadend/internal/api"
 	"github.com/euacreations/tvheadend/internal/config"
 	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/events"
 	"github.com/euacreations/tvheadend/internal/services"
-	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+)
+
+// Shutdown budgets. Channels get their own, so that a slow HTTP drain does
+// not leave them too little time to stop FFmpeg and write their final state.
+const (
+	httpDrainTimeout   = 10 * time.Second
+	channelStopTimeout = 15 * time.Second
 )
 
 type Application struct {
@@ -20,22 +30,46 @@ type Application struct {
 	server         *api.Server
 	channelService *services.ChannelService
 	mediaScanner   *services.MediaScanner
-	playlistExec   *services.PlaylistExecutor
+	tickerService  *services.TickerService
+	authService    *services.AuthService
+	events         *events.Bus
+
+	// Background services run until Stop cancels them
+	cancel     context.CancelFunc
+	background sync.WaitGroup
 }
 
 func NewApplication(cfg *config.Config) (*Application, error) {
+	// Everything that does not belong to a channel with its own timezone runs
+	// in station time
+	time.Local = cfg.Location
+
 	repo, err := database.NewRepository(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	ffmpeg := ffmpeg.New()
-	channelService := services.NewChannelService(repo)
-	mediaScanner := services.NewMediaScanner(repo)
-	playlistExec := services.NewPlaylistExecutor(repo, ffmpeg)
+	// system_settings overrides the file and environment
+	cfg.Settings.Load(context.Background(), repo)
+
+	bus := events.NewBus(repo, cfg.Settings)
+	channelService := services.NewChannelService(repo, cfg.Settings, bus)
+	cfg.Settings.OnChange(channelService.AdmitQueued)
+	mediaScanner := services.NewMediaScanner(repo, bus)
+	playlistService := services.NewPlaylistService(repo)
 	overlayService := services.NewOverlayService(repo)
+	overlayService.OnChange(channelService.ReloadOverlays)
+	tickerService := services.NewTickerService(repo, overlayService)
+	authService := services.NewAuthService(repo, time.Duration(cfg.SessionTTLHours)*time.Hour)
+
+	if err := authService.EnsureAdmin(context.Background(), cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		return nil, err
+	}
 
-	server := api.NewServer(channelService, mediaScanner, playlistExec, overlayService)
+	auditService := services.NewAuditService(repo)
+	settingsService := services.NewSettingsService(repo, cfg.Settings)
+
+	server := api.NewServer(channelService, mediaScanner, playlistService, overlayService, authService, auditService, settingsService, bus)
 
 	return &Application{
 		cfg:            cfg,
@@ -43,60 +77,136 @@ func NewApplication(cfg *config.Config) (*Application, error) {
 		server:         server,
 		channelService: channelService,
 		mediaScanner:   mediaScanner,
-		playlistExec:   playlistExec,
+		tickerService:  tickerService,
+		authService:    authService,
+		events:         bus,
 	}, nil
 }
 
+// Start starts the background services, the enabled channels and the HTTP
+// server, and returns once the server is listening.
 func (a *Application) Start() error {
 	// Start background services
-	go a.startBackgroundServices()
-
-	// Start HTTP server
+	background, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.runBackground(func() { a.events.Run(background) })
+	a.runBackground(func() { a.startBackgroundServices(background) })
+	a.runBackground(func() { a.tickerService.Run(background) })
+	a.runBackground(func() { a.authService.PurgeExpiredSessions(background) })
+
+	// Channels the previous run did not stop are not running anymore
+	ctx := context.Background()
+	if err := a.channelService.ReconcileStates(ctx); err != nil {
+		return err
+	}
 
 	// Start all enabled channels
-	ctx := context.Background()
 	channels, err := a.channelService.GetAllChannels(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve channels: %w", err)
 	}
 
-	for _, ch := range channels {
-		if ch.Enabled {
-			go func(channelID int) {
-				if err := a.channelService.StartChannel(ctx, channelID); err != nil {
-					log.Printf("Failed to start channel %d: %v", channelID, err)
-				} else {
-					log.Printf("Channel %d started", channelID)
-				}
-			}(ch.ChannelID)
+	// Start the most important channels first, so that they get the encoder
+	// slots when there are not enough for all; the rest wait in the queue
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].Priority > channels[j].Priority
+	})
+	go func() {
+		for _, ch := range channels {
+			if !ch.Enabled {
+				continue
+			}
+			err := a.channelService.StartChannel(ctx, ch.ChannelID)
+			if err != nil && !errors.Is(err, services.ErrChannelQueued) {
+				a.events.Publish(events.Event{
+					ChannelID: ch.ChannelID,
+					Type:      events.ChannelFailed,
+					Severity:  events.SeverityError,
+					Category:  events.CategoryChannel,
+					Message:   fmt.Sprintf("Failed to start channel: %v", err),
+				})
+			}
 		}
-	}
+	}()
 
 	return a.server.Start(":" + strconv.Itoa(a.cfg.HTTPPort))
+}
 
+func (a *Application) runBackground(f func()) {
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		f()
+	}()
 }
 
-func (a *Application) startBackgroundServices() {
+func (a *Application) startBackgroundServices(ctx context.Context) {
 	// Scan media files periodically
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		channels, err := a.repo.GetAllChannels(context.Background())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		channels, err := a.repo.GetAllChannels(ctx)
 		if err != nil {
-			log.Printf("Failed to get channels for scanning: %v", err)
+			a.events.Publish(events.Event{
+				Type:     events.ScanFailed,
+				Severity: events.SeverityError,
+				Category: events.CategorySystem,
+				Message:  fmt.Sprintf("Failed to get channels for scanning: %v", err),
+			})
 			continue
 		}
 
+		// The scanner records the outcome of each scan as an event
 		for _, channel := range channels {
-			if err := a.mediaScanner.ScanChannelMedia(context.Background(), channel.ChannelID); err != nil {
-				log.Printf("Failed to scan media for channel %d: %v", channel.ChannelID, err)
-			}
+			_ = a.mediaScanner.ScanChannelMedia(ctx, channel.ChannelID)
 		}
 	}
 }
 
+// Stop shuts down in order: the HTTP server stops taking requests, the
+// channels go off air with their final state written, and the background
+// services finish writing queued events before the database is closed.
 func (a *Application) Stop(ctx context.Context) error {
+	var errs []error
+
 	log.Println("Shutting down server...")
-	return a.repo.Close()
+	httpCtx, cancelHTTP := context.WithTimeout(ctx, httpDrainTimeout)
+	defer cancelHTTP()
+	if err := a.server.Shutdown(httpCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to shut down HTTP server: %w", err))
+	}
+
+	log.Println("Stopping channels...")
+	channelCtx, cancelChannels := context.WithTimeout(context.WithoutCancel(ctx), channelStopTimeout)
+	defer cancelChannels()
+	if err := a.channelService.Shutdown(channelCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop channels: %w", err))
+	}
+
+	if a.cancel != nil {
+		a.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		a.mediaScanner.Stop()
+		a.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, errors.New("background services did not stop in time"))
+	}
+
+	if err := a.repo.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
@@ -1,34 +1,155 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// defaultConfigFile is read when CONFIG_FILE is not set, if it exists.
+const defaultConfigFile = "config.yaml"
+
 type Config struct {
-	DBHost     string
-	DBPort     int
-	DBUser     string
-	DBPassword string
-	DBName     string
-	HTTPPort   int
-	DebugMode  bool
+	DBHost     string `yaml:"db_host"`
+	DBPort     int    `yaml:"db_port"`
+	DBUser     string `yaml:"db_user"`
+	DBPassword string `yaml:"db_password"`
+	DBName     string `yaml:"db_name"`
+	HTTPPort   int    `yaml:"http_port"`
+	DebugMode  bool   `yaml:"debug_mode"`
+
+	// Admin account created on first start when there are no users
+	AdminUsername string `yaml:"admin_username"`
+	AdminPassword string `yaml:"admin_password"`
+
+	SessionTTLHours int `yaml:"session_ttl_hours"`
+
+	// Station timezone: schedules, playlist dates and database times are in
+	// this zone unless a channel sets its own
+	Timezone string         `yaml:"timezone"`
+	Location *time.Location `yaml:"-"`
+
+	// Runtime settings, which system_settings can override while running
+	Settings *Settings `yaml:"-"`
+
+	// Environment variables that did not parse, reported by validate
+	envErrors []error
+}
+
+// file is the layout of the configuration file: the fields of Config and a
+// settings section with runtime settings.
+type file struct {
+	Config   `yaml:",inline"`
+	Settings map[string]string `yaml:"settings"`
 }
 
-func LoadConfig() *Config {
+// LoadConfig builds the configuration from defaults, then the YAML file
+// named by CONFIG_FILE, then environment variables, each overriding the one
+// before, and validates it.
+func LoadConfig() (*Config, error) {
 	_ = godotenv.Load()
 
-	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnvAsInt("DB_PORT", 3306),
-		DBUser:     getEnv("DB_USER", "headend_user"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBName:     getEnv("DB_NAME", "tv_headend_server"),
-		HTTPPort:   getEnvAsInt("HTTP_PORT", 8080),
-		DebugMode:  getEnvAsBool("DEBUG_MODE", false),
+	f := file{Config: Config{
+		DBHost:          "localhost",
+		DBPort:          3306,
+		DBUser:          "headend_user",
+		DBName:          "tv_headend_server",
+		HTTPPort:        8080,
+		AdminUsername:   "admin",
+		SessionTTLHours: 12,
+		Timezone:        "Local",
+	}}
+	if err := readFile(&f); err != nil {
+		return nil, err
+	}
+
+	cfg := &f.Config
+	cfg.DBHost = getEnv("DB_HOST", cfg.DBHost)
+	cfg.DBPort = cfg.getEnvAsInt("DB_PORT", cfg.DBPort)
+	cfg.DBUser = getEnv("DB_USER", cfg.DBUser)
+	cfg.DBPassword = getEnv("DB_PASSWORD", cfg.DBPassword)
+	cfg.DBName = getEnv("DB_NAME", cfg.DBName)
+	cfg.HTTPPort = cfg.getEnvAsInt("HTTP_PORT", cfg.HTTPPort)
+	cfg.DebugMode = cfg.getEnvAsBool("DEBUG_MODE", cfg.DebugMode)
+	cfg.AdminUsername = getEnv("ADMIN_USERNAME", cfg.AdminUsername)
+	cfg.AdminPassword = getEnv("ADMIN_PASSWORD", cfg.AdminPassword)
+	cfg.SessionTTLHours = cfg.getEnvAsInt("SESSION_TTL_HOURS", cfg.SessionTTLHours)
+	cfg.Timezone = getEnv("TZ", cfg.Timezone)
+
+	var errs []error
+	if err := cfg.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	settings, err := newSettings(f.Settings, os.LookupEnv)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	cfg.Settings = settings
+
+	return cfg, nil
+}
+
+// readFile reads the configuration file over the defaults in f. The default
+// file is optional; one named by CONFIG_FILE is not.
+func readFile(f *file) error {
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
 	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	log.Printf("Loaded configuration from %s", path)
+	return nil
+}
+
+// validate checks the static configuration and resolves the timezone.
+func (cfg *Config) validate() error {
+	errs := append([]error(nil), cfg.envErrors...)
+	if cfg.DBHost == "" {
+		errs = append(errs, errors.New("db_host is required"))
+	}
+	if cfg.DBPort < 1 || cfg.DBPort > 65535 {
+		errs = append(errs, fmt.Errorf("db_port %d is not a valid port", cfg.DBPort))
+	}
+	if cfg.DBName == "" {
+		errs = append(errs, errors.New("db_name is required"))
+	}
+	if cfg.HTTPPort < 1 || cfg.HTTPPort > 65535 {
+		errs = append(errs, fmt.Errorf("http_port %d is not a valid port", cfg.HTTPPort))
+	}
+	if strings.TrimSpace(cfg.AdminUsername) == "" {
+		errs = append(errs, errors.New("admin_username is required"))
+	}
+	if cfg.SessionTTLHours <= 0 {
+		errs = append(errs, fmt.Errorf("session_ttl_hours must be positive, got %d", cfg.SessionTTLHours))
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unknown timezone %q", cfg.Timezone))
+	}
+	cfg.Location = location
+
+	return errors.Join(errs...)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -38,18 +159,34 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
+// getEnvAsInt returns the integer in an environment variable, or the
+// default when it is unset or empty. A value that is not an integer is
+// recorded for validate and the default kept.
+func (cfg *Config) getEnvAsInt(key string, defaultValue int) int {
 	strValue := getEnv(key, "")
-	if value, err := strconv.Atoi(strValue); err == nil {
-		return value
+	if strValue == "" {
+		return defaultValue
 	}
-	return defaultValue
+	value, err := strconv.Atoi(strings.TrimSpace(strValue))
+	if err != nil {
+		cfg.envErrors = append(cfg.envErrors, fmt.Errorf("%s %q is not an integer", key, strValue))
+		return defaultValue
+	}
+	return value
 }
 
-func getEnvAsBool(key string, defaultValue bool) bool {
+// getEnvAsBool returns the boolean in an environment variable, as accepted
+// by strconv.ParseBool, or the default when it is unset or empty. Any other
+// value is recorded for validate and the default kept.
+func (cfg *Config) getEnvAsBool(key string, defaultValue bool) bool {
 	strValue := getEnv(key, "")
-	if value, err := strconv.ParseBool(strValue); err == nil {
-		return value
+	if strValue == "" {
+		return defaultValue
 	}
-	return defaultValue
+	value, err := strconv.ParseBool(strings.TrimSpace(strValue))
+	if err != nil {
+		cfg.envErrors = append(cfg.envErrors, fmt.Errorf("%s %q is not true or false", key, strValue))
+		return defaultValue
+	}
+	return value
 }
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	writeConfigFile(t, `
+db_host: db.example.com
+http_port: 9000
+timezone: America/New_York
+settings:
+  max_playlist_fallback_days: 10
+  enable_media_cache: true
+`)
+	t.Setenv("HTTP_PORT", "9100")
+	t.Setenv("MAX_PLAYLIST_FALLBACK_DAYS", "20")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.DBHost != "db.example.com" || cfg.DBPort != 3306 || cfg.HTTPPort != 9100 {
+		t.Errorf("db_host %q, db_port %d, http_port %d; want the file's host, the default port and the environment's HTTP port",
+			cfg.DBHost, cfg.DBPort, cfg.HTTPPort)
+	}
+	if cfg.Location.String() != "America/New_York" {
+		t.Errorf("location = %v, want America/New_York", cfg.Location)
+	}
+
+	sources := make(map[string]SettingValue)
+	for _, value := range cfg.Settings.All() {
+		sources[value.Key] = value
+	}
+	tests := []struct {
+		key, value, source string
+	}{
+		{"max_playlist_fallback_days", "20", SourceEnv},
+		{"enable_media_cache", "true", SourceFile},
+		{"stall_timeout_seconds", "30", SourceDefault},
+	}
+	for _, test := range tests {
+		if got := sources[test.key]; got.Value != test.value || got.Source != test.source {
+			t.Errorf("%s = %q from %s, want %q from %s", test.key, got.Value, got.Source, test.value, test.source)
+		}
+	}
+}
+
+func TestLoadConfigValidates(t *testing.T) {
+	writeConfigFile(t, `
+http_port: 70000
+timezone: Nowhere/Else
+settings:
+  min_encode_speed: fast
+  max_playlist_fallback_days: -1
+  no_such_setting: 1
+`)
+
+	_, err := LoadConfig()
+	if err == nil {
+		t.Fatal("LoadConfig accepted an invalid configuration")
+	}
+	for _, want := range []string{"http_port", "Nowhere/Else", "min_encode_speed", "max_playlist_fallback_days", "no_such_setting"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadConfigRejectsInvalidEnvironment(t *testing.T) {
+	writeConfigFile(t, "db_host: db.example.com\n")
+	t.Setenv("HTTP_PORT", "abc")
+	t.Setenv("DEBUG_MODE", "yes")
+	t.Setenv("SESSION_TTL_HOURS", "")
+
+	_, err := LoadConfig()
+	if err == nil {
+		t.Fatal("LoadConfig accepted HTTP_PORT=abc and DEBUG_MODE=yes")
+	}
+	for _, want := range []string{"HTTP_PORT", "DEBUG_MODE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "SESSION_TTL_HOURS") {
+		t.Errorf("error %q rejects an empty SESSION_TTL_HOURS, which leaves the default", err)
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := LoadConfig(); err == nil {
+		t.Error("LoadConfig ignored a missing CONFIG_FILE")
+	}
+}
+
+// settingStore keeps system settings in memory.
+type settingStore map[string]string
+
+func (s settingStore) GetSystemSetting(ctx context.Context, key string) (string, error) {
+	value, ok := s[key]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func (s settingStore) SetSystemSetting(ctx context.Context, key, value string) error {
+	s[key] = value
+	return nil
+}
+
+func TestSettingsLoadAndUpdate(t *testing.T) {
+	ctx := context.Background()
+	store := settingStore{
+		"max_playlist_fallback_days": "14",
+		"health_check_interval":      "often",
+	}
+
+	settings := DefaultSettings()
+	settings.Load(ctx, store)
+	if got := settings.Int("max_playlist_fallback_days"); got != 14 {
+		t.Errorf("max_playlist_fallback_days = %d, want 14 from the database", got)
+	}
+	if got := settings.Float("health_check_interval"); got != 10 {
+		t.Errorf("health_check_interval = %g, want the default 10 in place of an invalid stored value", got)
+	}
+
+	err := settings.Update(ctx, store, map[string]string{
+		"enable_media_cache": "true",
+		"min_encode_speed":   "11",
+	})
+	if !errors.Is(err, ErrInvalidSetting) {
+		t.Fatalf("Update with an out of range value: err = %v, want ErrInvalidSetting", err)
+	}
+	if settings.Bool("enable_media_cache") || store["enable_media_cache"] != "" {
+		t.Error("Update applied a change from a request with an invalid value")
+	}
+
+	if err := settings.Update(ctx, store, map[string]string{"enable_media_cache": "TRUE", "min_encode_speed": "0.5"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !settings.Bool("enable_media_cache") || settings.Float("min_encode_speed") != 0.5 {
+		t.Error("Update did not apply the new values")
+	}
+	if store["enable_media_cache"] != "true" {
+		t.Errorf("stored enable_media_cache = %q, want true", store["enable_media_cache"])
+	}
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSetting is returned for an unknown setting or a value it does
+// not accept.
+var ErrInvalidSetting = errors.New("invalid setting")
+
+// Kinds of setting value.
+const (
+	KindInt   = "int"
+	KindFloat = "float"
+	KindBool  = "bool"
+)
+
+// Where a setting's value came from, lowest precedence first.
+const (
+	SourceDefault  = "default"
+	SourceFile     = "file"
+	SourceEnv      = "env"
+	SourceDatabase = "database"
+)
+
+// Setting describes a runtime setting. Its environment variable is its key
+// in upper case.
+type Setting struct {
+	Key         string
+	Kind        string
+	Default     string
+	Min, Max    float64 // Bounds of numeric settings
+	Description string
+}
+
+// definitions lists every runtime setting. Services read them through
+// Settings each time they need one, so a change applies without a restart.
+var definitions = []Setting{
+	{Key: "health_check_interval", Kind: KindFloat, Default: "10", Min: 1, Max: 3600,
+		Description: "Interval in seconds for health checks"},
+	{Key: "stall_timeout_seconds", Kind: KindFloat, Default: "30", Min: 1, Max: 3600,
+		Description: "Seconds without output progress before an item is restarted"},
+	{Key: "min_encode_speed", Kind: KindFloat, Default: "0.8", Min: 0, Max: 10,
+		Description: "Encoding speed below which an item counts as too slow"},
+	{Key: "slow_speed_timeout_seconds", Kind: KindFloat, Default: "60", Min: 1, Max: 3600,
+		Description: "Seconds below min_encode_speed before an item is restarted"},
+	{Key: "max_stall_restarts", Kind: KindInt, Default: "3", Min: 0, Max: 100,
+		Description: "Restarts of a stalled item before skipping to the next item"},
+	{Key: "log_retention_days", Kind: KindInt, Default: "30", Min: 0, Max: 3650,
+		Description: "Number of days to retain event and API access logs"},
+	{Key: "max_log_entries_per_channel", Kind: KindInt, Default: "1000", Min: 0, Max: 1000000,
+		Description: "Maximum log entries to keep per channel"},
+	{Key: "max_playlist_fallback_days", Kind: KindInt, Default: "7", Min: 0, Max: 365,
+		Description: "Days a channel without a playlist for today looks back for an earlier one"},
+	{Key: "enable_media_cache", Kind: KindBool, Default: "false",
+		Description: "Cache media file details in memory during playout"},
+	{Key: "handover_orphaned_ffmpeg", Kind: KindBool, Default: "true",
+		Description: "Leave FFmpeg processes of a previous run on air until their channel starts again, rather than killing them at startup"},
+	{Key: "max_ffmpeg_instances", Kind: KindInt, Default: "8", Min: 0, Max: 1000,
+		Description: "Maximum number of concurrent FFmpeg processes; 0 for no limit"},
+	{Key: "gpu_memory_limit", Kind: KindInt, Default: "4096", Min: 0, Max: 1048576,
+		Description: "Maximum GPU memory to use in MB; 0 for no limit"},
+	{Key: "gpu_memory_per_channel", Kind: KindInt, Default: "512", Min: 1, Max: 1048576,
+		Description: "GPU memory allocation per channel in MB"},
+}
+
+// SettingStore persists runtime settings, in the system_settings table.
+type SettingStore interface {
+	GetSystemSetting(ctx context.Context, key string) (string, error)
+	SetSystemSetting(ctx context.Context, key, value string) error
+}
+
+// SettingValue is the current value of a setting and where it came from.
+type SettingValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Type        string `json:"type"`
+	Default     string `json:"default"`
+	Source      string `json:"source"`
+	Description string `json:"description"`
+}
+
+// Settings holds the runtime settings. Each starts at its default and is
+// overridden by the configuration file, the environment and finally the
+// system_settings table, in that order.
+type Settings struct {
+	mux      sync.RWMutex
+	values   map[string]string
+	sources  map[string]string
+	onChange []func()
+}
+
+// DefaultSettings returns settings at their defaults.
+func DefaultSettings() *Settings {
+	s := &Settings{
+		values:  make(map[string]string, len(definitions)),
+		sources: make(map[string]string, len(definitions)),
+	}
+	for _, def := range definitions {
+		s.values[def.Key] = def.Default
+		s.sources[def.Key] = SourceDefault
+	}
+	return s
+}
+
+// newSettings applies the configuration file's settings section and then
+// the environment to the defaults.
+func newSettings(fromFile map[string]string, lookupEnv func(string) (string, bool)) (*Settings, error) {
+	s := DefaultSettings()
+
+	var errs []error
+	for key, value := range fromFile {
+		if err := s.apply(key, value, SourceFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, def := range definitions {
+		if value, ok := lookupEnv(strings.ToUpper(def.Key)); ok {
+			if err := s.apply(def.Key, value, SourceEnv); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+// Load applies the overrides stored in system_settings. A stored value the
+// setting does not accept is logged and ignored.
+func (s *Settings) Load(ctx context.Context, store SettingStore) {
+	for _, def := range definitions {
+		value, err := store.GetSystemSetting(ctx, def.Key)
+		if err != nil {
+			continue
+		}
+		if err := s.apply(def.Key, value, SourceDatabase); err != nil {
+			log.Printf("Ignoring system setting: %v", err)
+		}
+	}
+}
+
+// Update validates and stores changed settings and applies them. Nothing is
+// changed unless every value is valid.
+func (s *Settings) Update(ctx context.Context, store SettingStore, changes map[string]string) error {
+	normalized := make(map[string]string, len(changes))
+	var errs []error
+	for key, value := range changes {
+		v, err := normalize(key, value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		normalized[key] = v
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, key := range sortedKeys(normalized) {
+		if err := store.SetSystemSetting(ctx, key, normalized[key]); err != nil {
+			return err
+		}
+		s.set(key, normalized[key], SourceDatabase)
+	}
+
+	s.mux.RLock()
+	callbacks := s.onChange
+	s.mux.RUnlock()
+	for _, callback := range callbacks {
+		callback()
+	}
+	return nil
+}
+
+// OnChange registers a function to call after settings are updated, for
+// services that act on a change rather than reading settings as they go.
+func (s *Settings) OnChange(callback func()) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.onChange = append(s.onChange, callback)
+}
+
+// All returns every setting in key order.
+func (s *Settings) All() []SettingValue {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	values := make([]SettingValue, 0, len(definitions))
+	for _, def := range definitions {
+		values = append(values, SettingValue{
+			Key:         def.Key,
+			Value:       s.values[def.Key],
+			Type:        def.Kind,
+			Default:     def.Default,
+			Source:      s.sources[def.Key],
+			Description: def.Description,
+		})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// Get returns a setting's value.
+func (s *Settings) Get(key string) string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.values[key]
+}
+
+// Int returns an int setting.
+func (s *Settings) Int(key string) int {
+	n, _ := strconv.Atoi(s.Get(key))
+	return n
+}
+
+// Float returns a numeric setting.
+func (s *Settings) Float(key string) float64 {
+	f, _ := strconv.ParseFloat(s.Get(key), 64)
+	return f
+}
+
+// Bool returns a bool setting.
+func (s *Settings) Bool(key string) bool {
+	b, _ := strconv.ParseBool(s.Get(key))
+	return b
+}
+
+// Seconds returns a numeric setting in seconds as a duration.
+func (s *Settings) Seconds(key string) time.Duration {
+	return time.Duration(s.Float(key) * float64(time.Second))
+}
+
+func (s *Settings) apply(key, value, source string) error {
+	v, err := normalize(key, value)
+	if err != nil {
+		return err
+	}
+	s.set(key, v, source)
+	return nil
+}
+
+func (s *Settings) set(key, value, source string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.values[key] = value
+	s.sources[key] = source
+}
+
+// normalize checks a value against its setting's definition and returns it
+// in canonical form.
+func normalize(key, value string) (string, error) {
+	def, ok := definition(key)
+	if !ok {
+		return "", fmt.Errorf("%w: unknown setting %q", ErrInvalidSetting, key)
+	}
+	value = strings.TrimSpace(value)
+
+	switch def.Kind {
+	case KindBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s must be true or false, got %q", ErrInvalidSetting, key, value)
+		}
+		return strconv.FormatBool(b), nil
+
+	case KindInt, KindFloat:
+		number := "a number"
+		if def.Kind == KindInt {
+			number = "a whole number"
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || (def.Kind == KindInt && f != float64(int(f))) {
+			return "", fmt.Errorf("%w: %s must be %s, got %q", ErrInvalidSetting, key, number, value)
+		}
+		if f < def.Min || f > def.Max {
+			return "", fmt.Errorf("%w: %s must be between %g and %g, got %g", ErrInvalidSetting, key, def.Min, def.Max, f)
+		}
+		if def.Kind == KindInt {
+			return strconv.Itoa(int(f)), nil
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	return value, nil
+}
+
+func definition(key string) (Setting, bool) {
+	for _, def := range definitions {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return Setting{}, false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
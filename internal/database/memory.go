@@ -0,0 +1,1102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// MemoryStore keeps everything in memory. It behaves like Repository, down
+// to the errors it wraps, so services can be tested without MySQL.
+type MemoryStore struct {
+	mux sync.Mutex
+
+	lastID map[string]int
+
+	channels    map[int]models.Channel
+	states      map[int]models.ChannelState
+	media       map[int]models.MediaFile
+	udpStreams  map[int]models.UDPStream
+	playlists   map[int]models.Playlist
+	items       map[int]models.PlaylistItem
+	overlays    map[int]models.Overlay
+	headlines   map[int][]models.TickerHeadline
+	languages   map[int]models.Language
+	titleStyles map[int][]models.TitleStyle
+	users       map[int]models.User
+	sessions    []models.UserSession
+	auditLogs   []models.AuditLog
+	accessLogs  []models.APIAccessLog
+	eventLogs   []models.EventLog
+	asRunLog    []models.AsRunEntry
+	settings    map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lastID:      make(map[string]int),
+		channels:    make(map[int]models.Channel),
+		states:      make(map[int]models.ChannelState),
+		media:       make(map[int]models.MediaFile),
+		udpStreams:  make(map[int]models.UDPStream),
+		playlists:   make(map[int]models.Playlist),
+		items:       make(map[int]models.PlaylistItem),
+		overlays:    make(map[int]models.Overlay),
+		headlines:   make(map[int][]models.TickerHeadline),
+		languages:   make(map[int]models.Language),
+		titleStyles: make(map[int][]models.TitleStyle),
+		users:       make(map[int]models.User),
+		settings:    make(map[string]string),
+	}
+}
+
+// nextID returns the next auto-increment value of a table. The caller must
+// hold mux.
+func (m *MemoryStore) nextID(table string) int {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// CreateLanguage adds a language. Languages are only set up with the schema
+// in MySQL, so this is not part of Store.
+func (m *MemoryStore) CreateLanguage(language *models.Language) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	language.LanguageID = m.nextID("languages")
+	language.CreatedAt = time.Now()
+	language.UpdatedAt = language.CreatedAt
+	m.languages[language.LanguageID] = *language
+}
+
+func (m *MemoryStore) UpdateChannel(ctx context.Context, channel *models.Channel) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for id, other := range m.channels {
+		if id != channel.ChannelID && other.ChannelName == channel.ChannelName {
+			return fmt.Errorf("failed to insert/update channel: duplicate channel name %q", channel.ChannelName)
+		}
+	}
+
+	now := time.Now()
+	if existing, ok := m.channels[channel.ChannelID]; ok {
+		channel.CreatedAt = existing.CreatedAt
+	} else {
+		if channel.ChannelID == 0 {
+			channel.ChannelID = m.nextID("channels")
+		} else if channel.ChannelID > m.lastID["channels"] {
+			m.lastID["channels"] = channel.ChannelID
+		}
+		channel.CreatedAt = now
+	}
+	channel.UpdatedAt = now
+
+	stored := *channel
+	stored.State = nil
+	m.channels[channel.ChannelID] = stored
+	return nil
+}
+
+func (m *MemoryStore) GetChannelByID(ctx context.Context, channelID int) (*models.Channel, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	channel, ok := m.channels[channelID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get channel: %w", sql.ErrNoRows)
+	}
+	return parseChannelStartTime(channel)
+}
+
+func (m *MemoryStore) GetAllChannels(ctx context.Context) ([]*models.Channel, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	channels := []*models.Channel{}
+	for _, id := range sortedKeys(m.channels) {
+		channel, err := parseChannelStartTime(m.channels[id])
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+func parseChannelStartTime(channel models.Channel) (*models.Channel, error) {
+	var err error
+	channel.StartTime, err = time.Parse("15:04:05", channel.StartTimeStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse start_time for channel %d: %w", channel.ChannelID, err)
+	}
+	return &channel, nil
+}
+
+func (m *MemoryStore) GetChannelState(ctx context.Context, channelID int) (*models.ChannelState, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	state, ok := m.states[channelID]
+	if !ok {
+		return &models.ChannelState{ChannelID: channelID, Running: false}, nil
+	}
+	return &state, nil
+}
+
+func (m *MemoryStore) UpdateChannelState(ctx context.Context, state *models.ChannelState) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.channels[state.ChannelID]; !ok {
+		return fmt.Errorf("failed to update channel state: no channel %d", state.ChannelID)
+	}
+
+	stored := *state
+	now := time.Now()
+	if existing, ok := m.states[state.ChannelID]; ok {
+		stored.StateID = existing.StateID
+		stored.ErrorMessage = existing.ErrorMessage
+		stored.CreatedAt = existing.CreatedAt
+	} else {
+		stored.StateID = m.nextID("channel_states")
+		stored.CreatedAt = now
+	}
+	stored.UpdatedAt = now
+	m.states[state.ChannelID] = stored
+	return nil
+}
+
+func (m *MemoryStore) MediaFileExists(ctx context.Context, channelID int, filePath string) (bool, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for _, file := range m.media {
+		if file.ChannelID == channelID && file.FilePath == filePath {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) CreateMediaFile(ctx context.Context, file *models.MediaFile) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.channels[file.ChannelID]; !ok {
+		return fmt.Errorf("no channel %d", file.ChannelID)
+	}
+	for _, other := range m.media {
+		if other.ChannelID == file.ChannelID && other.FilePath == file.FilePath {
+			return fmt.Errorf("duplicate media file %q", file.FilePath)
+		}
+	}
+
+	file.MediaID = m.nextID("media_files")
+	file.CreatedAt = time.Now()
+	file.UpdatedAt = file.CreatedAt
+	m.media[file.MediaID] = *file
+	return nil
+}
+
+func (m *MemoryStore) GetMediaFiles(ctx context.Context, channelID int, page, pageSize int) ([]*models.MediaFile, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	files := m.channelMedia(channelID, func(models.MediaFile) bool { return true })
+	offset := (page - 1) * pageSize
+	if offset >= len(files) {
+		return []*models.MediaFile{}, nil
+	}
+	end := offset + pageSize
+	if end > len(files) {
+		end = len(files)
+	}
+	return files[offset:end], nil
+}
+
+func (m *MemoryStore) CountMediaFiles(ctx context.Context, channelID int) (int, error) {
+	return len(m.channelMedia(channelID, func(models.MediaFile) bool { return true })), nil
+}
+
+func (m *MemoryStore) GetMediaFile(ctx context.Context, mediaID sql.NullInt64) (*models.MediaFile, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	file, ok := m.media[int(mediaID.Int64)]
+	if !mediaID.Valid || !ok {
+		return nil, fmt.Errorf("failed to get media file with ID %d: %w", mediaID.Int64, sql.ErrNoRows)
+	}
+	return &file, nil
+}
+
+func (m *MemoryStore) GetUnmeasuredMediaFiles(ctx context.Context, channelID int) ([]*models.MediaFile, error) {
+	return m.channelMedia(channelID, func(file models.MediaFile) bool { return !file.IntegratedLoudness.Valid }), nil
+}
+
+// channelMedia returns the channel's media files that match, by ID.
+func (m *MemoryStore) channelMedia(channelID int, match func(models.MediaFile) bool) []*models.MediaFile {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	files := []*models.MediaFile{}
+	for _, id := range sortedKeys(m.media) {
+		file := m.media[id]
+		if file.ChannelID == channelID && match(file) {
+			files = append(files, &file)
+		}
+	}
+	return files
+}
+
+func (m *MemoryStore) UpdateMediaLoudness(ctx context.Context, mediaID int, integrated, truePeak float64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	file, ok := m.media[mediaID]
+	if !ok {
+		return nil // An UPDATE matching no rows
+	}
+	file.IntegratedLoudness = sql.NullFloat64{Float64: integrated, Valid: true}
+	file.TruePeak = sql.NullFloat64{Float64: truePeak, Valid: true}
+	file.UpdatedAt = time.Now()
+	m.media[mediaID] = file
+	return nil
+}
+
+func (m *MemoryStore) CreateUDPStream(ctx context.Context, stream *models.UDPStream) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stream.StreamID = m.nextID("udp_streams")
+	stream.CreatedAt = time.Now()
+	stream.UpdatedAt = stream.CreatedAt
+	m.udpStreams[stream.StreamID] = *stream
+	return nil
+}
+
+func (m *MemoryStore) GetUDPStream(ctx context.Context, streamID sql.NullInt64) (*models.UDPStream, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stream, ok := m.udpStreams[int(streamID.Int64)]
+	if !streamID.Valid || !ok {
+		return nil, fmt.Errorf("failed to get udp stream with ID %d: %w", streamID.Int64, sql.ErrNoRows)
+	}
+	return &stream, nil
+}
+
+func (m *MemoryStore) CreatePlaylist(ctx context.Context, playlist *models.Playlist) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.channels[playlist.ChannelID]; !ok {
+		return fmt.Errorf("failed to create playlist: no channel %d", playlist.ChannelID)
+	}
+	if playlist.PlaylistDate != nil {
+		for _, other := range m.playlists {
+			if other.ChannelID == playlist.ChannelID && sameDate(other.PlaylistDate, *playlist.PlaylistDate) {
+				return fmt.Errorf("failed to create playlist: channel %d already has a playlist for %s",
+					playlist.ChannelID, playlist.PlaylistDate.Format("2006-01-02"))
+			}
+		}
+	}
+	if playlist.Status == "" {
+		playlist.Status = "scheduled"
+	}
+
+	playlist.PlaylistID = m.nextID("playlists")
+	playlist.CreatedAt = time.Now()
+	playlist.UpdatedAt = playlist.CreatedAt
+	m.playlists[playlist.PlaylistID] = *playlist
+	return nil
+}
+
+func (m *MemoryStore) GetPlaylists(ctx context.Context, channelID int) ([]*models.Playlist, error) {
+	return m.channelPlaylists(channelID, func(models.Playlist) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetPlaylist(ctx context.Context, playlistID int) (*models.Playlist, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	playlist, ok := m.playlists[playlistID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get playlist %d: %w", playlistID, sql.ErrNoRows)
+	}
+	return &playlist, nil
+}
+
+func (m *MemoryStore) GetActivePlaylist(ctx context.Context, channelID int) (*models.Playlist, error) {
+	playlists := m.channelPlaylists(channelID, func(playlist models.Playlist) bool { return playlist.Status == "active" })
+	if len(playlists) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	// The most recently created
+	newest := playlists[0]
+	for _, playlist := range playlists[1:] {
+		if !playlist.CreatedAt.Before(newest.CreatedAt) {
+			newest = playlist
+		}
+	}
+	return newest, nil
+}
+
+func (m *MemoryStore) GetPlaylistForDate(ctx context.Context, channelID int, playlistDate time.Time) (*models.Playlist, error) {
+	playlists := m.channelPlaylists(channelID, func(playlist models.Playlist) bool {
+		return playlist.PlaylistDate == nil || sameDate(playlist.PlaylistDate, playlistDate)
+	})
+
+	// A playlist for the date comes before an undated one
+	for _, playlist := range playlists {
+		if playlist.PlaylistDate != nil {
+			return playlist, nil
+		}
+	}
+	if len(playlists) > 0 {
+		return playlists[0], nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetNextPlaylistForChannel(ctx context.Context, channelID int) (*models.Playlist, error) {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	playlists := m.channelPlaylists(channelID, func(playlist models.Playlist) bool {
+		return playlist.Status == "scheduled" && sameDate(playlist.PlaylistDate, tomorrow)
+	})
+	if len(playlists) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return playlists[0], nil
+}
+
+// channelPlaylists returns the channel's playlists that match, by ID.
+func (m *MemoryStore) channelPlaylists(channelID int, match func(models.Playlist) bool) []*models.Playlist {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	playlists := []*models.Playlist{}
+	for _, id := range sortedKeys(m.playlists) {
+		playlist := m.playlists[id]
+		if playlist.ChannelID == channelID && match(playlist) {
+			playlists = append(playlists, &playlist)
+		}
+	}
+	return playlists
+}
+
+// sameDate reports whether a DATE column holds the day of t.
+func sameDate(date *time.Time, t time.Time) bool {
+	return date != nil && date.Format("2006-01-02") == t.Format("2006-01-02")
+}
+
+func (m *MemoryStore) CreatePlaylistItem(ctx context.Context, item *models.PlaylistItem) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.playlists[item.PlaylistID]; !ok {
+		return fmt.Errorf("failed to create playlist item: no playlist %d", item.PlaylistID)
+	}
+
+	item.ItemID = m.nextID("playlist_items")
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
+	m.items[item.ItemID] = *item
+	return nil
+}
+
+func (m *MemoryStore) GetPlaylistItems(ctx context.Context, playlistID int) ([]*models.PlaylistItem, error) {
+	return m.playlistItems(playlistID, func(models.PlaylistItem) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetCurrentAndNextPlaylistItems(ctx context.Context, playlistID int) (*models.PlaylistItem, *models.PlaylistItem, error) {
+	now := time.Now()
+
+	current := &models.PlaylistItem{}
+	playing := m.playlistItems(playlistID, func(item models.PlaylistItem) bool {
+		return item.Locked &&
+			item.ScheduledStartTime != nil && !item.ScheduledStartTime.After(now) &&
+			item.ScheduledEndTime != nil && item.ScheduledEndTime.After(now)
+	})
+	if len(playing) > 0 {
+		current = playing[0]
+	}
+
+	position := -1
+	if current.ItemID > 0 {
+		position = current.Position
+	}
+
+	next := &models.PlaylistItem{}
+	queued := m.playlistItems(playlistID, func(item models.PlaylistItem) bool {
+		return !item.Locked && item.Position > position
+	})
+	if len(queued) == 0 {
+		// At the end of the playlist, loop to the beginning
+		queued = m.playlistItems(playlistID, func(item models.PlaylistItem) bool { return !item.Locked })
+	}
+	if len(queued) > 0 {
+		next = queued[0]
+	}
+
+	return current, next, nil
+}
+
+// playlistItems returns the playlist's items that match, by position.
+func (m *MemoryStore) playlistItems(playlistID int, match func(models.PlaylistItem) bool) []*models.PlaylistItem {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	items := []*models.PlaylistItem{}
+	for _, id := range sortedKeys(m.items) {
+		item := m.items[id]
+		if item.PlaylistID == playlistID && match(item) {
+			items = append(items, &item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Position < items[j].Position })
+	return items
+}
+
+func (m *MemoryStore) LockPlaylistItem(ctx context.Context, itemID int) error {
+	return m.setItemLocked(itemID, true)
+}
+
+func (m *MemoryStore) UnlockPlaylistItem(ctx context.Context, itemID int) error {
+	return m.setItemLocked(itemID, false)
+}
+
+func (m *MemoryStore) setItemLocked(itemID int, locked bool) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if item, ok := m.items[itemID]; ok {
+		item.Locked = locked
+		m.items[itemID] = item
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetChannelOverlays(ctx context.Context, channelID int) ([]*models.Overlay, error) {
+	return m.findOverlays(func(overlay models.Overlay) bool {
+		return overlay.ChannelID == channelID && overlay.Enabled
+	}), nil
+}
+
+func (m *MemoryStore) GetAllChannelOverlays(ctx context.Context, channelID int) ([]*models.Overlay, error) {
+	return m.findOverlays(func(overlay models.Overlay) bool { return overlay.ChannelID == channelID }), nil
+}
+
+func (m *MemoryStore) GetFeedTickers(ctx context.Context) ([]*models.Overlay, error) {
+	return m.findOverlays(func(overlay models.Overlay) bool {
+		return overlay.Type == "ticker" && overlay.FeedURL != "" && overlay.Enabled
+	}), nil
+}
+
+// findOverlays returns the overlays that match in stacking order.
+func (m *MemoryStore) findOverlays(match func(models.Overlay) bool) []*models.Overlay {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	overlays := []*models.Overlay{}
+	for _, id := range sortedKeys(m.overlays) {
+		overlay := m.overlays[id]
+		if match(overlay) {
+			overlays = append(overlays, &overlay)
+		}
+	}
+	sort.SliceStable(overlays, func(i, j int) bool { return overlays[i].ZIndex < overlays[j].ZIndex })
+	return overlays
+}
+
+func (m *MemoryStore) GetOverlay(ctx context.Context, overlayID int) (*models.Overlay, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	overlay, ok := m.overlays[overlayID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get overlay with ID %d: %w", overlayID, sql.ErrNoRows)
+	}
+	return &overlay, nil
+}
+
+func (m *MemoryStore) CreateOverlay(ctx context.Context, overlay *models.Overlay) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.channels[overlay.ChannelID]; !ok {
+		return fmt.Errorf("no channel %d", overlay.ChannelID)
+	}
+
+	// New overlays go on top of the channel's existing ones
+	zIndex := 0
+	for _, other := range m.overlays {
+		if other.ChannelID == overlay.ChannelID && other.ZIndex > zIndex {
+			zIndex = other.ZIndex
+		}
+	}
+
+	stored := *overlay
+	stored.OverlayID = m.nextID("overlays")
+	stored.ZIndex = zIndex + 1
+	stored.CreatedAt = time.Now()
+	stored.UpdatedAt = stored.CreatedAt
+	stored.TextFile = ""
+	stored.Enable = ""
+	m.overlays[stored.OverlayID] = stored
+
+	*overlay = stored
+	return nil
+}
+
+func (m *MemoryStore) UpdateOverlay(ctx context.Context, overlay *models.Overlay) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	existing, ok := m.overlays[overlay.OverlayID]
+	if !ok {
+		return nil // An UPDATE matching no rows
+	}
+
+	stored := *overlay
+	stored.ChannelID = existing.ChannelID
+	stored.ZIndex = existing.ZIndex
+	stored.CreatedAt = existing.CreatedAt
+	stored.UpdatedAt = time.Now()
+	stored.TextFile = ""
+	stored.Enable = ""
+	m.overlays[overlay.OverlayID] = stored
+	return nil
+}
+
+func (m *MemoryStore) DeleteOverlay(ctx context.Context, overlayID int) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.overlays[overlayID]; !ok {
+		return fmt.Errorf("failed to delete overlay %d: %w", overlayID, sql.ErrNoRows)
+	}
+	delete(m.overlays, overlayID)
+	delete(m.headlines, overlayID)
+	return nil
+}
+
+func (m *MemoryStore) ReorderOverlays(ctx context.Context, channelID int, overlayIDs []int) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for i, id := range overlayIDs {
+		if overlay, ok := m.overlays[id]; ok && overlay.ChannelID == channelID {
+			overlay.ZIndex = i + 1
+			m.overlays[id] = overlay
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetTickerHeadlines(ctx context.Context, overlayID int) ([]*models.TickerHeadline, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	headlines := []*models.TickerHeadline{}
+	for _, headline := range m.headlines[overlayID] {
+		headline := headline
+		headlines = append(headlines, &headline)
+	}
+	return headlines, nil
+}
+
+func (m *MemoryStore) ReplaceTickerHeadlines(ctx context.Context, overlayID int, headlines []string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	now := time.Now()
+	replaced := make([]models.TickerHeadline, 0, len(headlines))
+	for i, text := range headlines {
+		replaced = append(replaced, models.TickerHeadline{
+			HeadlineID: m.nextID("ticker_headlines"),
+			OverlayID:  overlayID,
+			Position:   i + 1,
+			Text:       text,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+	m.headlines[overlayID] = replaced
+	return nil
+}
+
+func (m *MemoryStore) GetLanguage(ctx context.Context, languageID int) (*models.Language, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	language, ok := m.languages[languageID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get language with ID %d: %w", languageID, sql.ErrNoRows)
+	}
+	return &language, nil
+}
+
+func (m *MemoryStore) GetLanguages(ctx context.Context) ([]*models.Language, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	languages := []*models.Language{}
+	for _, id := range sortedKeys(m.languages) {
+		language := m.languages[id]
+		languages = append(languages, &language)
+	}
+	return languages, nil
+}
+
+func (m *MemoryStore) GetTitleStyles(ctx context.Context, channelID int) ([]*models.TitleStyle, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	styles := []*models.TitleStyle{}
+	for _, style := range m.titleStyles[channelID] {
+		style := style
+		styles = append(styles, &style)
+	}
+
+	// The channel default first, then by language
+	sort.SliceStable(styles, func(i, j int) bool {
+		a, b := styles[i].LanguageID, styles[j].LanguageID
+		if a.Valid != b.Valid {
+			return !a.Valid
+		}
+		return a.Int64 < b.Int64
+	})
+	return styles, nil
+}
+
+func (m *MemoryStore) ReplaceTitleStyles(ctx context.Context, channelID int, styles []*models.TitleStyle) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	now := time.Now()
+	replaced := make([]models.TitleStyle, 0, len(styles))
+	for _, style := range styles {
+		stored := *style
+		stored.StyleID = m.nextID("title_styles")
+		stored.ChannelID = channelID
+		stored.CreatedAt = now
+		stored.UpdatedAt = now
+		replaced = append(replaced, stored)
+	}
+	m.titleStyles[channelID] = replaced
+	return nil
+}
+
+func (m *MemoryStore) GetUsers(ctx context.Context) ([]*models.User, error) {
+	return m.findUsers(func(models.User) bool { return true }), nil
+}
+
+func (m *MemoryStore) CountUsers(ctx context.Context) (int, error) {
+	return len(m.findUsers(func(models.User) bool { return true })), nil
+}
+
+func (m *MemoryStore) GetUser(ctx context.Context, userID int) (*models.User, error) {
+	users := m.findUsers(func(user models.User) bool { return user.UserID == userID })
+	if len(users) == 0 {
+		return nil, fmt.Errorf("failed to get user with ID %d: %w", userID, sql.ErrNoRows)
+	}
+	return users[0], nil
+}
+
+func (m *MemoryStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	users := m.findUsers(func(user models.User) bool { return user.Username == username })
+	if len(users) == 0 {
+		return nil, fmt.Errorf("failed to get user %q: %w", username, sql.ErrNoRows)
+	}
+	return users[0], nil
+}
+
+func (m *MemoryStore) GetUserByAPIKey(ctx context.Context, keyHash string) (*models.User, error) {
+	users := m.findUsers(func(user models.User) bool { return user.APIKey.Valid && user.APIKey.String == keyHash })
+	if len(users) == 0 {
+		return nil, fmt.Errorf("failed to get user by API key: %w", sql.ErrNoRows)
+	}
+	return users[0], nil
+}
+
+// findUsers returns the users that match, by ID.
+func (m *MemoryStore) findUsers(match func(models.User) bool) []*models.User {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	users := []*models.User{}
+	for _, id := range sortedKeys(m.users) {
+		user := m.users[id]
+		if match(user) {
+			users = append(users, &user)
+		}
+	}
+	return users
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, user *models.User) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for _, other := range m.users {
+		if other.Username == user.Username {
+			return fmt.Errorf("failed to create user: duplicate username %q", user.Username)
+		}
+	}
+
+	user.UserID = m.nextID("users")
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	m.users[user.UserID] = *user
+	return nil
+}
+
+func (m *MemoryStore) UpdateUser(ctx context.Context, user *models.User) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	existing, ok := m.users[user.UserID]
+	if !ok {
+		return fmt.Errorf("failed to get user with ID %d: %w", user.UserID, sql.ErrNoRows)
+	}
+
+	existing.Username = user.Username
+	existing.PasswordHash = user.PasswordHash
+	existing.Email = user.Email
+	existing.Role = user.Role
+	existing.APIKey = user.APIKey
+	existing.IsActive = user.IsActive
+	existing.UpdatedAt = time.Now()
+	m.users[user.UserID] = existing
+	return nil
+}
+
+func (m *MemoryStore) DeleteUser(ctx context.Context, userID int) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return fmt.Errorf("failed to delete user %d: %w", userID, sql.ErrNoRows)
+	}
+	delete(m.users, userID)
+	m.deleteSessions(func(session models.UserSession) bool { return session.UserID == userID })
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserLastLogin(ctx context.Context, userID int) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if user, ok := m.users[userID]; ok {
+		user.LastLogin = sql.NullTime{Time: time.Now(), Valid: true}
+		m.users[userID] = user
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateUserSession(ctx context.Context, session *models.UserSession) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.users[session.UserID]; !ok {
+		return fmt.Errorf("failed to create session: no user %d", session.UserID)
+	}
+
+	session.SessionID = m.nextID("user_sessions")
+	session.CreatedAt = time.Now()
+	m.sessions = append(m.sessions, *session)
+	return nil
+}
+
+func (m *MemoryStore) GetSessionUser(ctx context.Context, tokenHash string) (*models.User, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	now := time.Now()
+	for _, session := range m.sessions {
+		if session.TokenHash == tokenHash && session.ExpiresAt.After(now) {
+			if user, ok := m.users[session.UserID]; ok {
+				return &user, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("failed to get session: %w", sql.ErrNoRows)
+}
+
+func (m *MemoryStore) DeleteUserSession(ctx context.Context, tokenHash string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.deleteSessions(func(session models.UserSession) bool { return session.TokenHash == tokenHash })
+	return nil
+}
+
+func (m *MemoryStore) DeleteUserSessions(ctx context.Context, userID int) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.deleteSessions(func(session models.UserSession) bool { return session.UserID == userID })
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpiredSessions(ctx context.Context) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	now := time.Now()
+	m.deleteSessions(func(session models.UserSession) bool { return !session.ExpiresAt.After(now) })
+	return nil
+}
+
+// deleteSessions removes the sessions that match. The caller must hold mux.
+func (m *MemoryStore) deleteSessions(match func(models.UserSession) bool) {
+	kept := m.sessions[:0]
+	for _, session := range m.sessions {
+		if !match(session) {
+			kept = append(kept, session)
+		}
+	}
+	m.sessions = kept
+}
+
+func (m *MemoryStore) CreateAuditLog(ctx context.Context, entry *models.AuditLog) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stored := *entry
+	stored.AuditID = m.nextID("audit_logs")
+	stored.ActionTime = time.Now()
+	m.auditLogs = append(m.auditLogs, stored)
+	return nil
+}
+
+func (m *MemoryStore) GetAuditLogs(ctx context.Context, filter models.AuditFilter) ([]*models.AuditLog, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	logs := []*models.AuditLog{}
+	for i := len(m.auditLogs) - 1; i >= 0; i-- {
+		entry := m.auditLogs[i]
+		switch {
+		case filter.UserID != 0 && entry.UserID.Int64 != int64(filter.UserID):
+		case filter.ActionType != "" && entry.ActionType != filter.ActionType:
+		case filter.TargetType != "" && entry.TargetType != filter.TargetType:
+		case filter.TargetID != 0 && entry.TargetID.Int64 != int64(filter.TargetID):
+		case !filter.Since.IsZero() && entry.ActionTime.Before(filter.Since):
+		case !filter.Until.IsZero() && !entry.ActionTime.Before(filter.Until):
+		default:
+			logs = append(logs, &entry)
+		}
+	}
+	return page(logs, filter.Limit, filter.Offset), nil
+}
+
+func (m *MemoryStore) CreateAPIAccessLog(ctx context.Context, entry *models.APIAccessLog) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stored := *entry
+	stored.LogID = m.nextID("api_access_logs")
+	stored.RequestTime = time.Now()
+	m.accessLogs = append(m.accessLogs, stored)
+	return nil
+}
+
+func (m *MemoryStore) DeleteAPIAccessLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	kept := m.accessLogs[:0]
+	for _, entry := range m.accessLogs {
+		if !entry.RequestTime.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	deleted := int64(len(m.accessLogs) - len(kept))
+	m.accessLogs = kept
+	return deleted, nil
+}
+
+func (m *MemoryStore) CreateEventLog(ctx context.Context, event *models.EventLog) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stored := *event
+	stored.EventID = m.nextID("event_logs")
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = time.Now()
+	}
+	m.eventLogs = append(m.eventLogs, stored)
+	return nil
+}
+
+func (m *MemoryStore) GetEventLogs(ctx context.Context, filter models.EventFilter) ([]*models.EventLog, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	logs := []*models.EventLog{}
+	for i := range m.eventLogs {
+		event := m.eventLogs[i]
+		switch {
+		case filter.ChannelID != 0 && event.ChannelID.Int64 != int64(filter.ChannelID):
+		case filter.EventName != "" && event.EventName != filter.EventName:
+		case filter.EventType != "" && event.EventType != filter.EventType:
+		case filter.Category != "" && event.EventCategory != filter.Category:
+		case !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since):
+		default:
+			logs = append(logs, &event)
+		}
+	}
+
+	// Newest first
+	sort.SliceStable(logs, func(i, j int) bool {
+		if !logs[i].CreatedAt.Equal(logs[j].CreatedAt) {
+			return logs[i].CreatedAt.After(logs[j].CreatedAt)
+		}
+		return logs[i].EventID > logs[j].EventID
+	})
+	return page(logs, filter.Limit, 0), nil
+}
+
+func (m *MemoryStore) DeleteEventLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.deleteEventLogs(func(event models.EventLog) bool { return event.CreatedAt.Before(before) }), nil
+}
+
+func (m *MemoryStore) TrimChannelEventLogs(ctx context.Context, channelID int, keep int) (int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var ids []int
+	for _, event := range m.eventLogs {
+		if event.ChannelID.Int64 == int64(channelID) {
+			ids = append(ids, event.EventID)
+		}
+	}
+	if keep < 1 || len(ids) <= keep {
+		return 0, nil
+	}
+	sort.Ints(ids)
+	oldestKept := ids[len(ids)-keep]
+
+	return m.deleteEventLogs(func(event models.EventLog) bool {
+		return event.ChannelID.Int64 == int64(channelID) && event.EventID < oldestKept
+	}), nil
+}
+
+// deleteEventLogs removes the events that match and returns how many it
+// removed. The caller must hold mux.
+func (m *MemoryStore) deleteEventLogs(match func(models.EventLog) bool) int64 {
+	kept := m.eventLogs[:0]
+	for _, event := range m.eventLogs {
+		if !match(event) {
+			kept = append(kept, event)
+		}
+	}
+	deleted := int64(len(m.eventLogs) - len(kept))
+	m.eventLogs = kept
+	return deleted
+}
+
+func (m *MemoryStore) CreateAsRunEntry(ctx context.Context, entry *models.AsRunEntry) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.channels[entry.ChannelID]; !ok {
+		return fmt.Errorf("failed to write as-run entry: no channel %d", entry.ChannelID)
+	}
+	entry.AsRunID = m.nextID("as_run_log")
+	entry.CreatedAt = time.Now()
+	m.asRunLog = append(m.asRunLog, *entry)
+	return nil
+}
+
+func (m *MemoryStore) EndAsRunEntry(ctx context.Context, asRunID int, endedAt time.Time, position float64, reason string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for i := range m.asRunLog {
+		if m.asRunLog[i].AsRunID == asRunID {
+			m.asRunLog[i].EndedAt = sql.NullTime{Time: endedAt, Valid: true}
+			m.asRunLog[i].EndPosition = sql.NullFloat64{Float64: position, Valid: true}
+			m.asRunLog[i].EndReason = sql.NullString{String: reason, Valid: true}
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetAsRunLog(ctx context.Context, channelID int, since time.Time, limit int) ([]*models.AsRunEntry, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	entries := []*models.AsRunEntry{}
+	for i := range m.asRunLog {
+		entry := m.asRunLog[i]
+		if entry.ChannelID == channelID && !entry.StartedAt.Before(since) {
+			entries = append(entries, &entry)
+		}
+	}
+
+	// Newest first
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].StartedAt.Equal(entries[j].StartedAt) {
+			return entries[i].StartedAt.After(entries[j].StartedAt)
+		}
+		return entries[i].AsRunID > entries[j].AsRunID
+	})
+	return page(entries, limit, 0), nil
+}
+
+func (m *MemoryStore) GetSystemSetting(ctx context.Context, key string) (string, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	value, ok := m.settings[key]
+	if !ok {
+		return "", fmt.Errorf("failed to get setting %q: %w", key, sql.ErrNoRows)
+	}
+	return value, nil
+}
+
+func (m *MemoryStore) SetSystemSetting(ctx context.Context, key, value string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.settings[key] = value
+	return nil
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// page applies LIMIT and OFFSET.
+func page[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return rows[:0]
+	}
+	rows = rows[offset:]
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
@@ -0,0 +1,5 @@
+ALTER TABLE overlays
+    DROP COLUMN text,
+    MODIFY position_x INT NOT NULL DEFAULT 0,
+    MODIFY position_y INT NOT NULL DEFAULT 0,
+    MODIFY font_size INT DEFAULT 24;
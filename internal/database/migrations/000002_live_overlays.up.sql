@@ -0,0 +1,7 @@
+-- Overlay positions and font sizes are FFmpeg expressions (e.g. W/12, H/30),
+-- and text overlays need somewhere to keep their text.
+ALTER TABLE overlays
+    MODIFY position_x VARCHAR(100) NOT NULL DEFAULT '0',
+    MODIFY position_y VARCHAR(100) NOT NULL DEFAULT '0',
+    MODIFY font_size VARCHAR(100) DEFAULT '24',
+    ADD COLUMN text VARCHAR(1024) NOT NULL DEFAULT '' AFTER file_path;
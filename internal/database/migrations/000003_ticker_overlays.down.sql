@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS ticker_headlines;
+
+DELETE FROM overlays WHERE type = 'ticker';
+
+ALTER TABLE overlays
+    DROP COLUMN feed_interval,
+    DROP COLUMN feed_url,
+    DROP COLUMN speed,
+    DROP COLUMN bg_color,
+    DROP COLUMN font_file,
+    MODIFY type ENUM('image', 'text') NOT NULL;
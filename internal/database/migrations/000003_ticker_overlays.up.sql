@@ -0,0 +1,20 @@
+ALTER TABLE overlays
+    MODIFY type ENUM('image', 'text', 'ticker') NOT NULL,
+    ADD COLUMN font_file VARCHAR(255) NOT NULL DEFAULT '' AFTER font_color,
+    ADD COLUMN bg_color VARCHAR(20) NOT NULL DEFAULT 'black@0.6',
+    ADD COLUMN speed INT NOT NULL DEFAULT 100 COMMENT 'Ticker scroll speed in pixels per second',
+    ADD COLUMN feed_url VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'RSS/Atom/JSON headline source',
+    ADD COLUMN feed_interval INT NOT NULL DEFAULT 300 COMMENT 'Seconds between feed polls';
+
+-- Headlines shown by ticker overlays, either managed through the API or
+-- refreshed from the overlay's feed
+CREATE TABLE ticker_headlines (
+    headline_id INT AUTO_INCREMENT PRIMARY KEY,
+    overlay_id INT NOT NULL,
+    position INT NOT NULL,
+    text VARCHAR(1024) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (overlay_id) REFERENCES overlays(id) ON DELETE CASCADE,
+    INDEX idx_ticker_overlay (overlay_id, position)
+);
@@ -0,0 +1,12 @@
+ALTER TABLE media_files
+    DROP COLUMN tags;
+
+ALTER TABLE overlays
+    DROP COLUMN hide_during_ads,
+    DROP COLUMN playlist_item_ids,
+    DROP COLUMN media_tags,
+    DROP COLUMN end_date,
+    DROP COLUMN start_date,
+    DROP COLUMN days_of_week,
+    DROP COLUMN active_until,
+    DROP COLUMN active_from;
@@ -0,0 +1,14 @@
+-- Activation rules for overlays. Empty values place no restriction.
+ALTER TABLE overlays
+    ADD COLUMN active_from VARCHAR(8) NOT NULL DEFAULT '' COMMENT 'Time of day the overlay appears, HH:MM[:SS]',
+    ADD COLUMN active_until VARCHAR(8) NOT NULL DEFAULT '' COMMENT 'Time of day the overlay disappears, HH:MM[:SS]',
+    ADD COLUMN days_of_week VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'Comma separated, e.g. mon,tue,wed',
+    ADD COLUMN start_date DATE DEFAULT NULL,
+    ADD COLUMN end_date DATE DEFAULT NULL,
+    ADD COLUMN media_tags VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Only on media carrying one of these tags',
+    ADD COLUMN playlist_item_ids VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Only on these playlist items',
+    ADD COLUMN hide_during_ads BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Free-form comma separated tags; media tagged 'ad' is treated as an ad break
+ALTER TABLE media_files
+    ADD COLUMN tags VARCHAR(255) NOT NULL DEFAULT '' AFTER program_name;
@@ -0,0 +1,2 @@
+ALTER TABLE overlays
+    DROP COLUMN z_index;
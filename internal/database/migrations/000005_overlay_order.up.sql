@@ -0,0 +1,5 @@
+-- Stacking order of a channel's overlays; higher values are drawn on top
+ALTER TABLE overlays
+    ADD COLUMN z_index INT NOT NULL DEFAULT 0 AFTER enabled;
+
+UPDATE overlays SET z_index = id;
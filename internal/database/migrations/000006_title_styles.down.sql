@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS title_styles;
+
+ALTER TABLE languages
+    DROP COLUMN font_file;
@@ -0,0 +1,24 @@
+-- Font used for program titles in this language when a channel has no
+-- style that names one
+ALTER TABLE languages
+    ADD COLUMN font_file VARCHAR(255) NOT NULL DEFAULT '' AFTER language_name;
+
+-- How the program title is drawn on a channel. A row without a language is
+-- the channel default; rows with a language override it for media files in
+-- that language.
+CREATE TABLE title_styles (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    channel_id INT NOT NULL,
+    language_id INT DEFAULT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    font_file VARCHAR(255) NOT NULL DEFAULT '',
+    font_size VARCHAR(255) NOT NULL DEFAULT 'H/30',
+    font_color VARCHAR(50) NOT NULL DEFAULT 'white',
+    position_x VARCHAR(255) NOT NULL DEFAULT 'W/12',
+    position_y VARCHAR(255) NOT NULL DEFAULT 'H/12',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (channel_id) REFERENCES channels(channel_id) ON DELETE CASCADE,
+    FOREIGN KEY (language_id) REFERENCES languages(language_id) ON DELETE CASCADE,
+    INDEX idx_title_style_channel (channel_id, language_id)
+);
@@ -0,0 +1,12 @@
+-- Login sessions for the API. Only a hash of the bearer token is stored.
+CREATE TABLE user_sessions (
+    session_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    ip_address VARCHAR(45),
+    expires_at DATETIME NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_session_token (token_hash),
+    INDEX idx_session_expiry (expires_at)
+);
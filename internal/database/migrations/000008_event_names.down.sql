@@ -0,0 +1,3 @@
+ALTER TABLE event_logs
+    DROP INDEX idx_event_name,
+    DROP COLUMN event_name;
@@ -0,0 +1,5 @@
+-- Machine-readable event name (e.g. channel_started, item_started) next to
+-- the severity kept in event_type
+ALTER TABLE event_logs
+    ADD COLUMN event_name VARCHAR(50) NOT NULL DEFAULT '' AFTER event_type,
+    ADD INDEX idx_event_name (event_name, created_at);
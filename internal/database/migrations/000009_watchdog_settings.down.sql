@@ -0,0 +1,2 @@
+DELETE FROM system_settings WHERE setting_key IN
+    ('stall_timeout_seconds', 'min_encode_speed', 'slow_speed_timeout_seconds', 'max_stall_restarts');
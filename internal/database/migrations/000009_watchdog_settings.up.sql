@@ -0,0 +1,7 @@
+-- Thresholds for restarting an item whose FFmpeg process stops making
+-- progress; checked every health_check_interval seconds
+INSERT INTO system_settings (setting_key, setting_value, description) VALUES
+('stall_timeout_seconds', '30', 'Seconds without output progress before an item is restarted'),
+('min_encode_speed', '0.8', 'Encoding speed below which an item counts as too slow'),
+('slow_speed_timeout_seconds', '60', 'Seconds below min_encode_speed before an item is restarted'),
+('max_stall_restarts', '3', 'Restarts of a stalled item before skipping to the next item');
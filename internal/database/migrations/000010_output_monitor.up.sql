@@ -0,0 +1,3 @@
+-- Analyse the transmitted stream of the channel for alarms
+ALTER TABLE channels
+    ADD COLUMN monitor_output BOOLEAN NOT NULL DEFAULT FALSE AFTER use_previous_day_fallback;
@@ -0,0 +1,8 @@
+ALTER TABLE media_files
+    DROP COLUMN true_peak,
+    DROP COLUMN integrated_loudness;
+
+ALTER TABLE channels
+    DROP COLUMN measure_loudness,
+    DROP COLUMN loudness_true_peak,
+    DROP COLUMN loudness_target;
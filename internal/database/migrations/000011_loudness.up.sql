@@ -0,0 +1,11 @@
+-- Per-channel loudness target in LUFS (-23 for EBU R128, -24 for ATSC A/85);
+-- NULL leaves the audio level alone
+ALTER TABLE channels
+    ADD COLUMN loudness_target DECIMAL(4,1) NULL,
+    ADD COLUMN loudness_true_peak DECIMAL(3,1) NOT NULL DEFAULT -1.0,
+    ADD COLUMN measure_loudness BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Measured during media scans so playback can apply a fixed gain
+ALTER TABLE media_files
+    ADD COLUMN integrated_loudness DECIMAL(5,2) NULL AFTER duration_seconds,
+    ADD COLUMN true_peak DECIMAL(5,2) NULL AFTER integrated_loudness;
@@ -0,0 +1,4 @@
+ALTER TABLE channels
+    DROP COLUMN burn_subtitles,
+    DROP COLUMN pass_subtitles,
+    DROP COLUMN audio_languages;
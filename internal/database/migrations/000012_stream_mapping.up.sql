@@ -0,0 +1,6 @@
+-- Audio tracks to carry as comma separated ISO 639-2 codes (e.g. "sin,eng");
+-- NULL carries every audio track
+ALTER TABLE channels
+    ADD COLUMN audio_languages VARCHAR(100) NULL,
+    ADD COLUMN pass_subtitles BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN burn_subtitles BOOLEAN NOT NULL DEFAULT FALSE;
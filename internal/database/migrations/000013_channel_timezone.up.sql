@@ -0,0 +1,4 @@
+-- IANA timezone of the channel's schedule (e.g. "Europe/London"); NULL uses
+-- the station timezone
+ALTER TABLE channels
+    ADD COLUMN timezone VARCHAR(64) NULL;
@@ -0,0 +1,4 @@
+-- Channels with a higher priority start first, and leave the queue first,
+-- when there are fewer encoder slots than enabled channels
+ALTER TABLE channels
+    ADD COLUMN priority INT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS as_run_log;
+
+ALTER TABLE channels
+    DROP COLUMN start_mode;
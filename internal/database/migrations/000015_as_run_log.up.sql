@@ -0,0 +1,20 @@
+-- How a channel picks up playout when it starts: from the schedule, where
+-- it left off, or from what actually went to air
+ALTER TABLE channels
+    ADD COLUMN start_mode ENUM('schedule', 'resume', 'schedule_locked') NOT NULL DEFAULT 'schedule';
+
+-- What actually went to air, one row per item played
+CREATE TABLE as_run_log (
+    as_run_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    channel_id INT NOT NULL,
+    playlist_id INT NOT NULL,
+    item_id INT NOT NULL,
+    started_at DATETIME(3) NOT NULL,
+    start_offset DOUBLE NOT NULL DEFAULT 0 COMMENT 'Seconds into the item',
+    ended_at DATETIME(3) NULL,
+    end_position DOUBLE NULL COMMENT 'Seconds into the item',
+    end_reason VARCHAR(20) NULL COMMENT 'completed, skipped, restarted, stopped or failed',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (channel_id) REFERENCES channels(channel_id) ON DELETE CASCADE,
+    INDEX idx_as_run_channel (channel_id, started_at)
+);
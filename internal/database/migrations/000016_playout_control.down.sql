@@ -0,0 +1,8 @@
+ALTER TABLE as_run_log
+    DROP COLUMN slate;
+
+ALTER TABLE channel_states
+    DROP COLUMN on_slate;
+
+ALTER TABLE channels
+    DROP COLUMN slate_file;
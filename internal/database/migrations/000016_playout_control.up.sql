@@ -0,0 +1,11 @@
+-- Emergency slate operators can put on air instead of the playlist: a media
+-- file relative to the channel's storage root, e.g. slate/technical_fault.ts
+ALTER TABLE channels
+    ADD COLUMN slate_file VARCHAR(255) NULL;
+
+ALTER TABLE channel_states
+    ADD COLUMN on_slate BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Slate periods are logged alongside the items, against the paused item
+ALTER TABLE as_run_log
+    ADD COLUMN slate BOOLEAN NOT NULL DEFAULT FALSE AFTER item_id;
@@ -0,0 +1,5 @@
+-- A hash cannot be turned back into its key, so revoke the keys; users
+-- need to be given new ones
+UPDATE users
+    SET api_key = NULL
+    WHERE api_key IS NOT NULL;
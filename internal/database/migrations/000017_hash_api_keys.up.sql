@@ -0,0 +1,9 @@
+-- API keys are looked up by their SHA-256, hex encoded; hash the keys
+-- stored in plain text before that
+UPDATE users
+    SET api_key = SHA2(api_key, 256)
+    WHERE api_key IS NOT NULL AND api_key <> '';
+
+UPDATE users
+    SET api_key = NULL
+    WHERE api_key = '';
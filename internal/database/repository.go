@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/euacreations/tvheadend/internal/config"
@@ -14,12 +15,13 @@ import (
 )
 
 type Repository struct {
-	db *sqlx.DB
+	db *timedDB
 }
 
 func NewRepository(cfg *config.Config) (*Repository, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=Asia%%2FColombo",
-		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+	// DATETIME columns hold station time
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=%s",
+		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName, url.QueryEscape(cfg.Timezone))
 
 	db, err := sqlx.Open("mysql", dsn)
 
@@ -35,7 +37,7 @@ func NewRepository(cfg *config.Config) (*Repository, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Repository{db: db}, nil
+	return &Repository{db: &timedDB{db}}, nil
 }
 
 func (r *Repository) Close() error {
@@ -44,7 +46,7 @@ func (r *Repository) Close() error {
 
 func (r *Repository) UpdateChannel(ctx context.Context, channel *models.Channel) error {
 	query := `
-		INSERT INTO channel (
+		INSERT INTO channels (
 			channel_id,
 			channel_name,
 			storage_root,
@@ -54,6 +56,17 @@ func (r *Repository) UpdateChannel(ctx context.Context, channel *models.Channel)
 			start_time,
 			enabled,
 			use_previous_day_fallback,
+			monitor_output,
+			loudness_target,
+			loudness_true_peak,
+			measure_loudness,
+			audio_languages,
+			pass_subtitles,
+			burn_subtitles,
+			timezone,
+			priority,
+			start_mode,
+			slate_file,
 			video_codec,
 			video_bitrate,
 			min_bitrate,
@@ -81,6 +94,17 @@ func (r *Repository) UpdateChannel(ctx context.Context, channel *models.Channel)
 			:start_time,
 			:enabled,
 			:use_previous_day_fallback,
+			:monitor_output,
+			:loudness_target,
+			:loudness_true_peak,
+			:measure_loudness,
+			:audio_languages,
+			:pass_subtitles,
+			:burn_subtitles,
+			:timezone,
+			:priority,
+			:start_mode,
+			:slate_file,
 			:video_codec,
 			:video_bitrate,
 			:min_bitrate,
@@ -108,6 +132,17 @@ func (r *Repository) UpdateChannel(ctx context.Context, channel *models.Channel)
 			start_time = VALUES(start_time),
 			enabled = VALUES(enabled),
 			use_previous_day_fallback = VALUES(use_previous_day_fallback),
+			monitor_output = VALUES(monitor_output),
+			loudness_target = VALUES(loudness_target),
+			loudness_true_peak = VALUES(loudness_true_peak),
+			measure_loudness = VALUES(measure_loudness),
+			audio_languages = VALUES(audio_languages),
+			pass_subtitles = VALUES(pass_subtitles),
+			burn_subtitles = VALUES(burn_subtitles),
+			timezone = VALUES(timezone),
+			priority = VALUES(priority),
+			start_mode = VALUES(start_mode),
+			slate_file = VALUES(slate_file),
 			video_codec = VALUES(video_codec),
 			video_bitrate = VALUES(video_bitrate),
 			min_bitrate = VALUES(min_bitrate),
@@ -127,10 +162,18 @@ func (r *Repository) UpdateChannel(ctx context.Context, channel *models.Channel)
 	`
 
 	// NamedExecContext uses struct field tags (db:"...") for parameter binding
-	_, err := r.db.NamedExecContext(ctx, query, channel)
+	result, err := r.db.NamedExecContext(ctx, query, channel)
 	if err != nil {
 		return fmt.Errorf("failed to insert/update channel: %w", err)
 	}
+
+	if channel.ChannelID == 0 {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get channel ID: %w", err)
+		}
+		channel.ChannelID = int(id)
+	}
 	return nil
 }
 
@@ -188,15 +231,16 @@ func (r *Repository) GetChannelState(ctx context.Context, channelID int) (*model
 func (r *Repository) UpdateChannelState(ctx context.Context, state *models.ChannelState) error {
 	query := `INSERT INTO channel_states 
         (channel_id, current_playlist_id, current_item_id, current_position, 
-        running, ffmpeg_pid, last_update_time) 
+        running, ffmpeg_pid, on_slate, last_update_time) 
         VALUES (:channel_id, :current_playlist_id, :current_item_id, :current_position, 
-        :running, :ffmpeg_pid, :last_update_time)
+        :running, :ffmpeg_pid, :on_slate, :last_update_time)
         ON DUPLICATE KEY UPDATE 
         current_playlist_id = VALUES(current_playlist_id),
         current_item_id = VALUES(current_item_id),
         current_position = VALUES(current_position),
         running = VALUES(running),
         ffmpeg_pid = VALUES(ffmpeg_pid),
+        on_slate = VALUES(on_slate),
         last_update_time = VALUES(last_update_time)`
 
 	// Use NamedExecContext to automatically map struct fields to named parameters
@@ -222,7 +266,7 @@ func (r *Repository) CreateMediaFile(ctx context.Context, file *models.MediaFile
 		(channel_id, file_path, file_name, duration_seconds, file_size, last_modified, scanned_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := r.db.ExecContext(ctx, query,
+	result, err := r.db.ExecContext(ctx, query,
 		file.ChannelID,
 		file.FilePath,
 		file.FileName,
@@ -231,12 +275,21 @@ func (r *Repository) CreateMediaFile(ctx context.Context, file *models.MediaFile
 		file.LastModified,
 		file.ScannedAt,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get media ID: %w", err)
+	}
+	file.MediaID = int(id)
+	return nil
 }
 
 /*func (r *Repository) GetMediaFiles(ctx context.Context, channelID int) ([]*models.MediaFile, error) {
 	query := `SELECT media_id, channel_id, file_path, file_name, duration_seconds,
-            program_name, file_size, last_modified, scanned_at, created_at, updated_at
+            program_name, language_id, tags, file_size, last_modified, scanned_at, created_at, updated_at
 			FROM media_files WHERE channel_id = ?`
 
 	var mf []*models.MediaFile
@@ -259,8 +312,8 @@ func (r *Repository) GetMediaFiles(ctx context.Context, channelID int, page, pag
 
 	offset := (page - 1) * pageSize
 
-	query := `SELECT media_id, channel_id, file_path, file_name, duration_seconds, 
-            program_name, file_size, last_modified, scanned_at, created_at, updated_at
+	query := `SELECT media_id, channel_id, file_path, file_name, duration_seconds,
+            integrated_loudness, true_peak, program_name, language_id, tags, file_size, last_modified, scanned_at, created_at, updated_at
             FROM media_files 
             WHERE channel_id = ?
             LIMIT ? OFFSET ?`
@@ -285,8 +338,8 @@ func (r *Repository) CountMediaFiles(ctx context.Context, channelID int) (int, e
 }
 
 func (r *Repository) GetMediaFile(ctx context.Context, mediaID sql.NullInt64) (*models.MediaFile, error) {
-	query := `SELECT media_id, channel_id, file_path, file_name, duration_seconds, 
-            program_name, file_size, last_modified, scanned_at, created_at, updated_at
+	query := `SELECT media_id, channel_id, file_path, file_name, duration_seconds,
+            integrated_loudness, true_peak, program_name, language_id, tags, file_size, last_modified, scanned_at, created_at, updated_at
 			FROM media_files WHERE media_id = ?`
 
 	var mf models.MediaFile
@@ -297,6 +350,56 @@ func (r *Repository) GetMediaFile(ctx context.Context, mediaID sql.NullInt64) (*
 	return &mf, nil
 }
 
+// GetUnmeasuredMediaFiles returns the channel's media files whose loudness
+// has not been measured yet.
+func (r *Repository) GetUnmeasuredMediaFiles(ctx context.Context, channelID int) ([]*models.MediaFile, error) {
+	query := `SELECT media_id, channel_id, file_path, file_name, duration_seconds,
+            integrated_loudness, true_peak, program_name, language_id, tags, file_size, last_modified, scanned_at, created_at, updated_at
+            FROM media_files
+            WHERE channel_id = ? AND integrated_loudness IS NULL
+            ORDER BY media_id`
+
+	var mf []*models.MediaFile
+	if err := r.db.SelectContext(ctx, &mf, query, channelID); err != nil {
+		return nil, fmt.Errorf("failed to get unmeasured media files: %w", err)
+	}
+	return mf, nil
+}
+
+// UpdateMediaLoudness stores the measured loudness of a media file.
+func (r *Repository) UpdateMediaLoudness(ctx context.Context, mediaID int, integrated, truePeak float64) error {
+	query := `UPDATE media_files SET integrated_loudness = ?, true_peak = ? WHERE media_id = ?`
+	if _, err := r.db.ExecContext(ctx, query, integrated, truePeak, mediaID); err != nil {
+		return fmt.Errorf("failed to update media loudness: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) CreateUDPStream(ctx context.Context, stream *models.UDPStream) error {
+	query := `INSERT INTO udp_streams
+        (channel_id, stream_name, stream_url, description, is_infinite, duration_seconds)
+        VALUES (?, ?, ?, ?, ?, ?)`
+
+	result, err := r.db.ExecContext(ctx, query,
+		stream.ChannelID,
+		stream.StreamName,
+		stream.StreamURL,
+		stream.Description,
+		stream.IsInfinite,
+		stream.DurationSeconds,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create udp stream: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get udp stream ID: %w", err)
+	}
+	stream.StreamID = int(id)
+	return nil
+}
+
 func (r *Repository) GetUDPStream(ctx context.Context, streamID sql.NullInt64) (*models.UDPStream, error) {
 	query := `SELECT * FROM udp_streams WHERE stream_id = ?`
 
@@ -332,13 +435,40 @@ func (r *Repository) GetPlaylist(ctx context.Context, playlistID int) (*models.P
 		FROM playlists
 		WHERE playlist_id = ?`
 
-	var playlist *models.Playlist
-	err := r.db.SelectContext(ctx, &playlist, query, playlistID)
+	var playlist models.Playlist
+	err := r.db.GetContext(ctx, &playlist, query, playlistID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get playlists: %w", err)
+		return nil, fmt.Errorf("failed to get playlist %d: %w", playlistID, err)
+	}
+
+	return &playlist, nil
+}
+
+func (r *Repository) CreatePlaylist(ctx context.Context, playlist *models.Playlist) error {
+	query := `INSERT INTO playlists (channel_id, playlist_date, status, total_duration_seconds)
+        VALUES (?, ?, ?, ?)`
+
+	var date interface{}
+	if playlist.PlaylistDate != nil {
+		date = playlist.PlaylistDate.Format("2006-01-02")
+	}
+
+	result, err := r.db.ExecContext(ctx, query,
+		playlist.ChannelID,
+		date,
+		playlist.Status,
+		playlist.TotalDurationSeconds,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create playlist: %w", err)
 	}
 
-	return playlist, nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get playlist ID: %w", err)
+	}
+	playlist.PlaylistID = int(id)
+	return nil
 }
 
 func (r *Repository) GetActivePlaylist(ctx context.Context, channelID int) (*models.Playlist, error) {
@@ -346,13 +476,13 @@ func (r *Repository) GetActivePlaylist(ctx context.Context, channelID int) (*mod
               WHERE channel_id = ? AND status = 'active' 
               ORDER BY created_at DESC LIMIT 1`
 
-	var playlist *models.Playlist
-	err := r.db.SelectContext(ctx, &playlist, query, channelID)
+	var playlist models.Playlist
+	err := r.db.GetContext(ctx, &playlist, query, channelID)
 
 	if err != nil {
 		return nil, err
 	}
-	return playlist, nil
+	return &playlist, nil
 }
 
 func (r *Repository) GetPlaylistItems(ctx context.Context, playlistID int) ([]*models.PlaylistItem, error) {
@@ -367,8 +497,35 @@ func (r *Repository) GetPlaylistItems(ctx context.Context, playlistID int) ([]*m
 	return items, nil
 }
 
+func (r *Repository) CreatePlaylistItem(ctx context.Context, item *models.PlaylistItem) error {
+	query := `INSERT INTO playlist_items
+        (playlist_id, media_id, stream_id, type, position, scheduled_start_time, scheduled_end_time, locked)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := r.db.ExecContext(ctx, query,
+		item.PlaylistID,
+		item.MediaID,
+		item.StreamID,
+		item.Type,
+		item.Position,
+		item.ScheduledStartTime,
+		item.ScheduledEndTime,
+		item.Locked,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create playlist item: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get playlist item ID: %w", err)
+	}
+	item.ItemID = int(id)
+	return nil
+}
+
 func (r *Repository) GetChannelOverlays(ctx context.Context, channelID int) ([]*models.Overlay, error) {
-	query := `SELECT * FROM overlays WHERE channel_id = ? AND enabled = TRUE`
+	query := `SELECT * FROM overlays WHERE channel_id = ? AND enabled = TRUE ORDER BY z_index, id`
 
 	var overlays []*models.Overlay
 	err := r.db.SelectContext(ctx, &overlays, query, channelID)
@@ -379,29 +536,444 @@ func (r *Repository) GetChannelOverlays(ctx context.Context, channelID int) ([]*
 	return overlays, nil
 }
 
+// GetAllChannelOverlays returns every overlay of a channel, including disabled
+// ones, in stacking order.
+func (r *Repository) GetAllChannelOverlays(ctx context.Context, channelID int) ([]*models.Overlay, error) {
+	query := `SELECT * FROM overlays WHERE channel_id = ? ORDER BY z_index, id`
+
+	var overlays []*models.Overlay
+	if err := r.db.SelectContext(ctx, &overlays, query, channelID); err != nil {
+		return nil, fmt.Errorf("failed to get overlays for channel %d: %w", channelID, err)
+	}
+	return overlays, nil
+}
+
+func (r *Repository) GetOverlay(ctx context.Context, overlayID int) (*models.Overlay, error) {
+	query := `SELECT * FROM overlays WHERE id = ?`
+
+	var overlay models.Overlay
+	if err := r.db.GetContext(ctx, &overlay, query, overlayID); err != nil {
+		return nil, fmt.Errorf("failed to get overlay with ID %d: %w", overlayID, err)
+	}
+	return &overlay, nil
+}
+
 func (r *Repository) CreateOverlay(ctx context.Context, overlay *models.Overlay) error {
+	// New overlays go on top of the channel's existing ones
 	query := `INSERT INTO overlays 
-        (channel_id, type, file_path, position_x, position_y, 
-         enabled, font_size, font_color)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-        RETURNING id, created_at, updated_at`
-
-	row := r.db.QueryRowContext(ctx, query, // Note: lowercase r.db if not exported
+        (channel_id, type, file_path, text, position_x, position_y, 
+         enabled, font_size, font_color, font_file,
+         bg_color, speed, feed_url, feed_interval,
+         active_from, active_until, days_of_week, start_date, end_date,
+         media_tags, playlist_item_ids, hide_during_ads, z_index)
+        SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+            COALESCE(MAX(z_index), 0) + 1
+        FROM overlays WHERE channel_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query,
 		overlay.ChannelID,
 		overlay.Type,
 		overlay.FilePath,
+		overlay.Text,
 		overlay.PositionX,
 		overlay.PositionY,
 		overlay.Enabled,
 		overlay.FontSize,
 		overlay.FontColor,
+		overlay.FontFile,
+		overlay.BackgroundColor,
+		overlay.Speed,
+		overlay.FeedURL,
+		overlay.FeedInterval,
+		overlay.ActiveFrom,
+		overlay.ActiveUntil,
+		overlay.DaysOfWeek,
+		overlay.StartDate,
+		overlay.EndDate,
+		overlay.MediaTags,
+		overlay.PlaylistItemIDs,
+		overlay.HideDuringAds,
+		overlay.ChannelID,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	created, err := r.GetOverlay(ctx, int(id))
+	if err != nil {
+		return err
+	}
+	*overlay = *created
+	return nil
+}
+
+func (r *Repository) UpdateOverlay(ctx context.Context, overlay *models.Overlay) error {
+	query := `UPDATE overlays SET
+        type = ?, file_path = ?, text = ?, position_x = ?, position_y = ?,
+        enabled = ?, font_size = ?, font_color = ?, font_file = ?,
+        bg_color = ?, speed = ?, feed_url = ?, feed_interval = ?,
+        active_from = ?, active_until = ?, days_of_week = ?, start_date = ?, end_date = ?,
+        media_tags = ?, playlist_item_ids = ?, hide_during_ads = ?
+        WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx, query,
+		overlay.Type,
+		overlay.FilePath,
+		overlay.Text,
+		overlay.PositionX,
+		overlay.PositionY,
+		overlay.Enabled,
+		overlay.FontSize,
+		overlay.FontColor,
+		overlay.FontFile,
+		overlay.BackgroundColor,
+		overlay.Speed,
+		overlay.FeedURL,
+		overlay.FeedInterval,
+		overlay.ActiveFrom,
+		overlay.ActiveUntil,
+		overlay.DaysOfWeek,
+		overlay.StartDate,
+		overlay.EndDate,
+		overlay.MediaTags,
+		overlay.PlaylistItemIDs,
+		overlay.HideDuringAds,
+		overlay.OverlayID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update overlay %d: %w", overlay.OverlayID, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteOverlay(ctx context.Context, overlayID int) error {
+	query := `DELETE FROM overlays WHERE id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, overlayID)
+	if err != nil {
+		return fmt.Errorf("failed to delete overlay %d: %w", overlayID, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("failed to delete overlay %d: %w", overlayID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// ReorderOverlays sets the stacking order of a channel's overlays to the order
+// of the given IDs, bottom first.
+func (r *Repository) ReorderOverlays(ctx context.Context, channelID int, overlayIDs []int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, id := range overlayIDs {
+		query := `UPDATE overlays SET z_index = ? WHERE id = ? AND channel_id = ?`
+		if _, err := tx.ExecContext(ctx, query, i+1, id, channelID); err != nil {
+			return fmt.Errorf("failed to reorder overlay %d: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetFeedTickers returns the enabled ticker overlays of all channels that take
+// their headlines from a feed.
+func (r *Repository) GetFeedTickers(ctx context.Context) ([]*models.Overlay, error) {
+	query := `SELECT * FROM overlays WHERE type = 'ticker' AND feed_url <> '' AND enabled = TRUE`
+
+	var overlays []*models.Overlay
+	if err := r.db.SelectContext(ctx, &overlays, query); err != nil {
+		return nil, fmt.Errorf("failed to get feed tickers: %w", err)
+	}
+	return overlays, nil
+}
+
+func (r *Repository) GetTickerHeadlines(ctx context.Context, overlayID int) ([]*models.TickerHeadline, error) {
+	query := `SELECT * FROM ticker_headlines WHERE overlay_id = ? ORDER BY position`
+
+	var headlines []*models.TickerHeadline
+	if err := r.db.SelectContext(ctx, &headlines, query, overlayID); err != nil {
+		return nil, fmt.Errorf("failed to get headlines for overlay %d: %w", overlayID, err)
+	}
+	return headlines, nil
+}
+
+// ReplaceTickerHeadlines swaps the headlines of a ticker overlay in one transaction.
+func (r *Repository) ReplaceTickerHeadlines(ctx context.Context, overlayID int, headlines []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM ticker_headlines WHERE overlay_id = ?`, overlayID); err != nil {
+		return fmt.Errorf("failed to clear headlines for overlay %d: %w", overlayID, err)
+	}
+
+	for i, text := range headlines {
+		query := `INSERT INTO ticker_headlines (overlay_id, position, text) VALUES (?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, overlayID, i+1, text); err != nil {
+			return fmt.Errorf("failed to insert headline for overlay %d: %w", overlayID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) GetLanguage(ctx context.Context, languageID int) (*models.Language, error) {
+	query := `SELECT * FROM languages WHERE language_id = ?`
+
+	var language models.Language
+	if err := r.db.GetContext(ctx, &language, query, languageID); err != nil {
+		return nil, fmt.Errorf("failed to get language with ID %d: %w", languageID, err)
+	}
+	return &language, nil
+}
+
+func (r *Repository) GetLanguages(ctx context.Context) ([]*models.Language, error) {
+	query := `SELECT * FROM languages ORDER BY language_id`
+
+	var languages []*models.Language
+	if err := r.db.SelectContext(ctx, &languages, query); err != nil {
+		return nil, fmt.Errorf("failed to get languages: %w", err)
+	}
+	return languages, nil
+}
+
+func (r *Repository) GetTitleStyles(ctx context.Context, channelID int) ([]*models.TitleStyle, error) {
+	query := `SELECT * FROM title_styles WHERE channel_id = ? ORDER BY language_id IS NOT NULL, language_id`
+
+	var styles []*models.TitleStyle
+	if err := r.db.SelectContext(ctx, &styles, query, channelID); err != nil {
+		return nil, fmt.Errorf("failed to get title styles for channel %d: %w", channelID, err)
+	}
+	return styles, nil
+}
+
+// ReplaceTitleStyles replaces all title styles of a channel.
+func (r *Repository) ReplaceTitleStyles(ctx context.Context, channelID int, styles []*models.TitleStyle) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM title_styles WHERE channel_id = ?`, channelID); err != nil {
+		return fmt.Errorf("failed to clear title styles for channel %d: %w", channelID, err)
+	}
+
+	for _, style := range styles {
+		query := `INSERT INTO title_styles 
+            (channel_id, language_id, enabled, font_file, font_size, font_color, position_x, position_y)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, query,
+			channelID,
+			style.LanguageID,
+			style.Enabled,
+			style.FontFile,
+			style.FontSize,
+			style.FontColor,
+			style.PositionX,
+			style.PositionY,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert title style for channel %d: %w", channelID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) GetUsers(ctx context.Context) ([]*models.User, error) {
+	query := `SELECT * FROM users ORDER BY user_id`
+
+	var users []*models.User
+	if err := r.db.SelectContext(ctx, &users, query); err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return users, nil
+}
+
+func (r *Repository) CountUsers(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM users`); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+func (r *Repository) GetUser(ctx context.Context, userID int) (*models.User, error) {
+	query := `SELECT * FROM users WHERE user_id = ?`
+
+	var user models.User
+	if err := r.db.GetContext(ctx, &user, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get user with ID %d: %w", userID, err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `SELECT * FROM users WHERE username = ?`
+
+	var user models.User
+	if err := r.db.GetContext(ctx, &user, query, username); err != nil {
+		return nil, fmt.Errorf("failed to get user %q: %w", username, err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) GetUserByAPIKey(ctx context.Context, keyHash string) (*models.User, error) {
+	query := `SELECT * FROM users WHERE api_key = ?`
+
+	var user models.User
+	if err := r.db.GetContext(ctx, &user, query, keyHash); err != nil {
+		return nil, fmt.Errorf("failed to get user by API key: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) CreateUser(ctx context.Context, user *models.User) error {
+	query := `INSERT INTO users (username, password_hash, email, role, api_key, is_active)
+        VALUES (?, ?, ?, ?, ?, ?)`
+
+	result, err := r.db.ExecContext(ctx, query,
+		user.Username,
+		user.PasswordHash,
+		user.Email,
+		user.Role,
+		user.APIKey,
+		user.IsActive,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get user ID: %w", err)
+	}
+	user.UserID = int(id)
+	return nil
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, user *models.User) error {
+	query := `UPDATE users SET 
+            username = ?, password_hash = ?, email = ?, role = ?, api_key = ?, is_active = ?
+        WHERE user_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query,
+		user.Username,
+		user.PasswordHash,
+		user.Email,
+		user.Role,
+		user.APIKey,
+		user.IsActive,
+		user.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user %d: %w", user.UserID, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		// MySQL reports zero rows when nothing changed, so check the user exists
+		if _, err := r.GetUser(ctx, user.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) DeleteUser(ctx context.Context, userID int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user %d: %w", userID, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("failed to delete user %d: %w", userID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func (r *Repository) UpdateUserLastLogin(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET last_login = NOW() WHERE user_id = ?`, userID)
+	return err
+}
+
+func (r *Repository) CreateUserSession(ctx context.Context, session *models.UserSession) error {
+	query := `INSERT INTO user_sessions (user_id, token_hash, ip_address, expires_at)
+        VALUES (?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		session.UserID,
+		session.TokenHash,
+		session.IPAddress,
+		session.ExpiresAt,
 	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// GetSessionUser returns the user of an unexpired session.
+func (r *Repository) GetSessionUser(ctx context.Context, tokenHash string) (*models.User, error) {
+	query := `SELECT u.* FROM users u
+        JOIN user_sessions s ON s.user_id = u.user_id
+        WHERE s.token_hash = ? AND s.expires_at > ?`
 
-	return row.Scan(
-		&overlay.OverlayID,
-		&overlay.CreatedAt,
-		&overlay.UpdatedAt,
+	var user models.User
+	if err := r.db.GetContext(ctx, &user, query, tokenHash, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) DeleteUserSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_sessions WHERE token_hash = ?`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserSessions logs a user out everywhere.
+func (r *Repository) DeleteUserSessions(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sessions of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteExpiredSessions(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_sessions WHERE expires_at <= ?`, time.Now())
+	return err
+}
+
+func (r *Repository) CreateAuditLog(ctx context.Context, entry *models.AuditLog) error {
+	query := `INSERT INTO audit_logs 
+        (user_id, action_type, target_type, target_id, old_value, new_value, ip_address)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		entry.UserID,
+		entry.ActionType,
+		entry.TargetType,
+		entry.TargetID,
+		entry.OldValue,
+		entry.NewValue,
+		entry.IPAddress,
 	)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
 }
 
 /*New Func*/
@@ -511,3 +1083,229 @@ func (r *Repository) UnlockPlaylistItem(ctx context.Context, itemID int) error {
 	_, err := r.db.ExecContext(ctx, query, itemID)
 	return err
 }
+
+func (r *Repository) GetAuditLogs(ctx context.Context, filter models.AuditFilter) ([]*models.AuditLog, error) {
+	query := `SELECT * FROM audit_logs WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.UserID != 0 {
+		query += ` AND user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.ActionType != "" {
+		query += ` AND action_type = ?`
+		args = append(args, filter.ActionType)
+	}
+	if filter.TargetType != "" {
+		query += ` AND target_type = ?`
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query += ` AND target_id = ?`
+		args = append(args, filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND action_time >= ?`
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND action_time < ?`
+		args = append(args, filter.Until)
+	}
+	query += ` ORDER BY action_time DESC, audit_id DESC LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	var logs []*models.AuditLog
+	if err := r.db.SelectContext(ctx, &logs, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+	return logs, nil
+}
+
+func (r *Repository) CreateAPIAccessLog(ctx context.Context, entry *models.APIAccessLog) error {
+	query := `INSERT INTO api_access_logs 
+        (user_id, endpoint, method, status_code, ip_address, user_agent, duration_ms, request_params)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		entry.UserID,
+		entry.Endpoint,
+		entry.Method,
+		entry.StatusCode,
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.DurationMs,
+		entry.RequestParams,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write access log: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIAccessLogsBefore removes access log entries older than the given
+// time.
+func (r *Repository) DeleteAPIAccessLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM api_access_logs WHERE request_time < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old access logs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *Repository) CreateEventLog(ctx context.Context, event *models.EventLog) error {
+	query := `INSERT INTO event_logs 
+        (channel_id, event_type, event_name, event_category, message, details, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		event.ChannelID,
+		event.EventType,
+		event.EventName,
+		event.EventCategory,
+		event.Message,
+		event.Details,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write event log: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetEventLogs(ctx context.Context, filter models.EventFilter) ([]*models.EventLog, error) {
+	query := `SELECT * FROM event_logs WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.ChannelID != 0 {
+		query += ` AND channel_id = ?`
+		args = append(args, filter.ChannelID)
+	}
+	if filter.EventName != "" {
+		query += ` AND event_name = ?`
+		args = append(args, filter.EventName)
+	}
+	if filter.EventType != "" {
+		query += ` AND event_type = ?`
+		args = append(args, filter.EventType)
+	}
+	if filter.Category != "" {
+		query += ` AND event_category = ?`
+		args = append(args, filter.Category)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.Since)
+	}
+	query += ` ORDER BY created_at DESC, event_id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	var events []*models.EventLog
+	if err := r.db.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get event logs: %w", err)
+	}
+	return events, nil
+}
+
+// CreateAsRunEntry records an item going to air.
+func (r *Repository) CreateAsRunEntry(ctx context.Context, entry *models.AsRunEntry) error {
+	query := `INSERT INTO as_run_log
+        (channel_id, playlist_id, item_id, slate, started_at, start_offset)
+        VALUES (?, ?, ?, ?, ?, ?)`
+
+	result, err := r.db.ExecContext(ctx, query,
+		entry.ChannelID,
+		entry.PlaylistID,
+		entry.ItemID,
+		entry.Slate,
+		entry.StartedAt,
+		entry.StartOffset,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write as-run entry: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get as-run entry ID: %w", err)
+	}
+	entry.AsRunID = int(id)
+	return nil
+}
+
+// EndAsRunEntry records an item leaving the air.
+func (r *Repository) EndAsRunEntry(ctx context.Context, asRunID int, endedAt time.Time, position float64, reason string) error {
+	query := `UPDATE as_run_log SET ended_at = ?, end_position = ?, end_reason = ?
+        WHERE as_run_id = ?`
+
+	if _, err := r.db.ExecContext(ctx, query, endedAt, position, reason, asRunID); err != nil {
+		return fmt.Errorf("failed to end as-run entry: %w", err)
+	}
+	return nil
+}
+
+// GetAsRunLog returns a channel's as-run entries, newest first.
+func (r *Repository) GetAsRunLog(ctx context.Context, channelID int, since time.Time, limit int) ([]*models.AsRunEntry, error) {
+	query := `SELECT * FROM as_run_log WHERE channel_id = ?`
+	args := []interface{}{channelID}
+
+	if !since.IsZero() {
+		query += ` AND started_at >= ?`
+		args = append(args, since)
+	}
+	query += ` ORDER BY started_at DESC, as_run_id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	var entries []*models.AsRunEntry
+	if err := r.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get as-run log: %w", err)
+	}
+	return entries, nil
+}
+
+// DeleteEventLogsBefore removes events older than the given time.
+func (r *Repository) DeleteEventLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM event_logs WHERE created_at < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old event logs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// TrimChannelEventLogs keeps only the newest keep events of a channel.
+func (r *Repository) TrimChannelEventLogs(ctx context.Context, channelID int, keep int) (int64, error) {
+	query := `DELETE FROM event_logs WHERE channel_id = ? AND event_id < (
+            SELECT event_id FROM (
+                SELECT event_id FROM event_logs WHERE channel_id = ?
+                ORDER BY event_id DESC LIMIT 1 OFFSET ?
+            ) AS newest
+        )`
+
+	result, err := r.db.ExecContext(ctx, query, channelID, channelID, keep-1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim event logs of channel %d: %w", channelID, err)
+	}
+	return result.RowsAffected()
+}
+
+// GetSystemSetting returns the value of a system setting.
+func (r *Repository) GetSystemSetting(ctx context.Context, key string) (string, error) {
+	var value sql.NullString
+	query := `SELECT setting_value FROM system_settings WHERE setting_key = ?`
+	if err := r.db.GetContext(ctx, &value, query, key); err != nil {
+		return "", fmt.Errorf("failed to get setting %q: %w", key, err)
+	}
+	return value.String, nil
+}
+
+// SetSystemSetting stores the value of a system setting, adding it if needed.
+func (r *Repository) SetSystemSetting(ctx context.Context, key, value string) error {
+	query := `INSERT INTO system_settings (setting_key, setting_value) VALUES (?, ?)
+        ON DUPLICATE KEY UPDATE setting_value = VALUES(setting_value)`
+	if _, err := r.db.ExecContext(ctx, query, key, value); err != nil {
+		return fmt.Errorf("failed to set setting %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TestRepository runs the store contract against a migrated MySQL database
+// named by TVHEADEND_TEST_DSN, e.g.
+// "user:pass@tcp(localhost:3306)/tvheadend_test?parseTime=true".
+func TestRepository(t *testing.T) {
+	dsn := os.Getenv("TVHEADEND_TEST_DSN")
+	if dsn == "" {
+		t.Skip("TVHEADEND_TEST_DSN is not set")
+	}
+
+	db, err := sqlx.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to ping database: %v", err)
+	}
+
+	repo := &Repository{db: &timedDB{db}}
+	testStore(t, func(t *testing.T) Store { return repo })
+}
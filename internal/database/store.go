@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// Store is everything the services need from the database. Repository keeps
+// it in MySQL and MemoryStore in memory, for tests.
+type Store interface {
+	ChannelStore
+	StateStore
+	MediaStore
+	PlaylistStore
+	OverlayStore
+	UserStore
+	LogStore
+	AsRunStore
+	SettingStore
+}
+
+var (
+	_ Store = (*Repository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+type ChannelStore interface {
+	UpdateChannel(ctx context.Context, channel *models.Channel) error
+	GetChannelByID(ctx context.Context, channelID int) (*models.Channel, error)
+	GetAllChannels(ctx context.Context) ([]*models.Channel, error)
+}
+
+type StateStore interface {
+	GetChannelState(ctx context.Context, channelID int) (*models.ChannelState, error)
+	UpdateChannelState(ctx context.Context, state *models.ChannelState) error
+}
+
+type MediaStore interface {
+	MediaFileExists(ctx context.Context, channelID int, filePath string) (bool, error)
+	CreateMediaFile(ctx context.Context, file *models.MediaFile) error
+	GetMediaFiles(ctx context.Context, channelID int, page, pageSize int) ([]*models.MediaFile, error)
+	CountMediaFiles(ctx context.Context, channelID int) (int, error)
+	GetMediaFile(ctx context.Context, mediaID sql.NullInt64) (*models.MediaFile, error)
+	GetUnmeasuredMediaFiles(ctx context.Context, channelID int) ([]*models.MediaFile, error)
+	UpdateMediaLoudness(ctx context.Context, mediaID int, integrated, truePeak float64) error
+}
+
+type PlaylistStore interface {
+	CreateUDPStream(ctx context.Context, stream *models.UDPStream) error
+	GetUDPStream(ctx context.Context, streamID sql.NullInt64) (*models.UDPStream, error)
+	CreatePlaylist(ctx context.Context, playlist *models.Playlist) error
+	GetPlaylists(ctx context.Context, channelID int) ([]*models.Playlist, error)
+	GetPlaylist(ctx context.Context, playlistID int) (*models.Playlist, error)
+	GetActivePlaylist(ctx context.Context, channelID int) (*models.Playlist, error)
+	GetPlaylistForDate(ctx context.Context, channelID int, playlistDate time.Time) (*models.Playlist, error)
+	GetNextPlaylistForChannel(ctx context.Context, channelID int) (*models.Playlist, error)
+	CreatePlaylistItem(ctx context.Context, item *models.PlaylistItem) error
+	GetPlaylistItems(ctx context.Context, playlistID int) ([]*models.PlaylistItem, error)
+	GetCurrentAndNextPlaylistItems(ctx context.Context, playlistID int) (*models.PlaylistItem, *models.PlaylistItem, error)
+	LockPlaylistItem(ctx context.Context, itemID int) error
+	UnlockPlaylistItem(ctx context.Context, itemID int) error
+}
+
+type OverlayStore interface {
+	GetChannelOverlays(ctx context.Context, channelID int) ([]*models.Overlay, error)
+	GetAllChannelOverlays(ctx context.Context, channelID int) ([]*models.Overlay, error)
+	GetOverlay(ctx context.Context, overlayID int) (*models.Overlay, error)
+	CreateOverlay(ctx context.Context, overlay *models.Overlay) error
+	UpdateOverlay(ctx context.Context, overlay *models.Overlay) error
+	DeleteOverlay(ctx context.Context, overlayID int) error
+	ReorderOverlays(ctx context.Context, channelID int, overlayIDs []int) error
+	GetFeedTickers(ctx context.Context) ([]*models.Overlay, error)
+	GetTickerHeadlines(ctx context.Context, overlayID int) ([]*models.TickerHeadline, error)
+	ReplaceTickerHeadlines(ctx context.Context, overlayID int, headlines []string) error
+	GetLanguage(ctx context.Context, languageID int) (*models.Language, error)
+	GetLanguages(ctx context.Context) ([]*models.Language, error)
+	GetTitleStyles(ctx context.Context, channelID int) ([]*models.TitleStyle, error)
+	ReplaceTitleStyles(ctx context.Context, channelID int, styles []*models.TitleStyle) error
+}
+
+type UserStore interface {
+	GetUsers(ctx context.Context) ([]*models.User, error)
+	CountUsers(ctx context.Context) (int, error)
+	GetUser(ctx context.Context, userID int) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByAPIKey(ctx context.Context, keyHash string) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, userID int) error
+	UpdateUserLastLogin(ctx context.Context, userID int) error
+	CreateUserSession(ctx context.Context, session *models.UserSession) error
+	GetSessionUser(ctx context.Context, tokenHash string) (*models.User, error)
+	DeleteUserSession(ctx context.Context, tokenHash string) error
+	DeleteUserSessions(ctx context.Context, userID int) error
+	DeleteExpiredSessions(ctx context.Context) error
+}
+
+// LogStore keeps the audit, API access and event logs.
+type LogStore interface {
+	CreateAuditLog(ctx context.Context, entry *models.AuditLog) error
+	GetAuditLogs(ctx context.Context, filter models.AuditFilter) ([]*models.AuditLog, error)
+	CreateAPIAccessLog(ctx context.Context, entry *models.APIAccessLog) error
+	DeleteAPIAccessLogsBefore(ctx context.Context, before time.Time) (int64, error)
+	CreateEventLog(ctx context.Context, event *models.EventLog) error
+	GetEventLogs(ctx context.Context, filter models.EventFilter) ([]*models.EventLog, error)
+	DeleteEventLogsBefore(ctx context.Context, before time.Time) (int64, error)
+	TrimChannelEventLogs(ctx context.Context, channelID int, keep int) (int64, error)
+}
+
+// AsRunStore keeps the as-run log of what actually went to air.
+type AsRunStore interface {
+	CreateAsRunEntry(ctx context.Context, entry *models.AsRunEntry) error
+	EndAsRunEntry(ctx context.Context, asRunID int, endedAt time.Time, position float64, reason string) error
+	GetAsRunLog(ctx context.Context, channelID int, since time.Time, limit int) ([]*models.AsRunEntry, error)
+}
+
+type SettingStore interface {
+	GetSystemSetting(ctx context.Context, key string) (string, error)
+	SetSystemSetting(ctx context.Context, key, value string) error
+}
@@ -0,0 +1,743 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// testStore runs the behaviour every Store must share against the store
+// returned by newStore. Each test creates its own channel, so the store may
+// already hold data.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store Store)
+	}{
+		{"Channels", testChannels},
+		{"ChannelState", testChannelState},
+		{"MediaFiles", testMediaFiles},
+		{"Playlists", testPlaylists},
+		{"PlaylistItems", testPlaylistItems},
+		{"Overlays", testOverlays},
+		{"Users", testUsers},
+		{"UserSessions", testUserSessions},
+		{"AuditLogs", testAuditLogs},
+		{"APIAccessLogs", testAPIAccessLogs},
+		{"EventLogs", testEventLogs},
+		{"AsRunLog", testAsRunLog},
+		{"Settings", testSettings},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newStore(t))
+		})
+	}
+}
+
+func createTestChannel(t *testing.T, store Store) *models.Channel {
+	t.Helper()
+	channel := &models.Channel{
+		ChannelName:      fmt.Sprintf("test-%s-%d", t.Name(), time.Now().UnixNano()),
+		StorageRoot:      t.TempDir(),
+		OutputUDP:        "udp://239.0.0.1:1234",
+		PlaylistType:     "daily",
+		StartTimeStr:     "06:00:00",
+		Enabled:          true,
+		VideoCodec:       "h264_nvenc",
+		VideoBitrate:     "4M",
+		AudioCodec:       "aac",
+		AudioBitrate:     "128k",
+		LoudnessTruePeak: -1,
+		OutputResolution: "1920x1080",
+	}
+	if err := store.UpdateChannel(context.Background(), channel); err != nil {
+		t.Fatalf("UpdateChannel: %v", err)
+	}
+	if channel.ChannelID == 0 {
+		t.Fatal("UpdateChannel did not set the channel ID")
+	}
+	return channel
+}
+
+func testChannels(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+
+	got, err := store.GetChannelByID(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetChannelByID: %v", err)
+	}
+	if got.ChannelName != channel.ChannelName || got.OutputUDP != channel.OutputUDP {
+		t.Errorf("GetChannelByID = %q %q, want %q %q", got.ChannelName, got.OutputUDP, channel.ChannelName, channel.OutputUDP)
+	}
+	if got.StartTime.Hour() != 6 {
+		t.Errorf("StartTime = %v, want 06:00", got.StartTime)
+	}
+
+	got.VideoBitrate = "6M"
+	if err := store.UpdateChannel(ctx, got); err != nil {
+		t.Fatalf("UpdateChannel: %v", err)
+	}
+	got, err = store.GetChannelByID(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetChannelByID: %v", err)
+	}
+	if got.VideoBitrate != "6M" {
+		t.Errorf("VideoBitrate = %q after update, want 6M", got.VideoBitrate)
+	}
+
+	channels, err := store.GetAllChannels(ctx)
+	if err != nil {
+		t.Fatalf("GetAllChannels: %v", err)
+	}
+	found := false
+	for _, c := range channels {
+		found = found || c.ChannelID == channel.ChannelID
+	}
+	if !found {
+		t.Errorf("GetAllChannels does not include channel %d", channel.ChannelID)
+	}
+
+	if _, err := store.GetChannelByID(ctx, -1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetChannelByID(-1) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testChannelState(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+
+	state, err := store.GetChannelState(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetChannelState: %v", err)
+	}
+	if state.Running || state.ChannelID != channel.ChannelID {
+		t.Errorf("GetChannelState before any update = %+v, want a stopped state", state)
+	}
+
+	state.Running = true
+	state.FFmpegPID = 1234
+	if err := store.UpdateChannelState(ctx, state); err != nil {
+		t.Fatalf("UpdateChannelState: %v", err)
+	}
+	state, err = store.GetChannelState(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetChannelState: %v", err)
+	}
+	if !state.Running || state.FFmpegPID != 1234 {
+		t.Errorf("GetChannelState = running %v pid %d, want running pid 1234", state.Running, state.FFmpegPID)
+	}
+}
+
+func testMediaFiles(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+
+	var ids []int
+	for i := 1; i <= 3; i++ {
+		file := &models.MediaFile{
+			ChannelID:       channel.ChannelID,
+			FilePath:        fmt.Sprintf("/media/%d.mp4", i),
+			FileName:        fmt.Sprintf("%d.mp4", i),
+			DurationSeconds: 60 * i,
+			LastModified:    time.Now().Truncate(time.Second),
+			ScannedAt:       time.Now().Truncate(time.Second),
+		}
+		if err := store.CreateMediaFile(ctx, file); err != nil {
+			t.Fatalf("CreateMediaFile: %v", err)
+		}
+		if file.MediaID == 0 {
+			t.Fatal("CreateMediaFile did not set the media ID")
+		}
+		ids = append(ids, file.MediaID)
+	}
+
+	exists, err := store.MediaFileExists(ctx, channel.ChannelID, "/media/2.mp4")
+	if err != nil || !exists {
+		t.Errorf("MediaFileExists = %v, %v, want true", exists, err)
+	}
+	exists, err = store.MediaFileExists(ctx, channel.ChannelID, "/media/4.mp4")
+	if err != nil || exists {
+		t.Errorf("MediaFileExists for a missing file = %v, %v, want false", exists, err)
+	}
+
+	count, err := store.CountMediaFiles(ctx, channel.ChannelID)
+	if err != nil || count != 3 {
+		t.Errorf("CountMediaFiles = %d, %v, want 3", count, err)
+	}
+	files, err := store.GetMediaFiles(ctx, channel.ChannelID, 2, 2)
+	if err != nil {
+		t.Fatalf("GetMediaFiles: %v", err)
+	}
+	if len(files) != 1 || files[0].MediaID != ids[2] {
+		t.Errorf("GetMediaFiles page 2 = %d files, want only media %d", len(files), ids[2])
+	}
+
+	if err := store.UpdateMediaLoudness(ctx, ids[0], -20.5, -2.25); err != nil {
+		t.Fatalf("UpdateMediaLoudness: %v", err)
+	}
+	file, err := store.GetMediaFile(ctx, sql.NullInt64{Int64: int64(ids[0]), Valid: true})
+	if err != nil {
+		t.Fatalf("GetMediaFile: %v", err)
+	}
+	if file.IntegratedLoudness.Float64 != -20.5 || file.TruePeak.Float64 != -2.25 {
+		t.Errorf("loudness = %v / %v, want -20.5 / -2.25", file.IntegratedLoudness, file.TruePeak)
+	}
+	unmeasured, err := store.GetUnmeasuredMediaFiles(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetUnmeasuredMediaFiles: %v", err)
+	}
+	if len(unmeasured) != 2 {
+		t.Errorf("GetUnmeasuredMediaFiles = %d files, want 2", len(unmeasured))
+	}
+
+	if _, err := store.GetMediaFile(ctx, sql.NullInt64{Int64: -1, Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetMediaFile(-1) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testPlaylists(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.Local)
+	undated := &models.Playlist{ChannelID: channel.ChannelID, PlaylistName: "loop", Status: "scheduled"}
+	dated := &models.Playlist{ChannelID: channel.ChannelID, PlaylistName: "pi day", PlaylistDate: &date, Status: "active"}
+	for _, playlist := range []*models.Playlist{undated, dated} {
+		if err := store.CreatePlaylist(ctx, playlist); err != nil {
+			t.Fatalf("CreatePlaylist: %v", err)
+		}
+		if playlist.PlaylistID == 0 {
+			t.Fatal("CreatePlaylist did not set the playlist ID")
+		}
+	}
+
+	got, err := store.GetPlaylistForDate(ctx, channel.ChannelID, date)
+	if err != nil {
+		t.Fatalf("GetPlaylistForDate: %v", err)
+	}
+	if got.PlaylistID != dated.PlaylistID {
+		t.Errorf("GetPlaylistForDate = playlist %d, want the dated playlist %d", got.PlaylistID, dated.PlaylistID)
+	}
+	got, err = store.GetPlaylistForDate(ctx, channel.ChannelID, date.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetPlaylistForDate: %v", err)
+	}
+	if got.PlaylistID != undated.PlaylistID {
+		t.Errorf("GetPlaylistForDate on another day = playlist %d, want the undated playlist %d", got.PlaylistID, undated.PlaylistID)
+	}
+
+	active, err := store.GetActivePlaylist(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetActivePlaylist: %v", err)
+	}
+	if active.PlaylistID != dated.PlaylistID {
+		t.Errorf("GetActivePlaylist = playlist %d, want %d", active.PlaylistID, dated.PlaylistID)
+	}
+
+	playlists, err := store.GetPlaylists(ctx, channel.ChannelID)
+	if err != nil || len(playlists) != 2 {
+		t.Errorf("GetPlaylists = %d playlists, %v, want 2", len(playlists), err)
+	}
+
+	if _, err := store.GetPlaylist(ctx, -1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPlaylist(-1) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testPlaylistItems(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+
+	playlist := &models.Playlist{ChannelID: channel.ChannelID, PlaylistName: "loop", Status: "active"}
+	if err := store.CreatePlaylist(ctx, playlist); err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	var items []*models.PlaylistItem
+	for position := 0; position < 3; position++ {
+		file := &models.MediaFile{
+			ChannelID:    channel.ChannelID,
+			FilePath:     fmt.Sprintf("/media/%d.mp4", position),
+			FileName:     fmt.Sprintf("%d.mp4", position),
+			LastModified: now,
+			ScannedAt:    now,
+		}
+		if err := store.CreateMediaFile(ctx, file); err != nil {
+			t.Fatalf("CreateMediaFile: %v", err)
+		}
+
+		start := now.Add(time.Duration(position-1) * time.Minute)
+		end := start.Add(time.Minute)
+		item := &models.PlaylistItem{
+			PlaylistID:         playlist.PlaylistID,
+			MediaID:            sql.NullInt64{Int64: int64(file.MediaID), Valid: true},
+			Type:               models.PlaylistItemTypeMedia,
+			Position:           position,
+			ScheduledStartTime: &start,
+			ScheduledEndTime:   &end,
+		}
+		if err := store.CreatePlaylistItem(ctx, item); err != nil {
+			t.Fatalf("CreatePlaylistItem: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	got, err := store.GetPlaylistItems(ctx, playlist.PlaylistID)
+	if err != nil {
+		t.Fatalf("GetPlaylistItems: %v", err)
+	}
+	if len(got) != 3 || got[0].ItemID != items[0].ItemID || got[2].ItemID != items[2].ItemID {
+		t.Fatalf("GetPlaylistItems returned %d items out of order", len(got))
+	}
+
+	// Nothing is playing, so the next item is the first unlocked one
+	current, next, err := store.GetCurrentAndNextPlaylistItems(ctx, playlist.PlaylistID)
+	if err != nil {
+		t.Fatalf("GetCurrentAndNextPlaylistItems: %v", err)
+	}
+	if current.ItemID != 0 || next.ItemID != items[0].ItemID {
+		t.Errorf("current, next = %d, %d, want none, %d", current.ItemID, next.ItemID, items[0].ItemID)
+	}
+
+	// The locked item scheduled around now is playing
+	if err := store.LockPlaylistItem(ctx, items[1].ItemID); err != nil {
+		t.Fatalf("LockPlaylistItem: %v", err)
+	}
+	current, next, err = store.GetCurrentAndNextPlaylistItems(ctx, playlist.PlaylistID)
+	if err != nil {
+		t.Fatalf("GetCurrentAndNextPlaylistItems: %v", err)
+	}
+	if current.ItemID != items[1].ItemID || next.ItemID != items[2].ItemID {
+		t.Errorf("current, next = %d, %d, want %d, %d", current.ItemID, next.ItemID, items[1].ItemID, items[2].ItemID)
+	}
+
+	if err := store.UnlockPlaylistItem(ctx, items[1].ItemID); err != nil {
+		t.Fatalf("UnlockPlaylistItem: %v", err)
+	}
+	current, _, err = store.GetCurrentAndNextPlaylistItems(ctx, playlist.PlaylistID)
+	if err != nil {
+		t.Fatalf("GetCurrentAndNextPlaylistItems: %v", err)
+	}
+	if current.ItemID != 0 {
+		t.Errorf("current = %d after unlocking, want none", current.ItemID)
+	}
+}
+
+func testOverlays(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+
+	var ids []int
+	for i, text := range []string{"first", "second", "third"} {
+		overlay := &models.Overlay{
+			ChannelID: channel.ChannelID,
+			Enabled:   i != 1,
+			Type:      "text",
+			Text:      text,
+			PositionX: "10",
+			PositionY: "10",
+			FontSize:  "24",
+			FontColor: "white",
+		}
+		if err := store.CreateOverlay(ctx, overlay); err != nil {
+			t.Fatalf("CreateOverlay: %v", err)
+		}
+		if overlay.OverlayID == 0 {
+			t.Fatal("CreateOverlay did not set the overlay ID")
+		}
+		ids = append(ids, overlay.OverlayID)
+	}
+
+	all, err := store.GetAllChannelOverlays(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetAllChannelOverlays: %v", err)
+	}
+	if len(all) != 3 || all[0].OverlayID != ids[0] || all[2].OverlayID != ids[2] {
+		t.Fatalf("GetAllChannelOverlays returned %d overlays out of creation order", len(all))
+	}
+	enabled, err := store.GetChannelOverlays(ctx, channel.ChannelID)
+	if err != nil || len(enabled) != 2 {
+		t.Errorf("GetChannelOverlays = %d overlays, %v, want 2", len(enabled), err)
+	}
+
+	if err := store.ReorderOverlays(ctx, channel.ChannelID, []int{ids[2], ids[0], ids[1]}); err != nil {
+		t.Fatalf("ReorderOverlays: %v", err)
+	}
+	all, err = store.GetAllChannelOverlays(ctx, channel.ChannelID)
+	if err != nil {
+		t.Fatalf("GetAllChannelOverlays: %v", err)
+	}
+	if all[0].OverlayID != ids[2] || all[1].OverlayID != ids[0] || all[2].OverlayID != ids[1] {
+		t.Errorf("GetAllChannelOverlays after reorder = %d %d %d", all[0].OverlayID, all[1].OverlayID, all[2].OverlayID)
+	}
+
+	overlay, err := store.GetOverlay(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("GetOverlay: %v", err)
+	}
+	overlay.Text = "changed"
+	if err := store.UpdateOverlay(ctx, overlay); err != nil {
+		t.Fatalf("UpdateOverlay: %v", err)
+	}
+	overlay, err = store.GetOverlay(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("GetOverlay: %v", err)
+	}
+	if overlay.Text != "changed" {
+		t.Errorf("Text = %q after update, want changed", overlay.Text)
+	}
+
+	if err := store.DeleteOverlay(ctx, ids[0]); err != nil {
+		t.Fatalf("DeleteOverlay: %v", err)
+	}
+	if _, err := store.GetOverlay(ctx, ids[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetOverlay after delete error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func createTestUser(t *testing.T, store Store) *models.User {
+	t.Helper()
+	user := &models.User{
+		Username:     fmt.Sprintf("user-%d", time.Now().UnixNano()),
+		PasswordHash: "hash",
+		Role:         models.RoleOperator,
+		IsActive:     true,
+	}
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.UserID == 0 {
+		t.Fatal("CreateUser did not set the user ID")
+	}
+	return user
+}
+
+func testUsers(t *testing.T, store Store) {
+	ctx := context.Background()
+	before, err := store.CountUsers(ctx)
+	if err != nil {
+		t.Fatalf("CountUsers: %v", err)
+	}
+	user := createTestUser(t, store)
+
+	if count, err := store.CountUsers(ctx); err != nil || count != before+1 {
+		t.Errorf("CountUsers = %d, %v, want %d", count, err, before+1)
+	}
+	duplicate := &models.User{Username: user.Username, PasswordHash: "hash", Role: models.RoleViewer}
+	if err := store.CreateUser(ctx, duplicate); err == nil {
+		t.Error("CreateUser accepted a duplicate username")
+	}
+
+	got, err := store.GetUserByUsername(ctx, user.Username)
+	if err != nil || got.UserID != user.UserID || got.Role != models.RoleOperator || !got.IsActive {
+		t.Fatalf("GetUserByUsername = %+v, %v", got, err)
+	}
+	if _, err := store.GetUserByUsername(ctx, user.Username+"-missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByUsername for a missing user error = %v, want sql.ErrNoRows", err)
+	}
+
+	keyHash := fmt.Sprintf("%064d", time.Now().UnixNano())
+	got.APIKey = sql.NullString{String: keyHash, Valid: true}
+	got.Role = models.RoleAdmin
+	if err := store.UpdateUser(ctx, got); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if byKey, err := store.GetUserByAPIKey(ctx, keyHash); err != nil || byKey.UserID != user.UserID || byKey.Role != models.RoleAdmin {
+		t.Errorf("GetUserByAPIKey = %+v, %v, want user %d as admin", byKey, err, user.UserID)
+	}
+	if _, err := store.GetUserByAPIKey(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByAPIKey for a missing key error = %v, want sql.ErrNoRows", err)
+	}
+	if err := store.UpdateUser(ctx, &models.User{UserID: user.UserID + 1000000, Username: "missing", Role: models.RoleViewer}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateUser for a missing user error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := store.UpdateUserLastLogin(ctx, user.UserID); err != nil {
+		t.Fatalf("UpdateUserLastLogin: %v", err)
+	}
+	if got, err = store.GetUser(ctx, user.UserID); err != nil || !got.LastLogin.Valid {
+		t.Errorf("GetUser after login = %+v, %v, want a last login", got, err)
+	}
+
+	users, err := store.GetUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	for i := 1; i < len(users); i++ {
+		if users[i-1].UserID >= users[i].UserID {
+			t.Fatalf("GetUsers is not ordered by ID")
+		}
+	}
+
+	if err := store.DeleteUser(ctx, user.UserID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := store.GetUser(ctx, user.UserID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUser after delete error = %v, want sql.ErrNoRows", err)
+	}
+	if err := store.DeleteUser(ctx, user.UserID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteUser twice error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testUserSessions(t *testing.T, store Store) {
+	ctx := context.Background()
+	user := createTestUser(t, store)
+	token := func(name string) string {
+		return fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	}
+
+	live, expired, other := token("live"), token("expired"), token("other")
+	for _, session := range []*models.UserSession{
+		{UserID: user.UserID, TokenHash: live, ExpiresAt: time.Now().Add(time.Hour)},
+		{UserID: user.UserID, TokenHash: expired, ExpiresAt: time.Now().Add(-time.Hour)},
+		{UserID: user.UserID, TokenHash: other, ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		if err := store.CreateUserSession(ctx, session); err != nil {
+			t.Fatalf("CreateUserSession: %v", err)
+		}
+	}
+
+	if got, err := store.GetSessionUser(ctx, live); err != nil || got.UserID != user.UserID {
+		t.Errorf("GetSessionUser = %+v, %v, want user %d", got, err, user.UserID)
+	}
+	if _, err := store.GetSessionUser(ctx, expired); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetSessionUser for an expired session error = %v, want sql.ErrNoRows", err)
+	}
+	if err := store.DeleteExpiredSessions(ctx); err != nil {
+		t.Fatalf("DeleteExpiredSessions: %v", err)
+	}
+
+	if err := store.DeleteUserSession(ctx, live); err != nil {
+		t.Fatalf("DeleteUserSession: %v", err)
+	}
+	if _, err := store.GetSessionUser(ctx, live); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetSessionUser after logout error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := store.GetSessionUser(ctx, other); err != nil {
+		t.Errorf("GetSessionUser for the user's other session: %v", err)
+	}
+
+	if err := store.DeleteUserSessions(ctx, user.UserID); err != nil {
+		t.Fatalf("DeleteUserSessions: %v", err)
+	}
+	if _, err := store.GetSessionUser(ctx, other); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetSessionUser after logging out everywhere error = %v, want sql.ErrNoRows", err)
+	}
+	if err := store.DeleteUser(ctx, user.UserID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+}
+
+func testAuditLogs(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+	user := createTestUser(t, store)
+	target := sql.NullInt64{Int64: int64(channel.ChannelID), Valid: true}
+
+	for _, entry := range []*models.AuditLog{
+		{UserID: sql.NullInt64{Int64: int64(user.UserID), Valid: true}, ActionType: "channel_update", TargetType: "channel", TargetID: target},
+		{ActionType: "channel_start", TargetType: "channel", TargetID: target},
+		{UserID: sql.NullInt64{Int64: int64(user.UserID), Valid: true}, ActionType: "channel_stop", TargetType: "channel", TargetID: target,
+			NewValue: sql.NullString{String: `{"running": false}`, Valid: true}},
+	} {
+		if err := store.CreateAuditLog(ctx, entry); err != nil {
+			t.Fatalf("CreateAuditLog: %v", err)
+		}
+	}
+
+	all := models.AuditFilter{TargetType: "channel", TargetID: channel.ChannelID, Limit: 10}
+	logs, err := store.GetAuditLogs(ctx, all)
+	if err != nil {
+		t.Fatalf("GetAuditLogs: %v", err)
+	}
+	if len(logs) != 3 || logs[0].ActionType != "channel_stop" || logs[2].ActionType != "channel_update" {
+		t.Fatalf("GetAuditLogs returned %d entries, want 3 newest first", len(logs))
+	}
+	if logs[0].NewValue.String != `{"running": false}` && logs[0].NewValue.String != `{"running":false}` {
+		t.Errorf("NewValue = %q", logs[0].NewValue.String)
+	}
+
+	filtered := func(change func(*models.AuditFilter)) []*models.AuditLog {
+		t.Helper()
+		filter := all
+		change(&filter)
+		logs, err := store.GetAuditLogs(ctx, filter)
+		if err != nil {
+			t.Fatalf("GetAuditLogs: %v", err)
+		}
+		return logs
+	}
+	if logs := filtered(func(f *models.AuditFilter) { f.UserID = user.UserID }); len(logs) != 2 {
+		t.Errorf("audit entries by user = %d, want 2", len(logs))
+	}
+	if logs := filtered(func(f *models.AuditFilter) { f.ActionType = "channel_start" }); len(logs) != 1 || logs[0].UserID.Valid {
+		t.Errorf("audit entries by action = %d, want the one system entry", len(logs))
+	}
+	if logs := filtered(func(f *models.AuditFilter) { f.Since = time.Now().Add(-24 * time.Hour) }); len(logs) != 3 {
+		t.Errorf("audit entries since yesterday = %d, want 3", len(logs))
+	}
+	if logs := filtered(func(f *models.AuditFilter) { f.Until = time.Now().Add(-24 * time.Hour) }); len(logs) != 0 {
+		t.Errorf("audit entries until yesterday = %d, want none", len(logs))
+	}
+	if logs := filtered(func(f *models.AuditFilter) { f.Limit, f.Offset = 1, 1 }); len(logs) != 1 || logs[0].ActionType != "channel_start" {
+		t.Errorf("second page of one audit entry = %d entries, want channel_start", len(logs))
+	}
+}
+
+func testAPIAccessLogs(t *testing.T, store Store) {
+	ctx := context.Background()
+	for _, path := range []string{"/api/v1/channels", "/api/v1/events"} {
+		entry := &models.APIAccessLog{Endpoint: path, Method: "GET", StatusCode: 200}
+		if err := store.CreateAPIAccessLog(ctx, entry); err != nil {
+			t.Fatalf("CreateAPIAccessLog: %v", err)
+		}
+	}
+
+	if deleted, err := store.DeleteAPIAccessLogsBefore(ctx, time.Now().Add(-time.Hour)); err != nil || deleted != 0 {
+		t.Errorf("DeleteAPIAccessLogsBefore an hour ago = %d, %v, want none deleted", deleted, err)
+	}
+	if deleted, err := store.DeleteAPIAccessLogsBefore(ctx, time.Now().Add(time.Hour)); err != nil || deleted < 2 {
+		t.Errorf("DeleteAPIAccessLogsBefore an hour ahead = %d, %v, want both entries deleted", deleted, err)
+	}
+}
+
+func testEventLogs(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+	channelID := sql.NullInt64{Int64: int64(channel.ChannelID), Valid: true}
+
+	// Far enough back that deleting old events leaves everyone else's
+	start := time.Date(2000, 1, 1, 6, 0, 0, 0, time.UTC)
+	for i, event := range []*models.EventLog{
+		{EventType: "info", EventName: "channel_started", EventCategory: "channel"},
+		{EventType: "warning", EventName: "stream_stalled", EventCategory: "ffmpeg"},
+		{EventType: "error", EventName: "ffmpeg_exited", EventCategory: "ffmpeg"},
+		{EventType: "info", EventName: "item_started", EventCategory: "playlist"},
+		{EventType: "info", EventName: "item_started", EventCategory: "playlist"},
+	} {
+		event.ChannelID = channelID
+		event.Message = event.EventName
+		event.CreatedAt = start.Add(time.Duration(i) * 24 * time.Hour)
+		if err := store.CreateEventLog(ctx, event); err != nil {
+			t.Fatalf("CreateEventLog: %v", err)
+		}
+	}
+
+	events := func(filter models.EventFilter) []*models.EventLog {
+		t.Helper()
+		filter.ChannelID = channel.ChannelID
+		if filter.Limit == 0 {
+			filter.Limit = 10
+		}
+		events, err := store.GetEventLogs(ctx, filter)
+		if err != nil {
+			t.Fatalf("GetEventLogs: %v", err)
+		}
+		return events
+	}
+
+	all := events(models.EventFilter{})
+	if len(all) != 5 || !all[0].CreatedAt.Equal(start.Add(4*24*time.Hour)) || all[4].EventName != "channel_started" {
+		t.Fatalf("GetEventLogs returned %d events, want 5 newest first", len(all))
+	}
+	tests := []struct {
+		name   string
+		filter models.EventFilter
+		want   int
+	}{
+		{"name", models.EventFilter{EventName: "item_started"}, 2},
+		{"type", models.EventFilter{EventType: "error"}, 1},
+		{"category", models.EventFilter{Category: "ffmpeg"}, 2},
+		{"since", models.EventFilter{Since: start.Add(3 * 24 * time.Hour)}, 2},
+		{"limit", models.EventFilter{Limit: 3}, 3},
+	}
+	for _, tt := range tests {
+		if got := events(tt.filter); len(got) != tt.want {
+			t.Errorf("events by %s = %d, want %d", tt.name, len(got), tt.want)
+		}
+	}
+
+	// Trimming keeps the newest
+	if deleted, err := store.TrimChannelEventLogs(ctx, channel.ChannelID, 3); err != nil || deleted != 2 {
+		t.Errorf("TrimChannelEventLogs = %d, %v, want 2 deleted", deleted, err)
+	}
+	if kept := events(models.EventFilter{}); len(kept) != 3 || kept[2].EventName != "ffmpeg_exited" {
+		t.Errorf("events after trimming = %d, want the newest 3", len(kept))
+	}
+
+	if deleted, err := store.DeleteEventLogsBefore(ctx, start.Add(3*24*time.Hour)); err != nil || deleted < 1 {
+		t.Errorf("DeleteEventLogsBefore = %d, %v, want the oldest kept event deleted", deleted, err)
+	}
+	if kept := events(models.EventFilter{}); len(kept) != 2 {
+		t.Errorf("events after deleting old ones = %d, want 2", len(kept))
+	}
+}
+
+func testAsRunLog(t *testing.T, store Store) {
+	ctx := context.Background()
+	channel := createTestChannel(t, store)
+
+	start := time.Date(2025, 3, 14, 6, 0, 0, 0, time.UTC)
+	first := &models.AsRunEntry{ChannelID: channel.ChannelID, PlaylistID: 1, ItemID: 10, StartedAt: start, StartOffset: 5}
+	slate := &models.AsRunEntry{ChannelID: channel.ChannelID, PlaylistID: 1, ItemID: 10, Slate: true, StartedAt: start.Add(time.Minute), StartOffset: 65}
+	for _, entry := range []*models.AsRunEntry{first, slate} {
+		if err := store.CreateAsRunEntry(ctx, entry); err != nil {
+			t.Fatalf("CreateAsRunEntry: %v", err)
+		}
+		if entry.AsRunID == 0 {
+			t.Fatal("CreateAsRunEntry did not set the entry ID")
+		}
+	}
+	if err := store.EndAsRunEntry(ctx, first.AsRunID, start.Add(time.Minute), 65, models.AsRunSlated); err != nil {
+		t.Fatalf("EndAsRunEntry: %v", err)
+	}
+
+	entries, err := store.GetAsRunLog(ctx, channel.ChannelID, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("GetAsRunLog: %v", err)
+	}
+	if len(entries) != 2 || entries[0].AsRunID != slate.AsRunID || entries[1].AsRunID != first.AsRunID {
+		t.Fatalf("GetAsRunLog returned %d entries, want 2 newest first", len(entries))
+	}
+	if !entries[0].Slate || entries[0].EndedAt.Valid {
+		t.Errorf("slate entry = %+v, want an open slate entry", entries[0])
+	}
+	ended := entries[1]
+	if ended.Slate || !ended.EndedAt.Time.Equal(start.Add(time.Minute)) || ended.EndPosition.Float64 != 65 || ended.EndReason.String != models.AsRunSlated {
+		t.Errorf("ended entry = %+v, want slated at 65s", ended)
+	}
+
+	if entries, err := store.GetAsRunLog(ctx, channel.ChannelID, start.Add(time.Second), 0); err != nil || len(entries) != 1 {
+		t.Errorf("GetAsRunLog since = %d entries, %v, want 1", len(entries), err)
+	}
+	if entries, err := store.GetAsRunLog(ctx, channel.ChannelID, time.Time{}, 1); err != nil || len(entries) != 1 || entries[0].AsRunID != slate.AsRunID {
+		t.Errorf("GetAsRunLog limited to 1 = %d entries, %v, want the newest", len(entries), err)
+	}
+}
+
+func testSettings(t *testing.T, store Store) {
+	ctx := context.Background()
+	key := fmt.Sprintf("test_setting_%d", time.Now().UnixNano())
+
+	if _, err := store.GetSystemSetting(ctx, key); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetSystemSetting for a missing key error = %v, want sql.ErrNoRows", err)
+	}
+	for _, value := range []string{"1", "2"} {
+		if err := store.SetSystemSetting(ctx, key, value); err != nil {
+			t.Fatalf("SetSystemSetting: %v", err)
+		}
+		got, err := store.GetSystemSetting(ctx, key)
+		if err != nil || got != value {
+			t.Errorf("GetSystemSetting = %q, %v, want %q", got, err, value)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/metrics"
+	"github.com/jmoiron/sqlx"
+)
+
+// timedDB records the latency of the queries made through it.
+type timedDB struct {
+	*sqlx.DB
+}
+
+func observeQuery(operation string, start time.Time) {
+	metrics.DBQueryDuration.Observe(time.Since(start).Seconds(), operation)
+}
+
+func (db *timedDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer observeQuery("get", time.Now())
+	return db.DB.GetContext(ctx, dest, query, args...)
+}
+
+func (db *timedDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer observeQuery("select", time.Now())
+	return db.DB.SelectContext(ctx, dest, query, args...)
+}
+
+func (db *timedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery("exec", time.Now())
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+func (db *timedDB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	defer observeQuery("exec", time.Now())
+	return db.DB.NamedExecContext(ctx, query, arg)
+}
+
+func (db *timedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery("get", time.Now())
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
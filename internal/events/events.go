@@ -0,0 +1,267 @@
+// Package events records what happens at runtime (channels starting and
+// stopping, items going on air, FFmpeg exits, scans) in the event_logs table.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// Severities, stored in the event_type column.
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+	SeveritySystem  = "system"
+)
+
+// Categories, stored in the event_category column.
+const (
+	CategoryChannel  = "channel"
+	CategoryPlaylist = "playlist"
+	CategoryMedia    = "media"
+	CategoryOverlay  = "overlay"
+	CategorySystem   = "system"
+)
+
+// Event types, stored in the event_name column.
+const (
+	ChannelStarted     = "channel_started"
+	ChannelStopped     = "channel_stopped"
+	ChannelFailed      = "channel_failed"
+	ChannelQueued      = "channel_queued"
+	ItemStarted        = "item_started"
+	PlayoutResumed     = "playout_resumed"
+	FFmpegExited       = "ffmpeg_exited"
+	PlaylistFallback   = "playlist_fallback"
+	PlaylistTransition = "playlist_transition"
+	ScanCompleted      = "scan_completed"
+	ScanFailed         = "scan_failed"
+	LoudnessMeasured   = "loudness_measured"
+	OverlayError       = "overlay_error"
+	StateUpdateFailed  = "state_update_failed"
+	StateReconciled    = "state_reconciled"
+	FFmpegOrphaned     = "ffmpeg_orphaned"
+	StreamStalled      = "stream_stalled"
+	OutputAlarm        = "output_alarm"
+	OutputAlarmCleared = "output_alarm_cleared"
+	PlayoutControl     = "playout_control"
+	SlateOn            = "slate_on"
+	SlateOff           = "slate_off"
+
+	// Position updates are only sent to subscribers, never stored
+	Position = "position"
+)
+
+// Event is something that happened, optionally on a channel.
+type Event struct {
+	ChannelID int                    `json:"channel_id,omitempty"`
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"`
+	Category  string                 `json:"category"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Time      time.Time              `json:"time"`
+}
+
+// eventStore is what the bus needs from the database.
+type eventStore interface {
+	database.ChannelStore
+	database.LogStore
+}
+
+// Bus records published events and passes them on to live subscribers.
+// Events are written by a background goroutine so publishing never blocks
+// playout on the database. A nil *Bus only writes events to the process log.
+type Bus struct {
+	repo     eventStore
+	settings *config.Settings
+	queue    chan Event
+
+	subscribers map[chan Event]struct{}
+	subMux      sync.RWMutex
+}
+
+func NewBus(repo eventStore, settings *config.Settings) *Bus {
+	return &Bus{
+		repo:        repo,
+		settings:    settings,
+		queue:       make(chan Event, 1024),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish records an event.
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Severity == "" {
+		event.Severity = SeverityInfo
+	}
+
+	if event.ChannelID != 0 {
+		log.Printf("[%s] channel %d: %s", event.Severity, event.ChannelID, event.Message)
+	} else {
+		log.Printf("[%s] %s", event.Severity, event.Message)
+	}
+
+	if b == nil {
+		return
+	}
+
+	select {
+	case b.queue <- event:
+	default:
+		log.Printf("Event queue full, dropping %s event", event.Type)
+	}
+
+	b.Notify(event)
+}
+
+// Notify passes an event to live subscribers only, without recording it.
+// It is meant for frequent updates such as stream positions.
+func (b *Bus) Notify(event Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.subMux.RLock()
+	defer b.subMux.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// The subscriber is not keeping up; it misses this event
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// and a function that ends the subscription.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 256)
+
+	b.subMux.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.subMux.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.subMux.Lock()
+			delete(b.subscribers, ch)
+			b.subMux.Unlock()
+		})
+	}
+}
+
+// Run writes queued events to the database and applies the retention
+// settings until the context is cancelled.
+func (b *Bus) Run(ctx context.Context) {
+	retention := time.NewTicker(time.Hour)
+	defer retention.Stop()
+
+	b.applyRetention(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.drain()
+			return
+		case event := <-b.queue:
+			b.write(ctx, event)
+		case <-retention.C:
+			b.applyRetention(ctx)
+		}
+	}
+}
+
+// drain writes whatever is still queued, so events published during
+// shutdown are not lost.
+func (b *Bus) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for {
+		select {
+		case event := <-b.queue:
+			b.write(ctx, event)
+		default:
+			return
+		}
+	}
+}
+
+func (b *Bus) write(ctx context.Context, event Event) {
+	entry := &models.EventLog{
+		EventType:     event.Severity,
+		EventName:     event.Type,
+		EventCategory: event.Category,
+		Message:       event.Message,
+		CreatedAt:     event.Time,
+	}
+	if event.ChannelID != 0 {
+		entry.ChannelID = sql.NullInt64{Int64: int64(event.ChannelID), Valid: true}
+	}
+	if len(event.Details) > 0 {
+		if data, err := json.Marshal(event.Details); err == nil {
+			entry.Details = sql.NullString{String: string(data), Valid: true}
+		}
+	}
+
+	if err := b.repo.CreateEventLog(ctx, entry); err != nil {
+		log.Printf("Failed to record %s event: %v", event.Type, err)
+	}
+}
+
+// applyRetention deletes events and API access log entries older than
+// log_retention_days and trims each channel to max_log_entries_per_channel.
+func (b *Bus) applyRetention(ctx context.Context) {
+	days := b.settings.Int("log_retention_days")
+	if days > 0 {
+		before := time.Now().AddDate(0, 0, -days)
+		if _, err := b.repo.DeleteEventLogsBefore(ctx, before); err != nil {
+			log.Printf("Failed to apply event retention: %v", err)
+		}
+		if _, err := b.repo.DeleteAPIAccessLogsBefore(ctx, before); err != nil {
+			log.Printf("Failed to apply access log retention: %v", err)
+		}
+	}
+
+	maxEntries := b.settings.Int("max_log_entries_per_channel")
+	if maxEntries <= 0 {
+		return
+	}
+
+	channels, err := b.repo.GetAllChannels(ctx)
+	if err != nil {
+		log.Printf("Failed to get channels for event retention: %v", err)
+		return
+	}
+	for _, channel := range channels {
+		if _, err := b.repo.TrimChannelEventLogs(ctx, channel.ChannelID, maxEntries); err != nil {
+			log.Printf("Failed to apply event retention: %v", err)
+		}
+	}
+}
+
+// Query returns recorded events matching the filter, newest first.
+func (b *Bus) Query(ctx context.Context, filter models.EventFilter) ([]*models.EventLog, error) {
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+	return b.repo.GetEventLogs(ctx, filter)
+}
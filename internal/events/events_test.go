@@ -0,0 +1,187 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func newTestBus(t *testing.T, settings map[string]string) (*Bus, *database.MemoryStore) {
+	t.Helper()
+	store := database.NewMemoryStore()
+	s := config.DefaultSettings()
+	if err := s.Update(context.Background(), store, settings); err != nil {
+		t.Fatal(err)
+	}
+	return NewBus(store, s), store
+}
+
+func addChannel(t *testing.T, store *database.MemoryStore, name string) int {
+	t.Helper()
+	channel := &models.Channel{ChannelName: name, PlaylistType: "daily_playlist", StartTimeStr: "06:00:00"}
+	if err := store.UpdateChannel(context.Background(), channel); err != nil {
+		t.Fatal(err)
+	}
+	return channel.ChannelID
+}
+
+func addEventLog(t *testing.T, store *database.MemoryStore, channelID int, name string, createdAt time.Time) {
+	t.Helper()
+	entry := &models.EventLog{
+		ChannelID: sql.NullInt64{Int64: int64(channelID), Valid: channelID != 0},
+		EventType: SeverityInfo,
+		EventName: name,
+		Message:   name,
+		CreatedAt: createdAt,
+	}
+	if err := store.CreateEventLog(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	bus, store := newTestBus(t, map[string]string{"log_retention_days": "7", "max_log_entries_per_channel": "3"})
+	busy, quiet := addChannel(t, store, "busy"), addChannel(t, store, "quiet")
+	now := time.Now()
+
+	addEventLog(t, store, 0, "old system", now.AddDate(0, 0, -8))
+	addEventLog(t, store, quiet, "old quiet", now.AddDate(0, 0, -8))
+	addEventLog(t, store, 0, "recent system", now.AddDate(0, 0, -6))
+	addEventLog(t, store, quiet, "recent quiet", now.AddDate(0, 0, -6))
+	for i := 5; i > 0; i-- {
+		addEventLog(t, store, busy, "busy", now.Add(-time.Duration(i)*time.Minute))
+	}
+
+	bus.applyRetention(ctx)
+
+	tests := []struct {
+		channelID int
+		want      []string
+	}{
+		{0, []string{"busy", "busy", "busy", "recent quiet", "recent system"}},
+		{busy, []string{"busy", "busy", "busy"}},
+		{quiet, []string{"recent quiet"}},
+	}
+	for _, tt := range tests {
+		logs, err := bus.Query(ctx, models.EventFilter{ChannelID: tt.channelID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range logs {
+			got = append(got, entry.Message)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("channel %d kept %q, want %q", tt.channelID, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("channel %d kept %q, want %q", tt.channelID, got, tt.want)
+				break
+			}
+		}
+	}
+
+	// Only the newest of the busy channel's events are kept
+	logs, err := bus.Query(ctx, models.EventFilter{ChannelID: busy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldest := logs[len(logs)-1].CreatedAt; !oldest.Equal(now.Add(-3 * time.Minute)) {
+		t.Errorf("oldest busy event kept is from %v, want %v", oldest, now.Add(-3*time.Minute))
+	}
+}
+
+// accessLogStore records where the bus cuts off the API access log.
+type accessLogStore struct {
+	*database.MemoryStore
+	before time.Time
+}
+
+func (s *accessLogStore) DeleteAPIAccessLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.before = before
+	return s.MemoryStore.DeleteAPIAccessLogsBefore(ctx, before)
+}
+
+func TestApplyRetentionToAccessLogs(t *testing.T) {
+	bus, store := newTestBus(t, map[string]string{"log_retention_days": "7"})
+	recorder := &accessLogStore{MemoryStore: store}
+	bus.repo = recorder
+
+	bus.applyRetention(context.Background())
+
+	want := time.Now().AddDate(0, 0, -7)
+	if diff := want.Sub(recorder.before); diff < 0 || diff > time.Minute {
+		t.Errorf("access logs cut off at %v, want %v", recorder.before, want)
+	}
+}
+
+func TestRunDrainsQueueOnShutdown(t *testing.T) {
+	bus, store := newTestBus(t, nil)
+	channelID := addChannel(t, store, "test")
+
+	for i := 0; i < 10; i++ {
+		bus.Publish(Event{ChannelID: channelID, Type: ItemStarted, Message: "queued"})
+	}
+
+	// Shutting down before the writer gets to the queue still records it all
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus.Run(ctx)
+
+	logs, err := store.GetEventLogs(context.Background(), models.EventFilter{ChannelID: channelID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 10 {
+		t.Errorf("recorded %d events, want 10", len(logs))
+	}
+}
+
+func TestQuery(t *testing.T) {
+	bus, store := newTestBus(t, nil)
+	first, second := addChannel(t, store, "first"), addChannel(t, store, "second")
+	now := time.Now()
+
+	addEventLog(t, store, first, ItemStarted, now.Add(-2*time.Hour))
+	addEventLog(t, store, first, FFmpegExited, now.Add(-time.Hour))
+	addEventLog(t, store, second, ItemStarted, now.Add(-time.Minute))
+	addEventLog(t, store, 0, ScanCompleted, now)
+
+	tests := []struct {
+		name   string
+		filter models.EventFilter
+		want   int
+	}{
+		{"everything", models.EventFilter{}, 4},
+		{"channel", models.EventFilter{ChannelID: first}, 2},
+		{"type", models.EventFilter{EventName: ItemStarted}, 2},
+		{"channel and type", models.EventFilter{ChannelID: first, EventName: ItemStarted}, 1},
+		{"since", models.EventFilter{Since: now.Add(-90 * time.Minute)}, 3},
+		{"limit", models.EventFilter{Limit: 2}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := bus.Query(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != tt.want {
+				t.Errorf("Query returned %d events, want %d", len(logs), tt.want)
+			}
+			for i := 1; i < len(logs); i++ {
+				if logs[i].CreatedAt.After(logs[i-1].CreatedAt) {
+					t.Errorf("event %d is newer than event %d", i, i-1)
+				}
+			}
+		})
+	}
+}
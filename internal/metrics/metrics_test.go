@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	counter := NewCounter("test_requests_total", "Requests served.", "method", "code")
+	counter.Inc("GET", "200")
+	counter.Add(2.5, "GET", "200")
+	counter.Inc("POST", "500")
+
+	gauge := NewGauge("test_temperature", "Current temperature.")
+	gauge.Set(21.5)
+	gauge.Set(-3)
+
+	tests := []struct {
+		name   string
+		metric metric
+		want   string
+	}{
+		{"counter", counter, `# HELP test_requests_total Requests served.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",code="200"} 3.5
+test_requests_total{method="POST",code="500"} 1
+`},
+		{"gauge without labels", gauge, `# HELP test_temperature Current temperature.
+# TYPE test_temperature gauge
+test_temperature -3
+`},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		tt.metric.write(&out)
+		if out.String() != tt.want {
+			t.Errorf("%s wrote\n%s\nwant\n%s", tt.name, out.String(), tt.want)
+		}
+	}
+
+	counter.Delete("POST", "500")
+	var out strings.Builder
+	counter.write(&out)
+	if strings.Contains(out.String(), "POST") {
+		t.Errorf("deleted series still written:\n%s", out.String())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "op")
+	for _, value := range []float64{0.05, 0.1, 0.5, 2} {
+		h.Observe(value, "read")
+	}
+
+	want := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="read",le="0.1"} 2
+test_duration_seconds_bucket{op="read",le="1"} 3
+test_duration_seconds_bucket{op="read",le="+Inf"} 4
+test_duration_seconds_sum{op="read"} 2.65
+test_duration_seconds_count{op="read"} 4
+`
+	var out strings.Builder
+	h.write(&out)
+	if out.String() != want {
+		t.Errorf("histogram wrote\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestLabelValueQuoting(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"news", `"news"`},
+		{`C:\media`, `"C:\\media"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"two\nlines", `"two\nlines"`},
+		{"tab\there\r\x7f", `"tabhere"`},
+		{"Télé 1", `"Télé 1"`},
+		{"bad \xff byte", "\"bad \uFFFD byte\""},
+	}
+	for _, tt := range tests {
+		if got := quoteLabel(tt.value); got != tt.want {
+			t.Errorf("quoteLabel(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{0.0025, "0.0025"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatValue(tt.value); got != tt.want {
+			t.Errorf("formatValue(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTextSortsMetrics(t *testing.T) {
+	NewGauge("test_zz_last", "Last.").Set(1)
+	NewGauge("test_aa_first", "First.").Set(1)
+
+	var out strings.Builder
+	WriteText(&out)
+	text := out.String()
+	first, last := strings.Index(text, "# HELP test_aa_first"), strings.Index(text, "# HELP test_zz_last")
+	if first < 0 || last < 0 || first > last {
+		t.Errorf("WriteText did not write metrics in name order:\n%s", text)
+	}
+	if !strings.Contains(text, "# TYPE tvheadend_channel_running gauge\n") {
+		t.Error("WriteText left out the registered tvheadend metrics")
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Set with a missing label value did not panic")
+		}
+	}()
+	NewGauge("test_labelled", "Labelled.", "channel").Set(1)
+}
@@ -0,0 +1,34 @@
+package metrics
+
+var (
+	ChannelRunning = NewGauge("tvheadend_channel_running",
+		"Whether the channel is on air (1) or not (0).", "channel")
+	ChannelPosition = NewGauge("tvheadend_channel_position_seconds",
+		"Position within the item on air.", "channel")
+	ItemTransitions = NewCounter("tvheadend_item_transitions_total",
+		"Playlist items started.", "channel")
+	FFmpegRestarts = NewCounter("tvheadend_ffmpeg_restarts_total",
+		"FFmpeg processes restarted for the item already on air.", "channel")
+	FFmpegFailures = NewCounter("tvheadend_ffmpeg_failures_total",
+		"FFmpeg processes that exited with a non-zero code.", "channel")
+
+	EncodeFPS = NewGauge("tvheadend_encode_fps",
+		"Frames per second encoded by FFmpeg.", "channel")
+	EncodeSpeed = NewGauge("tvheadend_encode_speed_ratio",
+		"Encoding speed relative to real time.", "channel")
+	EncodeBitrate = NewGauge("tvheadend_encode_bitrate_bits_per_second",
+		"Output bitrate reported by FFmpeg.", "channel")
+	FramesDropped = NewGauge("tvheadend_encode_dropped_frames",
+		"Frames dropped by the current FFmpeg process.", "channel")
+	FramesDuplicated = NewGauge("tvheadend_encode_duplicated_frames",
+		"Frames duplicated by the current FFmpeg process.", "channel")
+
+	DBQueryDuration = NewHistogram("tvheadend_db_query_duration_seconds",
+		"Database query latency.",
+		[]float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+		"operation")
+	MediaScanDuration = NewHistogram("tvheadend_media_scan_duration_seconds",
+		"Duration of media directory scans.",
+		[]float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+		"channel")
+)
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AsRunEntry records an item, or the slate in its place, as it actually
+// went to air.
+type AsRunEntry struct {
+	AsRunID     int             `json:"as_run_id" db:"as_run_id"`
+	ChannelID   int             `json:"channel_id" db:"channel_id"`
+	PlaylistID  int             `json:"playlist_id" db:"playlist_id"`
+	ItemID      int             `json:"item_id" db:"item_id"`
+	Slate       bool            `json:"slate" db:"slate"` // The slate, with the item paused
+	StartedAt   time.Time       `json:"started_at" db:"started_at"`
+	StartOffset float64         `json:"start_offset" db:"start_offset"` // Seconds into the item
+	EndedAt     sql.NullTime    `json:"ended_at" db:"ended_at"`         // NULL while on air
+	EndPosition sql.NullFloat64 `json:"end_position" db:"end_position"` // Seconds into the item
+	EndReason   sql.NullString  `json:"end_reason" db:"end_reason"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Why an item left the air.
+const (
+	AsRunCompleted = "completed"
+	AsRunSkipped   = "skipped"
+	AsRunRestarted = "restarted"
+	AsRunSeeked    = "seeked"
+	AsRunJumped    = "jumped"
+	AsRunSlated    = "slated"
+	AsRunUnslated  = "unslated"
+	AsRunStopped   = "stopped"
+	AsRunFailed    = "failed"
+)
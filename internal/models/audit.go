@@ -0,0 +1,43 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type AuditLog struct {
+	AuditID    int            `json:"audit_id" db:"audit_id"`
+	UserID     sql.NullInt64  `json:"user_id" db:"user_id"`
+	ActionType string         `json:"action_type" db:"action_type"`
+	TargetType string         `json:"target_type" db:"target_type"`
+	TargetID   sql.NullInt64  `json:"target_id" db:"target_id"`
+	OldValue   sql.NullString `json:"old_value" db:"old_value"`
+	NewValue   sql.NullString `json:"new_value" db:"new_value"`
+	IPAddress  sql.NullString `json:"ip_address" db:"ip_address"`
+	ActionTime time.Time      `json:"action_time" db:"action_time"`
+}
+
+// AuditFilter selects audit entries. Zero values match everything.
+type AuditFilter struct {
+	UserID     int
+	ActionType string
+	TargetType string
+	TargetID   int
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+type APIAccessLog struct {
+	LogID         int            `json:"log_id" db:"log_id"`
+	UserID        sql.NullInt64  `json:"user_id" db:"user_id"`
+	Endpoint      string         `json:"endpoint" db:"endpoint"`
+	Method        string         `json:"method" db:"method"`
+	StatusCode    int            `json:"status_code" db:"status_code"`
+	IPAddress     sql.NullString `json:"ip_address" db:"ip_address"`
+	UserAgent     sql.NullString `json:"user_agent" db:"user_agent"`
+	RequestTime   time.Time      `json:"request_time" db:"request_time"`
+	DurationMs    int            `json:"duration_ms" db:"duration_ms"`
+	RequestParams sql.NullString `json:"request_params" db:"request_params"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type EventLog struct {
+	EventID       int            `json:"event_id" db:"event_id"`
+	ChannelID     sql.NullInt64  `json:"channel_id" db:"channel_id"`
+	EventType     string         `json:"event_type" db:"event_type"` // info, warning, error or system
+	EventName     string         `json:"event_name" db:"event_name"`
+	EventCategory string         `json:"event_category" db:"event_category"`
+	Message       string         `json:"message" db:"message"`
+	Details       sql.NullString `json:"details" db:"details"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+}
+
+// EventFilter selects event log entries. Zero values match everything.
+type EventFilter struct {
+	ChannelID int
+	EventName string
+	EventType string
+	Category  string
+	Since     time.Time
+	Limit     int
+}
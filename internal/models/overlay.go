@@ -6,6 +6,7 @@ type Overlay struct {
 	OverlayID int       `json:"id" db:"id"`
 	ChannelID int       `json:"channel_id" db:"channel_id"`
 	Enabled   bool      `json:"enabled" db:"enabled"`
+	ZIndex    int       `json:"z_index" db:"z_index"` // Stacking order; higher is drawn on top
 	Type      string    `json:"type" db:"type"`
 	FilePath  string    `json:"file_path" db:"file_path"`
 	Text      string    `json:"text" db:"text"`
@@ -13,7 +14,35 @@ type Overlay struct {
 	PositionY string    `json:"position_y" db:"position_y"`
 	FontSize  string    `json:"font_size" db:"font_size"`
 	FontColor string    `json:"font_color" db:"font_color"`
+	FontFile  string    `json:"font_file" db:"font_file"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
-	FontFile  string    `json:"font_file" db:"-"`
+	TextFile  string    `json:"-" db:"-"` // When set, drawtext reloads the text from this file every frame
+
+	// Ticker settings
+	BackgroundColor string `json:"bg_color" db:"bg_color"`
+	Speed           int    `json:"speed" db:"speed"`                 // Scroll speed in pixels per second
+	FeedURL         string `json:"feed_url" db:"feed_url"`           // RSS/Atom/JSON source polled for headlines
+	FeedInterval    int    `json:"feed_interval" db:"feed_interval"` // Seconds between feed polls
+
+	// Activation rules; empty values place no restriction
+	ActiveFrom      string     `json:"active_from" db:"active_from"`             // Time of day, HH:MM[:SS]
+	ActiveUntil     string     `json:"active_until" db:"active_until"`           // Time of day, HH:MM[:SS]
+	DaysOfWeek      string     `json:"days_of_week" db:"days_of_week"`           // e.g. "mon,tue,wed"
+	StartDate       *time.Time `json:"start_date" db:"start_date"`               // First day shown
+	EndDate         *time.Time `json:"end_date" db:"end_date"`                   // Last day shown
+	MediaTags       string     `json:"media_tags" db:"media_tags"`               // Only on media with one of these tags
+	PlaylistItemIDs string     `json:"playlist_item_ids" db:"playlist_item_ids"` // Only on these playlist items
+	HideDuringAds   bool       `json:"hide_during_ads" db:"hide_during_ads"`
+	Enable          string     `json:"-" db:"-"` // FFmpeg timeline expression limiting when the overlay is drawn
+}
+
+// TickerHeadline is one entry in the crawl of a ticker overlay.
+type TickerHeadline struct {
+	HeadlineID int       `json:"headline_id" db:"headline_id"`
+	OverlayID  int       `json:"overlay_id" db:"overlay_id"`
+	Position   int       `json:"position" db:"position"`
+	Text       string    `json:"text" db:"text"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -10,6 +10,7 @@ type ChannelState struct {
 	CurrentPosition   float64   `json:"current_position" db:"current_position"`
 	Running           bool      `json:"running" db:"running"`
 	FFmpegPID         int       `json:"ffmpeg_pid" db:"ffmpeg_pid"`
+	OnSlate           bool      `json:"on_slate" db:"on_slate"` // The emergency slate is on air instead of the item
 	LastUpdateTime    time.Time `json:"last_update_time" db:"last_update_time"`
 	ErrorMessage      *string   `json:"error_message" db:"error_message"`
 	CreatedAt         time.Time `json:"created_at" db:"created_at"`
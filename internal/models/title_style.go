@@ -0,0 +1,33 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Language struct {
+	LanguageID   int       `json:"language_id" db:"language_id"`
+	LanguageCode string    `json:"language_code" db:"language_code"`
+	LanguageName string    `json:"language_name" db:"language_name"`
+	FontFile     string    `json:"font_file" db:"font_file"`
+	NowPrefix    string    `json:"now_prefix" db:"now_prefix"`
+	NextPrefix   string    `json:"next_prefix" db:"next_prefix"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TitleStyle controls how the program title is drawn on a channel. A style
+// without a language is the channel default.
+type TitleStyle struct {
+	StyleID    int           `json:"id" db:"id"`
+	ChannelID  int           `json:"channel_id" db:"channel_id"`
+	LanguageID sql.NullInt64 `json:"language_id" db:"language_id"`
+	Enabled    bool          `json:"enabled" db:"enabled"`
+	FontFile   string        `json:"font_file" db:"font_file"` // Empty uses the language's font
+	FontSize   string        `json:"font_size" db:"font_size"`
+	FontColor  string        `json:"font_color" db:"font_color"`
+	PositionX  string        `json:"position_x" db:"position_x"`
+	PositionY  string        `json:"position_y" db:"position_y"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// roleLevels orders the roles; each role can do everything the roles below it can.
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAllows reports whether a user with the given role may do what the
+// required role may do.
+func RoleAllows(role, required string) bool {
+	return roleLevels[role] >= roleLevels[required] && roleLevels[role] > 0
+}
+
+type User struct {
+	UserID       int            `json:"user_id" db:"user_id"`
+	Username     string         `json:"username" db:"username"`
+	PasswordHash string         `json:"-" db:"password_hash"`
+	Email        sql.NullString `json:"email" db:"email"`
+	Role         string         `json:"role" db:"role"`
+	APIKey       sql.NullString `json:"-" db:"api_key"` // SHA-256 of the key
+	IsActive     bool           `json:"is_active" db:"is_active"`
+	LastLogin    sql.NullTime   `json:"last_login" db:"last_login"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+type UserSession struct {
+	SessionID int            `json:"session_id" db:"session_id"`
+	UserID    int            `json:"user_id" db:"user_id"`
+	TokenHash string         `json:"-" db:"token_hash"`
+	IPAddress sql.NullString `json:"ip_address" db:"ip_address"`
+	ExpiresAt time.Time      `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,22 @@
+package services
+
+import "context"
+
+// Actor identifies who triggered a change, for the audit trail.
+type Actor struct {
+	UserID    int
+	IPAddress string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that carries the given actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, if any.
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+)
+
+// ErrChannelQueued is returned when a channel cannot start for lack of
+// encoder slots. It starts by itself once one is free.
+var ErrChannelQueued = errors.New("channel queued until an encoder slot is free")
+
+// Capacity reports how many channels can run at once and which are waiting.
+type Capacity struct {
+	MaxInstances        int             `json:"max_ffmpeg_instances"` // 0 for no limit
+	GPUMemoryLimit      int             `json:"gpu_memory_limit_mb"`  // 0 for no limit
+	GPUMemoryPerChannel int             `json:"gpu_memory_per_channel_mb"`
+	Slots               int             `json:"slots"` // -1 for no limit
+	Used                int             `json:"used"`
+	Available           int             `json:"available"` // -1 for no limit
+	GPUMemoryUsed       int             `json:"gpu_memory_used_mb"`
+	Running             []int           `json:"running"`
+	Queued              []QueuedChannel `json:"queued"`
+}
+
+// QueuedChannel is a channel waiting for an encoder slot.
+type QueuedChannel struct {
+	ChannelID int       `json:"channel_id"`
+	Priority  int       `json:"priority"`
+	QueuedAt  time.Time `json:"queued_at"`
+}
+
+// admission hands out encoder slots. Every running channel holds one FFmpeg
+// process and gpu_memory_per_channel of GPU memory, so the number of slots
+// is the lower of max_ffmpeg_instances and what fits in gpu_memory_limit.
+// Channels that find no free slot wait in a queue ordered by priority, then
+// by when they asked. Running channels are never preempted.
+type admission struct {
+	settings *config.Settings
+	mux      sync.Mutex
+	running  map[int]bool
+	queue    []QueuedChannel
+}
+
+func newAdmission(settings *config.Settings) *admission {
+	return &admission{
+		settings: settings,
+		running:  make(map[int]bool),
+	}
+}
+
+// slots returns the number of channels that may run, or -1 for no limit.
+func (a *admission) slots() int {
+	slots := -1
+	if instances := a.settings.Int("max_ffmpeg_instances"); instances > 0 {
+		slots = instances
+	}
+	limit, perChannel := a.settings.Int("gpu_memory_limit"), a.settings.Int("gpu_memory_per_channel")
+	if limit > 0 && perChannel > 0 {
+		if fit := limit / perChannel; slots < 0 || fit < slots {
+			slots = fit
+		}
+	}
+	return slots
+}
+
+// acquire takes a slot for a channel, or queues it and returns false. Slots
+// go to waiting channels of the same or a higher priority first.
+func (a *admission) acquire(channelID, priority int, now time.Time) bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.running[channelID] {
+		return true
+	}
+	slots := a.slots()
+	if slots < 0 || a.ahead(channelID, priority) < slots-len(a.running) {
+		a.remove(channelID)
+		a.running[channelID] = true
+		return true
+	}
+
+	if !a.queued(channelID) {
+		a.queue = append(a.queue, QueuedChannel{ChannelID: channelID, Priority: priority, QueuedAt: now})
+		sort.SliceStable(a.queue, func(i, j int) bool {
+			return a.queue[i].Priority > a.queue[j].Priority
+		})
+	}
+	return false
+}
+
+// release frees a channel's slot, or takes it out of the queue, and returns
+// the channels that may now start.
+func (a *admission) release(channelID int) []int {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if !a.running[channelID] && !a.queued(channelID) {
+		return nil
+	}
+	delete(a.running, channelID)
+	a.remove(channelID)
+	return a.admissible()
+}
+
+// cancel takes a channel out of the queue and reports whether it was queued.
+func (a *admission) cancel(channelID int) bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if !a.queued(channelID) {
+		return false
+	}
+	a.remove(channelID)
+	return true
+}
+
+// admissible returns the queued channels for which there are free slots,
+// first in line first. The caller must hold mux.
+func (a *admission) admissible() []int {
+	free := len(a.queue)
+	if slots := a.slots(); slots >= 0 {
+		free = slots - len(a.running)
+	}
+
+	var channelIDs []int
+	for i := 0; i < free && i < len(a.queue); i++ {
+		channelIDs = append(channelIDs, a.queue[i].ChannelID)
+	}
+	return channelIDs
+}
+
+// next returns the queued channels that may start, e.g. after the limits
+// were raised.
+func (a *admission) next() []int {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.admissible()
+}
+
+// ahead returns how many queued channels come before a channel of the
+// given priority that is not queued yet, or before it if it is. The caller
+// must hold mux.
+func (a *admission) ahead(channelID, priority int) int {
+	for i, queued := range a.queue {
+		if queued.ChannelID == channelID {
+			return i
+		}
+	}
+	n := 0
+	for _, queued := range a.queue {
+		if queued.Priority >= priority {
+			n++
+		}
+	}
+	return n
+}
+
+// queued reports whether a channel is waiting. The caller must hold mux.
+func (a *admission) queued(channelID int) bool {
+	for _, queued := range a.queue {
+		if queued.ChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// remove takes a channel out of the queue. The caller must hold mux.
+func (a *admission) remove(channelID int) {
+	for i, queued := range a.queue {
+		if queued.ChannelID == channelID {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (a *admission) capacity() Capacity {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	c := Capacity{
+		MaxInstances:        a.settings.Int("max_ffmpeg_instances"),
+		GPUMemoryLimit:      a.settings.Int("gpu_memory_limit"),
+		GPUMemoryPerChannel: a.settings.Int("gpu_memory_per_channel"),
+		Slots:               a.slots(),
+		Used:                len(a.running),
+		Available:           -1,
+		Running:             make([]int, 0, len(a.running)),
+		Queued:              append([]QueuedChannel{}, a.queue...),
+	}
+	c.GPUMemoryUsed = c.Used * c.GPUMemoryPerChannel
+	if c.Slots >= 0 {
+		c.Available = max(c.Slots-c.Used, 0)
+	}
+	for channelID := range a.running {
+		c.Running = append(c.Running, channelID)
+	}
+	sort.Ints(c.Running)
+	return c
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/internal/database"
+)
+
+func newTestAdmission(t *testing.T, settings map[string]string) *admission {
+	t.Helper()
+	s := config.DefaultSettings()
+	if err := s.Update(context.Background(), database.NewMemoryStore(), settings); err != nil {
+		t.Fatal(err)
+	}
+	return newAdmission(s)
+}
+
+func TestAdmissionSlots(t *testing.T) {
+	tests := []struct {
+		instances, gpuLimit, perChannel string
+		want                            int
+	}{
+		{"8", "4096", "512", 8},
+		{"8", "2048", "512", 4},
+		{"2", "4096", "512", 2},
+		{"0", "1000", "300", 3},
+		{"0", "0", "512", -1},
+	}
+	for _, test := range tests {
+		a := newTestAdmission(t, map[string]string{
+			"max_ffmpeg_instances":   test.instances,
+			"gpu_memory_limit":       test.gpuLimit,
+			"gpu_memory_per_channel": test.perChannel,
+		})
+		if got := a.slots(); got != test.want {
+			t.Errorf("slots with %s instances and %s/%s MB = %d, want %d",
+				test.instances, test.gpuLimit, test.perChannel, got, test.want)
+		}
+	}
+}
+
+func TestAdmissionQueuesByPriority(t *testing.T) {
+	a := newTestAdmission(t, map[string]string{"max_ffmpeg_instances": "2"})
+	now := time.Now()
+
+	if !a.acquire(1, 0, now) || !a.acquire(2, 0, now) {
+		t.Fatal("channels were refused free slots")
+	}
+	if a.acquire(3, 1, now) || a.acquire(4, 5, now) || a.acquire(5, 1, now.Add(time.Second)) {
+		t.Fatal("channels were admitted beyond max_ffmpeg_instances")
+	}
+
+	capacity := a.capacity()
+	var queued []int
+	for _, q := range capacity.Queued {
+		queued = append(queued, q.ChannelID)
+	}
+	if !reflect.DeepEqual(queued, []int{4, 3, 5}) {
+		t.Errorf("queue = %v, want [4 3 5]: highest priority first, then first come", queued)
+	}
+	if capacity.Used != 2 || capacity.Available != 0 || capacity.GPUMemoryUsed != 1024 {
+		t.Errorf("capacity = %+v, want 2 used, none available, 1024 MB of GPU memory", capacity)
+	}
+
+	// Only the channel at the front of the queue may take a freed slot
+	if got := a.release(1); !reflect.DeepEqual(got, []int{4}) {
+		t.Errorf("release admitted %v, want [4]", got)
+	}
+	if a.acquire(3, 1, now) {
+		t.Error("channel 3 jumped the queue")
+	}
+	if !a.acquire(4, 5, now) {
+		t.Error("channel 4 was refused the freed slot")
+	}
+
+	// A queued channel that is cancelled no longer waits
+	if !a.cancel(3) || a.cancel(3) {
+		t.Error("cancel did not take channel 3 out of the queue exactly once")
+	}
+	if got := a.release(2); !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("release admitted %v, want [5]", got)
+	}
+	if got := a.release(2); got != nil {
+		t.Errorf("second release of channel 2 admitted %v", got)
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// asRunLookback is how many as-run entries lastAsRun reads back past slate
+// periods to find an item.
+const asRunLookback = 10
+
+// startAsRun records the item that has just gone to air.
+func (e *PlaylistExecutor) startAsRun(channel *models.Channel, item *models.PlaylistItem, offset int) {
+	e.openAsRun(&models.AsRunEntry{
+		ChannelID:   channel.ChannelID,
+		PlaylistID:  item.PlaylistID,
+		ItemID:      item.ItemID,
+		StartedAt:   e.clock.Now(),
+		StartOffset: float64(offset),
+	})
+}
+
+// startSlateAsRun records the slate going to air in place of the item
+// paused at offset.
+func (e *PlaylistExecutor) startSlateAsRun(channel *models.Channel, item *models.PlaylistItem, offset int) {
+	e.openAsRun(&models.AsRunEntry{
+		ChannelID:   channel.ChannelID,
+		PlaylistID:  item.PlaylistID,
+		ItemID:      item.ItemID,
+		Slate:       true,
+		StartedAt:   e.clock.Now(),
+		StartOffset: float64(offset),
+	})
+}
+
+// openAsRun writes a new entry and keeps it as the one on air.
+func (e *PlaylistExecutor) openAsRun(entry *models.AsRunEntry) {
+	if err := e.repo.CreateAsRunEntry(context.Background(), entry); err != nil {
+		log.Printf("Failed to write as-run log: %v", err)
+		return
+	}
+	e.currentState.asRun = entry
+}
+
+// endAsRun records the item on air leaving it, and how far into the item it
+// got going by the clock. The item stays paused while the slate is on air.
+func (e *PlaylistExecutor) endAsRun(reason string) {
+	entry := e.currentState.asRun
+	if entry == nil {
+		return
+	}
+	e.currentState.asRun = nil
+
+	now := e.clock.Now()
+	position := entry.StartOffset
+	if !entry.Slate {
+		position += now.Sub(entry.StartedAt).Seconds()
+	}
+	if err := e.repo.EndAsRunEntry(context.Background(), entry.AsRunID, now, position, reason); err != nil {
+		log.Printf("Failed to write as-run log: %v", err)
+	}
+}
+
+// lastAsRun returns the last item that went to air on a channel, or nil.
+// Slate periods are passed over; the item paused for one is ended there.
+func (e *PlaylistExecutor) lastAsRun(ctx context.Context, channelID int) *models.AsRunEntry {
+	entries, err := e.repo.GetAsRunLog(ctx, channelID, time.Time{}, asRunLookback)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if !entry.Slate {
+			return entry
+		}
+	}
+	return nil
+}
+
+// endStaleAsRun ends the as-run entry a previous run left open when it was
+// killed with an item on air. The item is taken to have left the air when
+// the channel state was last recorded.
+func (s *ChannelService) endStaleAsRun(ctx context.Context, state *models.ChannelState) {
+	entries, err := s.repo.GetAsRunLog(ctx, state.ChannelID, time.Time{}, 1)
+	if err != nil || len(entries) == 0 || entries[0].EndedAt.Valid {
+		return
+	}
+	entry := entries[0]
+
+	endedAt, position := state.LastUpdateTime, state.CurrentPosition
+	if entry.ItemID != state.CurrentItemID || endedAt.Before(entry.StartedAt) {
+		endedAt, position = entry.StartedAt, entry.StartOffset
+	}
+	if err := s.repo.EndAsRunEntry(ctx, entry.AsRunID, endedAt, position, models.AsRunFailed); err != nil {
+		log.Printf("Failed to write as-run log: %v", err)
+	}
+}
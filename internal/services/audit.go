@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// auditStore is what the audit service needs from the database.
+type auditStore interface {
+	database.LogStore
+}
+
+// AuditService reads the audit trail and records API access.
+type AuditService struct {
+	repo auditStore
+}
+
+func NewAuditService(repo auditStore) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// GetAuditLogs returns audit entries matching the filter, newest first.
+func (s *AuditService) GetAuditLogs(ctx context.Context, filter models.AuditFilter) ([]*models.AuditLog, error) {
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return s.repo.GetAuditLogs(ctx, filter)
+}
+
+// RecordAccess stores an API request in the access log.
+func (s *AuditService) RecordAccess(ctx context.Context, entry *models.APIAccessLog) {
+	if err := s.repo.CreateAPIAccessLog(ctx, entry); err != nil {
+		log.Printf("Failed to log access to %s %s: %v", entry.Method, entry.Endpoint, err)
+	}
+}
+
+// recordAudit writes a before/after snapshot of a change to the audit
+// trail, attributed to the actor in ctx. Failures are logged rather than
+// returned so that a change that has already been made is never reported
+// as failed.
+func recordAudit(ctx context.Context, repo database.LogStore, action, targetType string, targetID int, oldValue, newValue sql.NullString) {
+	entry := &models.AuditLog{
+		ActionType: action,
+		TargetType: targetType,
+		TargetID:   sql.NullInt64{Int64: int64(targetID), Valid: true},
+		OldValue:   oldValue,
+		NewValue:   newValue,
+	}
+
+	if actor, ok := ActorFromContext(ctx); ok {
+		entry.UserID = sql.NullInt64{Int64: int64(actor.UserID), Valid: actor.UserID != 0}
+		entry.IPAddress = sql.NullString{String: actor.IPAddress, Valid: actor.IPAddress != ""}
+	}
+
+	if err := repo.CreateAuditLog(ctx, entry); err != nil {
+		log.Printf("Failed to audit %s of %s %d: %v", action, targetType, targetID, err)
+	}
+}
+
+func marshalAuditValue[T any](value *T) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
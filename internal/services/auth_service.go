@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnauthorized is returned when credentials are missing, wrong or expired.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrInvalidUser is returned when a user fails validation.
+	ErrInvalidUser = errors.New("invalid user")
+)
+
+const minPasswordLength = 8
+
+// authStore is what the auth service needs from the database.
+type authStore interface {
+	database.UserStore
+	database.LogStore
+}
+
+// AuthService authenticates API callers by API key or by a session token
+// obtained with a username and password, and manages users.
+type AuthService struct {
+	repo       authStore
+	sessionTTL time.Duration
+	dummyHash  []byte
+}
+
+func NewAuthService(repo authStore, sessionTTL time.Duration) *AuthService {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return &AuthService{
+		repo:       repo,
+		sessionTTL: sessionTTL,
+		dummyHash:  dummyHash,
+	}
+}
+
+// Login checks a username and password and starts a session. The returned
+// token is sent as a bearer token on later requests.
+func (s *AuthService) Login(ctx context.Context, username, password string) (string, *models.User, time.Time, error) {
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Spend the same time as a wrong password so usernames cannot be probed
+			bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+			return "", nil, time.Time{}, ErrUnauthorized
+		}
+		return "", nil, time.Time{}, err
+	}
+
+	if !user.IsActive || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return "", nil, time.Time{}, ErrUnauthorized
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+
+	session := &models.UserSession{
+		UserID:    user.UserID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.sessionTTL),
+	}
+	if actor, ok := ActorFromContext(ctx); ok && actor.IPAddress != "" {
+		session.IPAddress = sql.NullString{String: actor.IPAddress, Valid: true}
+	}
+	if err := s.repo.CreateUserSession(ctx, session); err != nil {
+		return "", nil, time.Time{}, err
+	}
+
+	if err := s.repo.UpdateUserLastLogin(ctx, user.UserID); err != nil {
+		log.Printf("Failed to update last login of user %d: %v", user.UserID, err)
+	}
+
+	return token, user, session.ExpiresAt, nil
+}
+
+// Logout ends the session of a token.
+func (s *AuthService) Logout(ctx context.Context, token string) error {
+	return s.repo.DeleteUserSession(ctx, hashToken(token))
+}
+
+// AuthenticateToken returns the active user of a session token.
+func (s *AuthService) AuthenticateToken(ctx context.Context, token string) (*models.User, error) {
+	user, err := s.repo.GetSessionUser(ctx, hashToken(token))
+	return activeUser(user, err)
+}
+
+// AuthenticateAPIKey returns the active user owning an API key.
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (*models.User, error) {
+	user, err := s.repo.GetUserByAPIKey(ctx, hashToken(key))
+	return activeUser(user, err)
+}
+
+func activeUser(user *models.User, err error) (*models.User, error) {
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnauthorized
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrUnauthorized
+	}
+	return user, nil
+}
+
+func (s *AuthService) ListUsers(ctx context.Context) ([]*models.User, error) {
+	return s.repo.GetUsers(ctx)
+}
+
+func (s *AuthService) GetUser(ctx context.Context, userID int) (*models.User, error) {
+	return s.repo.GetUser(ctx, userID)
+}
+
+// CreateUser adds a user with the given password.
+func (s *AuthService) CreateUser(ctx context.Context, user *models.User, password string) (*models.User, error) {
+	user.Username = strings.TrimSpace(user.Username)
+	if user.Role == "" {
+		user.Role = models.RoleViewer
+	}
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = hash
+
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	created, err := s.repo.GetUser(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(ctx, s.repo, "user_create", "user", created.UserID, sql.NullString{}, marshalAuditValue(created))
+	return created, nil
+}
+
+// UpdateUser changes a user's details. The password is only changed when
+// password is not empty; a changed password or a deactivated user ends all
+// of the user's sessions.
+func (s *AuthService) UpdateUser(ctx context.Context, user *models.User, password string) (*models.User, error) {
+	existing, err := s.repo.GetUser(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Username = strings.TrimSpace(user.Username)
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+	if err := s.checkLastAdmin(ctx, existing, user.Role, user.IsActive); err != nil {
+		return nil, err
+	}
+
+	user.PasswordHash = existing.PasswordHash
+	user.APIKey = existing.APIKey
+	if password != "" {
+		if user.PasswordHash, err = hashPassword(password); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if password != "" || !user.IsActive {
+		if err := s.repo.DeleteUserSessions(ctx, user.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.repo.GetUser(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	action := "user_update"
+	if password != "" {
+		action = "user_password_change"
+	}
+	recordAudit(ctx, s.repo, action, "user", updated.UserID, marshalAuditValue(existing), marshalAuditValue(updated))
+	return updated, nil
+}
+
+func (s *AuthService) DeleteUser(ctx context.Context, userID int) error {
+	existing, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLastAdmin(ctx, existing, "", false); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	recordAudit(ctx, s.repo, "user_delete", "user", userID, marshalAuditValue(existing), sql.NullString{})
+	return nil
+}
+
+// RegenerateAPIKey gives a user a new API key, replacing any previous one.
+// The key is only ever returned here; the database keeps a hash of it.
+func (s *AuthService) RegenerateAPIKey(ctx context.Context, userID int) (string, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	user.APIKey = sql.NullString{String: hashToken(key), Valid: true}
+
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return "", err
+	}
+
+	recordAudit(ctx, s.repo, "api_key_regenerate", "user", userID, sql.NullString{}, sql.NullString{})
+	return key, nil
+}
+
+// RevokeAPIKey removes a user's API key.
+func (s *AuthService) RevokeAPIKey(ctx context.Context, userID int) error {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user.APIKey = sql.NullString{}
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	recordAudit(ctx, s.repo, "api_key_revoke", "user", userID, sql.NullString{}, sql.NullString{})
+	return nil
+}
+
+// EnsureAdmin creates an admin account when there are no users yet, so a
+// fresh installation can be logged into.
+func (s *AuthService) EnsureAdmin(ctx context.Context, username, password string) error {
+	count, err := s.repo.CountUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if password == "" {
+		log.Printf("No users exist; set ADMIN_PASSWORD to create the %q admin account", username)
+		return nil
+	}
+
+	_, err = s.CreateUser(ctx, &models.User{
+		Username: username,
+		Role:     models.RoleAdmin,
+		IsActive: true,
+	}, password)
+	if err != nil {
+		return fmt.Errorf("failed to create admin user: %w", err)
+	}
+	log.Printf("Created admin user %q", username)
+	return nil
+}
+
+// PurgeExpiredSessions removes expired sessions periodically until the
+// context is cancelled.
+func (s *AuthService) PurgeExpiredSessions(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if err := s.repo.DeleteExpiredSessions(ctx); err != nil {
+			log.Printf("Failed to purge expired sessions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkLastAdmin stops the last active admin from being demoted, deactivated
+// or deleted, which would lock everyone out of user management.
+func (s *AuthService) checkLastAdmin(ctx context.Context, existing *models.User, newRole string, active bool) error {
+	if existing.Role != models.RoleAdmin || !existing.IsActive {
+		return nil
+	}
+	if newRole == models.RoleAdmin && active {
+		return nil
+	}
+
+	users, err := s.repo.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.UserID != existing.UserID && u.Role == models.RoleAdmin && u.IsActive {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: cannot remove the last active admin", ErrInvalidUser)
+}
+
+func validateUser(user *models.User) error {
+	if user.Username == "" || len(user.Username) > 50 {
+		return fmt.Errorf("%w: username must be 1 to 50 characters", ErrInvalidUser)
+	}
+	if !models.ValidRole(user.Role) {
+		return fmt.Errorf("%w: invalid role %q", ErrInvalidUser, user.Role)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken hashes session tokens and API keys for storage. They are random
+// and long, so a plain SHA-256 is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,30 +2,73 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/euacreations/tvheadend/internal/config"
 	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/metrics"
 	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/clock"
 	"github.com/euacreations/tvheadend/pkg/ffmpeg"
 )
 
+// ffmpegStopTimeout is how long FFmpeg gets to exit after SIGTERM when a
+// channel stops before it is killed.
+const ffmpegStopTimeout = 5 * time.Second
+
+// commandTimeout bounds how long a command waits for a channel's executor,
+// which takes commands while an item is on air.
+const commandTimeout = 10 * time.Second
+
+// channelStore is what the channel service needs from the database,
+// including what it hands on to the playout of its channels.
+type channelStore interface {
+	database.ChannelStore
+	database.LogStore
+	playoutStore
+}
+
 type ChannelService struct {
-	repo             *database.Repository
-	playlistExecutor *PlaylistExecutor
-	streamers        map[int]*ffmpeg.Streamer
-	executorCancels  map[int]context.CancelFunc
-	streamMux        sync.Mutex
+	repo            channelStore
+	settings        *config.Settings
+	events          *events.Bus
+	executors       map[int]*PlaylistExecutor // Running channels
+	executorCancels map[int]context.CancelFunc
+	executorDone    map[int]chan struct{}
+	orphans         map[int][]*ffmpeg.Process // Left on air until their channel starts
+	logs            map[int]*ffmpeg.LogBuffer
+	overlayReloads  map[int]bool // Channels reloading overlays; true when another reload is due
+	outputMonitor   *OutputMonitor
+	admission       *admission
+	shuttingDown    bool
+	streamMux       sync.Mutex
+
+	// Overridden by tests to play out on simulated FFmpeg and virtual time
+	newRunner func() ffmpeg.Runner
+	clock     clock.Clock
 }
 
-func NewChannelService(repo *database.Repository) *ChannelService {
+func NewChannelService(repo channelStore, settings *config.Settings, bus *events.Bus) *ChannelService {
 	return &ChannelService{
-		repo:             repo,
-		streamers:        make(map[int]*ffmpeg.Streamer),
-		executorCancels:  make(map[int]context.CancelFunc),
-		playlistExecutor: NewPlaylistExecutor(repo, ffmpeg.New()),
+		repo:            repo,
+		settings:        settings,
+		events:          bus,
+		executors:       make(map[int]*PlaylistExecutor),
+		executorCancels: make(map[int]context.CancelFunc),
+		executorDone:    make(map[int]chan struct{}),
+		orphans:         make(map[int][]*ffmpeg.Process),
+		logs:            make(map[int]*ffmpeg.LogBuffer),
+		overlayReloads:  make(map[int]bool),
+		outputMonitor:   NewOutputMonitor(bus),
+		admission:       newAdmission(settings),
+		newRunner:       func() ffmpeg.Runner { return ffmpeg.New() },
+		clock:           clock.Real(),
 	}
 }
 
@@ -69,15 +112,15 @@ func (s *ChannelService) GetChannelStatus(ctx context.Context, channelID int) (*
 		return nil, false, fmt.Errorf("failed to get channel state: %w", err)
 	}
 
-	// Check if the streamer is actually running in our application memory
+	// Check if the channel is actually running in our application memory
 	s.streamMux.Lock()
-	streamer, isRunning := s.streamers[channelID]
+	executor, isRunning := s.executors[channelID]
 	s.streamMux.Unlock()
 
-	// If we have a streamer but the state says not running, update the state
+	// If we have an executor but the state says not running, update the state
 	if isRunning && !state.Running {
 		state.Running = true
-		state.FFmpegPID = streamer.PID()
+		state.FFmpegPID = executor.PID()
 		state.LastUpdateTime = time.Now()
 
 		if err := s.repo.UpdateChannelState(ctx, state); err != nil {
@@ -85,7 +128,7 @@ func (s *ChannelService) GetChannelStatus(ctx context.Context, channelID int) (*
 		}
 	}
 
-	// If we don't have a streamer but state says running, update the state
+	// If we don't have an executor but state says running, update the state
 	if !isRunning && state.Running {
 		state.Running = false
 		state.FFmpegPID = 0
@@ -103,7 +146,7 @@ func (s *ChannelService) CheckChannelStatus(ctx context.Context, channelID int)
 	s.streamMux.Lock()
 	defer s.streamMux.Unlock()
 
-	_, exists := s.streamers[channelID]
+	_, exists := s.executors[channelID]
 	return exists, nil
 }
 
@@ -113,65 +156,97 @@ func (s *ChannelService) StartChannel(ctx context.Context, channelID int) error
 		return fmt.Errorf("failed to get channel: %w", err)
 	}
 
+	if channel.PlaylistType != "daily_playlist" {
+		// Default behavior (existing code)
+		return s.startDefaultStream(ctx, channel)
+	}
+
 	s.streamMux.Lock()
 	defer s.streamMux.Unlock()
-	if _, exists := s.streamers[channelID]; exists {
+	if s.shuttingDown {
+		return fmt.Errorf("channel %d not started: shutting down", channelID)
+	}
+	if _, exists := s.executors[channelID]; exists {
 		return fmt.Errorf("channel %d is already running", channelID)
 	}
 
-	streamer := ffmpeg.New()
-	s.streamers[channelID] = streamer
+	if !s.admission.acquire(channelID, channel.Priority, s.clock.Now()) {
+		s.events.Publish(events.Event{
+			ChannelID: channelID,
+			Type:      events.ChannelQueued,
+			Severity:  events.SeverityWarning,
+			Category:  events.CategoryChannel,
+			Message:   fmt.Sprintf("Channel %s queued: all encoder slots are in use", channel.ChannelName),
+			Details:   map[string]interface{}{"priority": channel.Priority},
+		})
+		return fmt.Errorf("channel %d: %w", channelID, ErrChannelQueued)
+	}
 
-	streamer.SetProgressCallback(func(position float64) {
-		state := &models.ChannelState{
-			ChannelID:       channelID,
-			Running:         true,
-			CurrentPosition: position,
-			LastUpdateTime:  time.Now(),
-			FFmpegPID:       streamer.PID(),
-		}
-		if err := s.repo.UpdateChannelState(context.Background(), state); err != nil {
-			log.Printf("Failed to update channel state: %v", err)
-		}
-	})
+	if state, err := s.repo.GetChannelState(ctx, channelID); err == nil {
+		recordAudit(ctx, s.repo, "channel_start", "channel", channelID, marshalAuditValue(state), sql.NullString{})
+	}
 
-	switch channel.PlaylistType {
-	case "daily_playlist":
-		executor := NewPlaylistExecutor(s.repo, streamer)
-		executorCtx, cancel := context.WithCancel(context.Background())
-		s.executorCancels[channelID] = cancel
-
-		go func() {
-
-			defer func() {
-				// Clean up on exit
-				s.streamMux.Lock()
-				delete(s.streamers, channelID)
-				delete(s.executorCancels, channelID)
-				s.streamMux.Unlock()
-			}()
-
-			if err := executor.Execute(executorCtx, channel); err != nil {
-				// Only log error if it's not due to context cancellation
-				if err != context.Canceled {
-					log.Printf("PlaylistExecutor error: %v", err)
-				}
-			}
-			// if err := executor.Execute(context.Background(), channel); err != nil {
-			// 	log.Printf("PlaylistExecutor error: %v", err)
-			// 	// Clean up on error
-			// 	s.streamMux.Lock()
-			// 	delete(s.streamers, channelID)
-			// 	s.streamMux.Unlock()
-			// }
+	// The executor owns the channel's FFmpeg runner
+	runner := s.newRunner()
+	runner.SetLogBuffer(s.logBuffer(channelID))
+	executor := NewPlaylistExecutor(s.repo, s.settings, runner, s.events)
+	executor.clock = s.clock
+	executorCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.executors[channelID] = executor
+	s.executorCancels[channelID] = cancel
+	s.executorDone[channelID] = done
+
+	// Take over from FFmpeg left streaming by the previous run only now that
+	// the channel has a slot, so a queued channel stays on air meanwhile
+	orphans := s.orphans[channelID]
+	delete(s.orphans, channelID)
+
+	go func() {
+		defer close(done)
+		defer func() {
+			// The channel is free to start again only once FFmpeg has exited
+			s.streamMux.Lock()
+			s.forget(channelID)
+			s.streamMux.Unlock()
+			s.outputMonitor.Stop(channelID)
+			s.startQueued(s.admission.release(channelID))
 		}()
 
-	default:
-		// Default behavior (existing code)
-		return s.startDefaultStream(ctx, channel)
-		//return nil
+		s.stopOrphans(channelID, orphans)
+		if err := executor.Execute(executorCtx, channel); err != nil {
+			// Only report errors that are not due to context cancellation
+			if !errors.Is(err, context.Canceled) {
+				s.events.Publish(events.Event{
+					ChannelID: channelID,
+					Type:      events.ChannelFailed,
+					Severity:  events.SeverityError,
+					Category:  events.CategoryChannel,
+					Message:   fmt.Sprintf("Playout stopped: %v", err),
+				})
+			}
+		}
+	}()
+
+	if channel.MonitorOutput {
+		if err := s.outputMonitor.Start(channel); err != nil {
+			s.events.Publish(events.Event{
+				ChannelID: channelID,
+				Type:      events.OutputAlarm,
+				Severity:  events.SeverityWarning,
+				Category:  events.CategoryChannel,
+				Message:   fmt.Sprintf("Output monitor not started: %v", err),
+			})
+		}
 	}
 
+	s.events.Publish(events.Event{
+		ChannelID: channelID,
+		Type:      events.ChannelStarted,
+		Category:  events.CategoryChannel,
+		Message:   fmt.Sprintf("Channel %s started", channel.ChannelName),
+		Details:   map[string]interface{}{"playlist_type": channel.PlaylistType},
+	})
 	return nil
 
 }
@@ -183,43 +258,412 @@ func (s *ChannelService) startDefaultStream(ctx context.Context, channel *models
 
 func (s *ChannelService) StopChannel(ctx context.Context, channelID int) error {
 	s.streamMux.Lock()
-	streamer, streamerExists := s.streamers[channelID]
-	cancel, cancelExists := s.executorCancels[channelID]
+	_, running := s.executors[channelID]
 	s.streamMux.Unlock()
 
-	if !streamerExists {
+	if !running {
+		if s.admission.cancel(channelID) {
+			s.events.Publish(events.Event{
+				ChannelID: channelID,
+				Type:      events.ChannelStopped,
+				Category:  events.CategoryChannel,
+				Message:   "Queued channel start cancelled",
+			})
+			return nil
+		}
 		return fmt.Errorf("channel %d is not running", channelID)
 	}
 
-	streamer.SetProgressCallback(nil) // Disable further callbacks
+	previousState, currentState, err := s.stopChannel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	recordAudit(ctx, s.repo, "channel_stop", "channel", channelID, marshalAuditValue(previousState), marshalAuditValue(currentState))
+	s.events.Publish(events.Event{
+		ChannelID: channelID,
+		Type:      events.ChannelStopped,
+		Category:  events.CategoryChannel,
+		Message:   "Channel stopped",
+	})
+	return nil
+}
+
+// stopChannel takes a channel off air. Its executor stops FFmpeg, which gets
+// ffmpegStopTimeout to exit after SIGTERM, unlocks its items and writes the
+// final state as it exits. The channel stays registered until then, so it
+// cannot be started again while the old FFmpeg may still be streaming. It
+// returns the state before and after.
+func (s *ChannelService) stopChannel(ctx context.Context, channelID int) (*models.ChannelState, *models.ChannelState, error) {
+	s.streamMux.Lock()
+	_, exists := s.executors[channelID]
+	cancel := s.executorCancels[channelID]
+	done := s.executorDone[channelID]
+	s.streamMux.Unlock()
 
-	if cancelExists {
-		cancel()
+	if !exists {
+		return nil, nil, fmt.Errorf("channel %d is not running", channelID)
 	}
 
-	if err := streamer.Stop(); err != nil {
-		return fmt.Errorf("failed to stop stream: %w", err)
+	// The channel stops even if its state cannot be read
+	previousState, _ := s.repo.GetChannelState(ctx, channelID)
+
+	cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, nil, fmt.Errorf("channel %d is still stopping: %w", channelID, ctx.Err())
 	}
 
-	// Retrieve the current state before modifying
-	currentState, err := s.repo.GetChannelState(ctx, channelID)
+	currentState, err := s.repo.GetChannelState(context.WithoutCancel(ctx), channelID)
 	if err != nil {
-		return fmt.Errorf("failed to get current channel state: %w", err)
+		return nil, nil, fmt.Errorf("failed to get current channel state: %w", err)
 	}
+	return previousState, currentState, nil
+}
+
+// forget drops a channel's executor. The caller must hold streamMux.
+func (s *ChannelService) forget(channelID int) {
+	delete(s.executors, channelID)
+	delete(s.executorCancels, channelID)
+	delete(s.executorDone, channelID)
+}
 
-	currentState.Running = false
-	currentState.FFmpegPID = 0
-	currentState.LastUpdateTime = time.Now()
+// Shutdown takes every channel off air, in parallel, before the server
+// exits. Channels cannot be started afterwards, and queued ones never are.
+func (s *ChannelService) Shutdown(ctx context.Context) error {
+	s.streamMux.Lock()
+	s.shuttingDown = true
+	channelIDs := make([]int, 0, len(s.executors))
+	for channelID := range s.executors {
+		channelIDs = append(channelIDs, channelID)
+	}
+	s.streamMux.Unlock()
 
-	// Update the channel state in the database
-	if err := s.repo.UpdateChannelState(ctx, currentState); err != nil {
-		return fmt.Errorf("failed to update channel state: %w", err)
+	var wg sync.WaitGroup
+	errs := make([]error, len(channelIDs))
+	for i, channelID := range channelIDs {
+		wg.Add(1)
+		go func(i, channelID int) {
+			defer wg.Done()
+			if _, _, err := s.stopChannel(ctx, channelID); err != nil {
+				errs[i] = fmt.Errorf("channel %d: %w", channelID, err)
+				return
+			}
+			s.events.Publish(events.Event{
+				ChannelID: channelID,
+				Type:      events.ChannelStopped,
+				Category:  events.CategoryChannel,
+				Message:   "Channel stopped for shutdown",
+			})
+		}(i, channelID)
 	}
+	wg.Wait()
 
 	s.streamMux.Lock()
-	delete(s.streamers, channelID)
-	delete(s.executorCancels, channelID)
+	orphaned := make([]int, 0, len(s.orphans))
+	for channelID := range s.orphans {
+		orphaned = append(orphaned, channelID)
+	}
+	s.streamMux.Unlock()
+	for _, channelID := range orphaned {
+		s.retireOrphans(channelID)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ReconcileStates clears channel states that a previous run left marked as
+// running, as it does when it was killed rather than shut down, and deals
+// with the FFmpeg processes it left streaming. The item and position each
+// channel reached are kept.
+func (s *ChannelService) ReconcileStates(ctx context.Context) error {
+	channels, err := s.repo.GetAllChannels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve channels: %w", err)
+	}
+
+	for _, channel := range channels {
+		state, err := s.repo.GetChannelState(ctx, channel.ChannelID)
+		if err != nil {
+			state = &models.ChannelState{ChannelID: channel.ChannelID}
+		}
+		s.reconcileOrphans(channel, state.FFmpegPID)
+
+		if !state.Running && state.FFmpegPID == 0 {
+			continue
+		}
+		s.endStaleAsRun(ctx, state)
+
+		// LastUpdateTime stays when the position was recorded
+		pid := state.FFmpegPID
+		state.Running = false
+		state.FFmpegPID = 0
+		state.OnSlate = false
+		if err := s.repo.UpdateChannelState(ctx, state); err != nil {
+			return fmt.Errorf("failed to update state of channel %d: %w", channel.ChannelID, err)
+		}
+
+		s.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.StateReconciled,
+			Category:  events.CategoryChannel,
+			Message:   "Cleared running state left by the previous run",
+			Details:   map[string]interface{}{"ffmpeg_pid": pid},
+		})
+	}
+	return nil
+}
+
+// GetCapacity reports the encoder slots in use and the channels waiting
+// for one.
+func (s *ChannelService) GetCapacity() Capacity {
+	return s.admission.capacity()
+}
+
+// AdmitQueued starts queued channels for which there are free slots, e.g.
+// after the limits were raised.
+func (s *ChannelService) AdmitQueued() {
+	s.startQueued(s.admission.next())
+}
+
+// startQueued starts channels that have left the queue.
+func (s *ChannelService) startQueued(channelIDs []int) {
+	s.streamMux.Lock()
+	shuttingDown := s.shuttingDown
+	s.streamMux.Unlock()
+	if shuttingDown {
+		return
+	}
+
+	for _, channelID := range channelIDs {
+		go func(channelID int) {
+			err := s.StartChannel(context.Background(), channelID)
+			if err != nil && !errors.Is(err, ErrChannelQueued) {
+				s.events.Publish(events.Event{
+					ChannelID: channelID,
+					Type:      events.ChannelFailed,
+					Severity:  events.SeverityError,
+					Category:  events.CategoryChannel,
+					Message:   fmt.Sprintf("Failed to start queued channel: %v", err),
+				})
+			}
+		}(channelID)
+	}
+}
+
+// GetChannelProgress returns the latest encoding statistics of a running
+// channel.
+func (s *ChannelService) GetChannelProgress(channelID int) (ffmpeg.Progress, bool) {
+	s.streamMux.Lock()
+	executor, running := s.executors[channelID]
+	s.streamMux.Unlock()
+
+	if !running {
+		return ffmpeg.Progress{}, false
+	}
+	return executor.Progress(), true
+}
+
+// GetOutputReport returns the latest analysis of the channel's transmitted
+// stream, if its output is monitored.
+func (s *ChannelService) GetOutputReport(channelID int) (OutputReport, bool) {
+	return s.outputMonitor.Report(channelID)
+}
+
+// GetAsRunLog returns what went to air on a channel since the given time,
+// newest first.
+func (s *ChannelService) GetAsRunLog(ctx context.Context, channelID int, since time.Time, limit int) ([]*models.AsRunEntry, error) {
+	entries, err := s.repo.GetAsRunLog(ctx, channelID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get as-run log: %w", err)
+	}
+	return entries, nil
+}
+
+// GetChannelLogs returns up to limit of the most recent FFmpeg log lines of a
+// channel, including those of earlier runs since the server started.
+func (s *ChannelService) GetChannelLogs(channelID int, limit int) []ffmpeg.LogLine {
+	s.streamMux.Lock()
+	defer s.streamMux.Unlock()
+
+	logs, exists := s.logs[channelID]
+	if !exists {
+		return []ffmpeg.LogLine{}
+	}
+	return logs.Lines(limit)
+}
+
+// logBuffer returns the channel's FFmpeg log, creating it on first use. The
+// caller must hold streamMux.
+func (s *ChannelService) logBuffer(channelID int) *ffmpeg.LogBuffer {
+	logs, exists := s.logs[channelID]
+	if !exists {
+		logs = ffmpeg.NewLogBuffer(ffmpeg.DefaultLogLines)
+		s.logs[channelID] = logs
+	}
+	return logs
+}
+
+// CollectMetrics refreshes the per-channel gauges from the running channels.
+func (s *ChannelService) CollectMetrics(ctx context.Context) error {
+	channels, err := s.repo.GetAllChannels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get channels: %w", err)
+	}
+
+	s.streamMux.Lock()
+	defer s.streamMux.Unlock()
+
+	for _, channel := range channels {
+		label := strconv.Itoa(channel.ChannelID)
+		executor, running := s.executors[channel.ChannelID]
+		if !running {
+			metrics.ChannelRunning.Set(0, label)
+			metrics.ChannelPosition.Delete(label)
+			metrics.EncodeFPS.Delete(label)
+			metrics.EncodeSpeed.Delete(label)
+			metrics.EncodeBitrate.Delete(label)
+			metrics.FramesDropped.Delete(label)
+			metrics.FramesDuplicated.Delete(label)
+			continue
+		}
+
+		progress := executor.Progress()
+		metrics.ChannelRunning.Set(1, label)
+		metrics.ChannelPosition.Set(executor.Position(), label)
+		metrics.EncodeFPS.Set(progress.FPS, label)
+		metrics.EncodeSpeed.Set(progress.Speed, label)
+		metrics.EncodeBitrate.Set(progress.Bitrate, label)
+		metrics.FramesDropped.Set(float64(progress.DroppedFrames), label)
+		metrics.FramesDuplicated.Set(float64(progress.DuplicatedFrames), label)
+	}
+	return nil
+}
+
+// ReloadOverlays applies changed overlays to the channel's running stream,
+// if the channel is on air. It returns without waiting for the executor;
+// changes made while a reload is under way are picked up by one more.
+func (s *ChannelService) ReloadOverlays(channelID int) {
+	s.streamMux.Lock()
+	defer s.streamMux.Unlock()
+
+	if _, exists := s.executors[channelID]; !exists {
+		return
+	}
+	if _, reloading := s.overlayReloads[channelID]; reloading {
+		s.overlayReloads[channelID] = true
+		return
+	}
+	s.overlayReloads[channelID] = false
+	go s.reloadOverlays(channelID)
+}
+
+// reloadOverlays sends reload commands to the channel's executor until no
+// further reload is due.
+func (s *ChannelService) reloadOverlays(channelID int) {
+	for {
+		s.streamMux.Lock()
+		executor, exists := s.executors[channelID]
+		s.streamMux.Unlock()
+
+		if exists {
+			ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+			err := executor.ReloadOverlays(ctx)
+			cancel()
+			if err != nil && !errors.Is(err, ErrPlayoutStopped) {
+				s.events.Publish(events.Event{
+					ChannelID: channelID,
+					Type:      events.OverlayError,
+					Severity:  events.SeverityWarning,
+					Category:  events.CategoryOverlay,
+					Message:   fmt.Sprintf("Failed to reload overlays: %v", err),
+				})
+			}
+		}
+
+		s.streamMux.Lock()
+		again := s.overlayReloads[channelID]
+		if again {
+			s.overlayReloads[channelID] = false
+		} else {
+			delete(s.overlayReloads, channelID)
+		}
+		s.streamMux.Unlock()
+
+		if !again {
+			return
+		}
+	}
+}
+
+// ErrChannelNotRunning is returned for a control sent to a channel that is
+// not on air.
+var ErrChannelNotRunning = errors.New("channel is not running")
+
+// Operator transport controls for a channel on air.
+const (
+	ControlSkip    = "skip"    // End the item and play the next
+	ControlRestart = "restart" // Play the item again from its start
+	ControlSeek    = "seek"    // Play the media file from Position
+	ControlJump    = "jump"    // End the item and play ItemID next
+	ControlSlate   = "slate"   // Pause the item behind the emergency slate
+	ControlUnslate = "unslate" // Resume the paused item
+)
+
+// ControlRequest is an operator's transport control for a channel.
+type ControlRequest struct {
+	Action   string `json:"action" binding:"required"`
+	Position int    `json:"position"` // Seconds into the item, for seek
+	ItemID   int    `json:"item_id"`  // Item to play next, for jump
+}
+
+// Control hands a transport control to the channel's executor and waits
+// until it has been carried out.
+func (s *ChannelService) Control(ctx context.Context, channelID int, req ControlRequest) error {
+	s.streamMux.Lock()
+	executor, exists := s.executors[channelID]
 	s.streamMux.Unlock()
 
+	if !exists {
+		return fmt.Errorf("%w: channel %d", ErrChannelNotRunning, channelID)
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	var err error
+	switch req.Action {
+	case ControlSkip:
+		err = executor.Skip(cmdCtx)
+	case ControlRestart:
+		err = executor.Restart(cmdCtx)
+	case ControlSeek:
+		err = executor.Seek(cmdCtx, req.Position)
+	case ControlJump:
+		err = executor.Jump(cmdCtx, req.ItemID)
+	case ControlSlate:
+		err = executor.Slate(cmdCtx)
+	case ControlUnslate:
+		err = executor.Unslate(cmdCtx)
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidCommand, req.Action)
+	}
+	if err != nil {
+		return err
+	}
+
+	recordAudit(ctx, s.repo, "channel_control", "channel", channelID, sql.NullString{}, marshalAuditValue(&req))
+	s.events.Publish(events.Event{
+		ChannelID: channelID,
+		Type:      events.PlayoutControl,
+		Category:  events.CategoryChannel,
+		Message:   fmt.Sprintf("Operator control: %s", req.Action),
+		Details: map[string]interface{}{
+			"action":   req.Action,
+			"position": req.Position,
+			"item_id":  req.ItemID,
+		},
+	})
 	return nil
 }
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+)
+
+// newTestChannelService returns a channel service that plays out on the
+// harness's simulator and clock.
+func newTestChannelService(h *playoutHarness) *ChannelService {
+	s := NewChannelService(h.store, config.DefaultSettings(), nil)
+	s.newRunner = func() ffmpeg.Runner { return h.sim }
+	s.clock = h.clock
+	return s
+}
+
+func TestShutdownStampsFinalState(t *testing.T) {
+	ctx := context.Background()
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	s := newTestChannelService(h)
+
+	if err := s.StartChannel(ctx, h.channel.ChannelID); err != nil {
+		t.Fatalf("StartChannel: %v", err)
+	}
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+	h.clock.Advance(4 * time.Second)
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if h.sim.IsRunning() {
+		t.Error("FFmpeg is still running after shutdown")
+	}
+
+	state, err := h.store.GetChannelState(ctx, h.channel.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := h.store.GetPlaylistItems(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Running || state.FFmpegPID != 0 {
+		t.Errorf("state running %v with PID %d after shutdown", state.Running, state.FFmpegPID)
+	}
+	if state.CurrentItemID != items[1].ItemID || state.CurrentPosition != 9 {
+		t.Errorf("stopped at item %d position %.1f, want item %d position 9", state.CurrentItemID, state.CurrentPosition, items[1].ItemID)
+	}
+	for _, item := range items {
+		if item.Locked {
+			t.Errorf("item %d is still locked", item.ItemID)
+		}
+	}
+
+	if err := s.StartChannel(ctx, h.channel.ChannelID); err == nil {
+		t.Error("StartChannel succeeded after shutdown")
+	}
+}
+
+func TestControlStopOnSlate(t *testing.T) {
+	ctx := context.Background()
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.channel.SlateFile = sql.NullString{String: "slate.ts", Valid: true}
+	if err := h.store.UpdateChannel(ctx, h.channel); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestChannelService(h)
+
+	if err := s.Control(ctx, h.channel.ChannelID, ControlRequest{Action: ControlSkip}); !errors.Is(err, ErrChannelNotRunning) {
+		t.Errorf("Control before start = %v, want ErrChannelNotRunning", err)
+	}
+	if err := s.StartChannel(ctx, h.channel.ChannelID); err != nil {
+		t.Fatalf("StartChannel: %v", err)
+	}
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+	h.clock.Advance(3 * time.Second)
+
+	if err := s.Control(ctx, h.channel.ChannelID, ControlRequest{Action: "rewind"}); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("unknown action = %v, want ErrInvalidCommand", err)
+	}
+	if err := s.Control(ctx, h.channel.ChannelID, ControlRequest{Action: ControlSlate}); err != nil {
+		t.Fatalf("slate: %v", err)
+	}
+	h.sim.WaitStarts(2)
+	h.clock.BlockUntil(1)
+	h.clock.Advance(time.Minute)
+
+	// The slate's position must not overwrite the paused item's
+	if err := s.StopChannel(ctx, h.channel.ChannelID); err != nil {
+		t.Fatalf("StopChannel: %v", err)
+	}
+	state, err := h.store.GetChannelState(ctx, h.channel.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.OnSlate || state.CurrentPosition != 8 {
+		t.Errorf("stopped on slate %v at %.1fs, want off the slate at 8s", state.OnSlate, state.CurrentPosition)
+	}
+}
+
+func TestReconcileStatesClearsStaleRunningState(t *testing.T) {
+	ctx := context.Background()
+	h := newPlayoutHarness(t, at(14, "06:00:00"))
+	s := newTestChannelService(h)
+
+	stale := &models.ChannelState{
+		ChannelID:       h.channel.ChannelID,
+		CurrentItemID:   7,
+		CurrentPosition: 42,
+		Running:         true,
+		FFmpegPID:       1 << 22, // Above the kernel's PID limit
+	}
+	if err := h.store.UpdateChannelState(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ReconcileStates(ctx); err != nil {
+		t.Fatalf("ReconcileStates: %v", err)
+	}
+	state, err := h.store.GetChannelState(ctx, h.channel.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Running || state.FFmpegPID != 0 {
+		t.Errorf("state still running %v with PID %d", state.Running, state.FFmpegPID)
+	}
+	if state.CurrentItemID != 7 || state.CurrentPosition != 42 {
+		t.Errorf("reconciling lost the position: item %d at %.1f, want item 7 at 42", state.CurrentItemID, state.CurrentPosition)
+	}
+}
+
+func TestQueuedChannelKeepsOrphanOnAir(t *testing.T) {
+	ctx := context.Background()
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	s := newTestChannelService(h)
+	if err := s.settings.Update(ctx, h.store, map[string]string{"max_ffmpeg_instances": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stand-in for FFmpeg left streaming by the previous run
+	orphan := exec.Command("sleep", "60")
+	if err := orphan.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = orphan.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		_ = orphan.Process.Kill()
+		<-exited
+	})
+	s.orphans[h.channel.ChannelID] = []*ffmpeg.Process{{PID: orphan.Process.Pid}}
+
+	// Another channel holds the only encoder slot
+	s.admission.acquire(99, 0, h.clock.Now())
+	if err := s.StartChannel(ctx, h.channel.ChannelID); !errors.Is(err, ErrChannelQueued) {
+		t.Fatalf("StartChannel = %v, want ErrChannelQueued", err)
+	}
+	select {
+	case <-exited:
+		t.Fatal("orphan stopped while its channel was queued")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Once admitted the channel takes over
+	s.startQueued(s.admission.release(99))
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("orphan still running after its channel started")
+	}
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+// slowExitRunner is FFmpeg that takes until exit is closed to stop.
+type slowExitRunner struct {
+	*ffmpeg.Simulator
+	exit chan struct{}
+}
+
+func (r *slowExitRunner) Terminate(timeout time.Duration) error {
+	<-r.exit
+	return r.Simulator.Terminate(timeout)
+}
+
+func TestStopChannelKeepsChannelUntilFFmpegExits(t *testing.T) {
+	ctx := context.Background()
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	s := newTestChannelService(h)
+	runner := &slowExitRunner{Simulator: h.sim, exit: make(chan struct{})}
+	s.newRunner = func() ffmpeg.Runner { return runner }
+
+	if err := s.StartChannel(ctx, h.channel.ChannelID); err != nil {
+		t.Fatalf("StartChannel: %v", err)
+	}
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+
+	// The request gives up before FFmpeg has exited
+	stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := s.StopChannel(stopCtx, h.channel.ChannelID); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("StopChannel = %v, want DeadlineExceeded", err)
+	}
+	if err := s.StartChannel(ctx, h.channel.ChannelID); err == nil {
+		t.Fatal("StartChannel succeeded while the old FFmpeg was still stopping")
+	}
+
+	close(runner.exit)
+	s.streamMux.Lock()
+	done := s.executorDone[h.channel.ChannelID]
+	s.streamMux.Unlock()
+	if done != nil {
+		<-done
+	}
+
+	state, err := h.store.GetChannelState(ctx, h.channel.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Running || state.FFmpegPID != 0 {
+		t.Errorf("state running %v with PID %d after FFmpeg exited", state.Running, state.FFmpegPID)
+	}
+	if running, _ := s.CheckChannelStatus(ctx, h.channel.ChannelID); running {
+		t.Error("channel still registered after FFmpeg exited")
+	}
+}
+
+func TestReloadOverlaysDoesNotWaitForPlayout(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:00"))
+	s := newTestChannelService(h)
+	channelID := h.channel.ChannelID
+
+	// An executor between items takes no commands
+	executor := NewPlaylistExecutor(h.store, s.settings, h.sim, nil)
+	s.streamMux.Lock()
+	s.executors[channelID] = executor
+	s.streamMux.Unlock()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		s.ReloadOverlays(channelID)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ReloadOverlays took %v", elapsed)
+	}
+
+	// The later changes wait for the reload under way as a single reload
+	s.streamMux.Lock()
+	again, reloading := s.overlayReloads[channelID]
+	s.streamMux.Unlock()
+	if !reloading || !again {
+		t.Errorf("reloading %v with another due %v, want one reload under way and one due", reloading, again)
+	}
+
+	close(executor.done)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.streamMux.Lock()
+		_, reloading = s.overlayReloads[channelID]
+		s.streamMux.Unlock()
+		if !reloading {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("overlay reloads did not finish after playout stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -5,32 +5,176 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/metrics"
 	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
 )
 
+// mediaStore is what the media scanner needs from the database.
+type mediaStore interface {
+	database.ChannelStore
+	database.MediaStore
+}
+
 type MediaScanner struct {
-	repo *database.Repository
+	repo   mediaStore
+	events *events.Bus
+	// Channels whose media loudness is being measured
+	measuring    map[int]bool
+	measuringMux sync.Mutex
+	measurements sync.WaitGroup
+	ctx          context.Context // Cancelled by Stop, ending measurements
+	cancel       context.CancelFunc
 }
 
-func NewMediaScanner(repo *database.Repository) *MediaScanner {
-	return &MediaScanner{repo: repo}
+func NewMediaScanner(repo mediaStore, bus *events.Bus) *MediaScanner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MediaScanner{repo: repo, events: bus, measuring: make(map[int]bool), ctx: ctx, cancel: cancel}
+}
+
+// Stop ends the loudness measurements in progress, killing their FFmpeg
+// processes, and waits for them to finish.
+func (s *MediaScanner) Stop() {
+	s.cancel()
+	s.measurements.Wait()
 }
 
 func (s *MediaScanner) ScanChannelMedia(ctx context.Context, channelID int) error {
+	started := time.Now()
+	files, added, err := s.scan(ctx, channelID)
+	metrics.MediaScanDuration.Observe(time.Since(started).Seconds(), strconv.Itoa(channelID))
+	if err != nil {
+		s.events.Publish(events.Event{
+			ChannelID: channelID,
+			Type:      events.ScanFailed,
+			Severity:  events.SeverityError,
+			Category:  events.CategoryMedia,
+			Message:   fmt.Sprintf("Media scan failed: %v", err),
+		})
+		return err
+	}
+
+	s.events.Publish(events.Event{
+		ChannelID: channelID,
+		Type:      events.ScanCompleted,
+		Category:  events.CategoryMedia,
+		Message:   fmt.Sprintf("Media scan found %d files, %d new", files, added),
+		Details: map[string]interface{}{
+			"files":       files,
+			"added":       added,
+			"duration_ms": time.Since(started).Milliseconds(),
+		},
+	})
+
+	if channel, err := s.repo.GetChannelByID(ctx, channelID); err == nil && channel.MeasureLoudness {
+		s.startLoudnessMeasurement(channel)
+	}
+	return nil
+}
+
+// startLoudnessMeasurement measures the loudness of the channel's media
+// files that have not been measured yet, in the background since it decodes
+// every file in full.
+func (s *MediaScanner) startLoudnessMeasurement(channel *models.Channel) {
+	s.measuringMux.Lock()
+	defer s.measuringMux.Unlock()
+
+	if s.measuring[channel.ChannelID] || s.ctx.Err() != nil {
+		return
+	}
+	s.measuring[channel.ChannelID] = true
+
+	s.measurements.Add(1)
+	go func() {
+		defer s.measurements.Done()
+		defer func() {
+			s.measuringMux.Lock()
+			delete(s.measuring, channel.ChannelID)
+			s.measuringMux.Unlock()
+		}()
+		s.measureLoudness(s.ctx, channel)
+	}()
+}
+
+func (s *MediaScanner) measureLoudness(ctx context.Context, channel *models.Channel) {
+	files, err := s.repo.GetUnmeasuredMediaFiles(ctx, channel.ChannelID)
+	if err != nil {
+		s.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.ScanFailed,
+			Severity:  events.SeverityError,
+			Category:  events.CategoryMedia,
+			Message:   fmt.Sprintf("Loudness measurement failed: %v", err),
+		})
+		return
+	}
+
+	measured, failed := 0, 0
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return // Shutting down; the rest are measured next time
+		}
+		loudness, err := ffmpeg.MeasureLoudness(ctx, mediaPath(channel, file))
+		if err == nil {
+			err = s.repo.UpdateMediaLoudness(ctx, file.MediaID, loudness.Integrated, loudness.TruePeak)
+		}
+		if err != nil {
+			failed++
+			s.events.Publish(events.Event{
+				ChannelID: channel.ChannelID,
+				Type:      events.ScanFailed,
+				Severity:  events.SeverityWarning,
+				Category:  events.CategoryMedia,
+				Message:   fmt.Sprintf("Failed to measure loudness of %s: %v", file.FileName, err),
+				Details:   map[string]interface{}{"media_id": file.MediaID},
+			})
+			continue
+		}
+		measured++
+	}
+
+	if len(files) > 0 {
+		s.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.LoudnessMeasured,
+			Category:  events.CategoryMedia,
+			Message:   fmt.Sprintf("Measured loudness of %d media files, %d failed", measured, failed),
+			Details:   map[string]interface{}{"measured": measured, "failed": failed},
+		})
+	}
+}
+
+// mediaPath returns where a media file is on disk. Scanned files are stored
+// with their full path, others relative to the channel's media directory.
+func mediaPath(channel *models.Channel, file *models.MediaFile) string {
+	if filepath.IsAbs(file.FilePath) {
+		return file.FilePath
+	}
+	return filepath.Join(channel.StorageRoot, "media", file.FilePath)
+}
+
+// scan adds new files in the channel's media directory to the database and
+// returns how many files it saw and how many were new.
+func (s *MediaScanner) scan(ctx context.Context, channelID int) (int, int, error) {
 	channel, err := s.repo.GetChannelByID(ctx, channelID)
 	if err != nil {
-		return fmt.Errorf("failed to get channel: %w", err)
+		return 0, 0, fmt.Errorf("failed to get channel: %w", err)
 	}
 
 	mediaDir := filepath.Join(channel.StorageRoot, "media")
 	files, err := os.ReadDir(mediaDir)
 	if err != nil {
-		return fmt.Errorf("failed to read media directory: %w", err)
+		return 0, 0, fmt.Errorf("failed to read media directory: %w", err)
 	}
 
+	seen, added := 0, 0
+
 	for _, file := range files {
 		if file.IsDir() {
 			continue
@@ -41,11 +185,12 @@ func (s *MediaScanner) ScanChannelMedia(ctx context.Context, channelID int) erro
 		if err != nil {
 			continue // Skip files we can't stat
 		}
+		seen++
 
 		// Check if file already exists in database
 		exists, err := s.repo.MediaFileExists(ctx, channelID, filePath)
 		if err != nil {
-			return err
+			return seen, added, err
 		}
 
 		if !exists {
@@ -60,10 +205,11 @@ func (s *MediaScanner) ScanChannelMedia(ctx context.Context, channelID int) erro
 			}
 
 			if err := s.repo.CreateMediaFile(ctx, &mediaFile); err != nil {
-				return err
+				return seen, added, err
 			}
+			added++
 		}
 	}
 
-	return nil
+	return seen, added, nil
 }
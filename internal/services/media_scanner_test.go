@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func TestMediaScannerAddsNewFiles(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+
+	root := t.TempDir()
+	mediaDir := filepath.Join(root, "media")
+	if err := os.MkdirAll(filepath.Join(mediaDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		if err := os.WriteFile(filepath.Join(mediaDir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	channel := &models.Channel{ChannelName: "one", StorageRoot: root, StartTimeStr: "00:00:00"}
+	if err := store.UpdateChannel(ctx, channel); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := NewMediaScanner(store, nil)
+	if err := scanner.ScanChannelMedia(ctx, channel.ChannelID); err != nil {
+		t.Fatalf("ScanChannelMedia: %v", err)
+	}
+
+	// A second scan adds only the new file
+	if err := os.WriteFile(filepath.Join(mediaDir, "c.mp4"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := scanner.ScanChannelMedia(ctx, channel.ChannelID); err != nil {
+		t.Fatalf("ScanChannelMedia: %v", err)
+	}
+
+	files, err := store.GetMediaFiles(ctx, channel.ChannelID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.FileName)
+	}
+	if len(names) != 3 || names[0] != "a.mp4" || names[1] != "b.mp4" || names[2] != "c.mp4" {
+		t.Errorf("media files = %v, want [a.mp4 b.mp4 c.mp4]", names)
+	}
+}
+
+func TestMediaScannerMissingDirectory(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+
+	channel := &models.Channel{ChannelName: "one", StorageRoot: t.TempDir(), StartTimeStr: "00:00:00"}
+	if err := store.UpdateChannel(ctx, channel); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewMediaScanner(store, nil).ScanChannelMedia(ctx, channel.ChannelID); err == nil {
+		t.Error("ScanChannelMedia succeeded without a media directory")
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+)
+
+// reconcileOrphans deals with FFmpeg processes that a previous run left
+// streaming to a channel's output: the one recorded in its state and any
+// other found in /proc. With handover_orphaned_ffmpeg they stay on air until
+// the channel starts again, so viewers only lose the picture for as long as
+// FFmpeg takes to start. Otherwise, and for channels that will not start,
+// they are stopped now, as two processes streaming to the same output
+// corrupt it. Only daily playlist channels start a playout to hand over to.
+func (s *ChannelService) reconcileOrphans(channel *models.Channel, recordedPID int) {
+	processes, err := ffmpeg.FindProcesses(channel.OutputUDP)
+	if err != nil {
+		s.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.FFmpegOrphaned,
+			Severity:  events.SeverityWarning,
+			Category:  events.CategoryChannel,
+			Message:   fmt.Sprintf("Could not look for FFmpeg left running: %v", err),
+		})
+		return
+	}
+	if recordedPID != 0 {
+		if _, err := ffmpeg.FindProcess(recordedPID, channel.OutputUDP); err != nil && !containsProcess(processes, recordedPID) {
+			s.events.Publish(events.Event{
+				ChannelID: channel.ChannelID,
+				Type:      events.FFmpegOrphaned,
+				Category:  events.CategoryChannel,
+				Message:   fmt.Sprintf("Recorded FFmpeg process %d is gone: %v", recordedPID, err),
+			})
+		}
+	}
+	if len(processes) == 0 {
+		return
+	}
+
+	s.streamMux.Lock()
+	s.orphans[channel.ChannelID] = processes
+	s.streamMux.Unlock()
+
+	if channel.Enabled && channel.PlaylistType == "daily_playlist" && s.settings.Bool("handover_orphaned_ffmpeg") {
+		for _, process := range processes {
+			s.events.Publish(events.Event{
+				ChannelID: channel.ChannelID,
+				Type:      events.FFmpegOrphaned,
+				Severity:  events.SeverityWarning,
+				Category:  events.CategoryChannel,
+				Message:   fmt.Sprintf("FFmpeg %d of the previous run stays on air until the channel starts", process.PID),
+				Details:   map[string]interface{}{"pid": process.PID, "args": process.Args},
+			})
+		}
+		return
+	}
+	s.retireOrphans(channel.ChannelID)
+}
+
+// retireOrphans stops the FFmpeg processes a previous run left streaming to
+// a channel's output.
+func (s *ChannelService) retireOrphans(channelID int) {
+	s.streamMux.Lock()
+	processes := s.orphans[channelID]
+	delete(s.orphans, channelID)
+	s.streamMux.Unlock()
+
+	s.stopOrphans(channelID, processes)
+}
+
+// stopOrphans stops FFmpeg processes taken from s.orphans.
+func (s *ChannelService) stopOrphans(channelID int, processes []*ffmpeg.Process) {
+	for _, process := range processes {
+		event := events.Event{
+			ChannelID: channelID,
+			Type:      events.FFmpegOrphaned,
+			Severity:  events.SeverityWarning,
+			Category:  events.CategoryChannel,
+			Message:   fmt.Sprintf("Stopped FFmpeg %d left running by the previous run", process.PID),
+			Details:   map[string]interface{}{"pid": process.PID},
+		}
+		if err := process.Terminate(ffmpegStopTimeout); err != nil {
+			event.Message = fmt.Sprintf("Stopping FFmpeg %d left running by the previous run: %v", process.PID, err)
+		}
+		s.events.Publish(event)
+	}
+}
+
+func containsProcess(processes []*ffmpeg.Process, pid int) bool {
+	for _, process := range processes {
+		if process.PID == pid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+	"github.com/euacreations/tvheadend/pkg/tsmonitor"
+)
+
+// Output alarms, besides the black, frozen and silent conditions reported
+// by FFmpeg.
+const (
+	alarmNoSignal   = "no_signal"
+	alarmContinuity = "continuity_errors"
+	alarmPCRJitter  = "pcr_jitter"
+	alarmPATMissing = "pat_missing"
+	alarmPMTMissing = "pmt_missing"
+)
+
+// Output alarm thresholds.
+const (
+	monitorInterval    = time.Second
+	monitorGracePeriod = 10 * time.Second // FFmpeg startup
+	noSignalTimeout    = 3 * time.Second
+	tableTimeout       = 2 * time.Second // PAT and PMT repetition
+	maxPCRJitterMs     = 40.0
+)
+
+var outputDetectConfig = ffmpeg.DetectConfig{
+	BlackSeconds:  5,
+	FreezeSeconds: 10,
+	SilentSeconds: 10,
+}
+
+// OutputReport is the latest analysis of a channel's transmitted stream.
+type OutputReport struct {
+	Stats     tsmonitor.Stats `json:"stats"`
+	Alarms    []string        `json:"alarms"`
+	Detection string          `json:"detection,omitempty"` // Why picture and audio are not analysed
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// OutputMonitor receives what each monitored channel transmits and raises
+// alarms when the transport stream, picture or audio goes wrong. It has to
+// run where the output is received: on a multicast group, or on this host
+// for unicast output.
+type OutputMonitor struct {
+	events   *events.Bus
+	monitors map[int]*channelMonitor
+	mux      sync.Mutex
+}
+
+func NewOutputMonitor(bus *events.Bus) *OutputMonitor {
+	return &OutputMonitor{
+		events:   bus,
+		monitors: make(map[int]*channelMonitor),
+	}
+}
+
+type channelMonitor struct {
+	channelID  int
+	analyzer   *tsmonitor.Analyzer
+	cancel     context.CancelFunc
+	started    time.Time
+	events     *events.Bus
+	conditions map[string]bool // Reported by FFmpeg
+	alarms     map[string]bool
+	report     OutputReport
+	lastErrors int64
+	mux        sync.Mutex
+}
+
+// Start begins monitoring the channel's output.
+func (m *OutputMonitor) Start(channel *models.Channel) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, exists := m.monitors[channel.ChannelID]; exists {
+		return nil
+	}
+
+	conn, err := listenOutput(channel.OutputUDP)
+	if err != nil {
+		return fmt.Errorf("failed to join output %s: %w", channel.OutputUDP, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	monitor := &channelMonitor{
+		channelID:  channel.ChannelID,
+		analyzer:   tsmonitor.NewAnalyzer(),
+		cancel:     cancel,
+		started:    time.Now(),
+		events:     m.events,
+		conditions: make(map[string]bool),
+		alarms:     make(map[string]bool),
+	}
+	m.monitors[channel.ChannelID] = monitor
+
+	go monitor.run(ctx, conn)
+	return nil
+}
+
+// Stop ends monitoring of the channel's output.
+func (m *OutputMonitor) Stop(channelID int) {
+	m.mux.Lock()
+	monitor, exists := m.monitors[channelID]
+	delete(m.monitors, channelID)
+	m.mux.Unlock()
+
+	if exists {
+		monitor.cancel()
+	}
+}
+
+// Report returns the latest analysis of a monitored channel.
+func (m *OutputMonitor) Report(channelID int) (OutputReport, bool) {
+	m.mux.Lock()
+	monitor, exists := m.monitors[channelID]
+	m.mux.Unlock()
+
+	if !exists {
+		return OutputReport{}, false
+	}
+	monitor.mux.Lock()
+	defer monitor.mux.Unlock()
+	return monitor.report, true
+}
+
+// listenOutput joins the multicast group of a udp:// output URL, or listens
+// on its port for unicast output to this host. Unicast output to another
+// host never arrives here, so it is refused rather than reported as having
+// no signal.
+func listenOutput(output string) (*net.UDPConn, error) {
+	u, err := url.Parse(output)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "udp" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	addr, err := net.ResolveUDPAddr("udp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if addr.IP.IsMulticast() {
+		return net.ListenMulticastUDP("udp", nil, addr)
+	}
+	local, err := isLocalIP(addr.IP)
+	if err != nil {
+		return nil, err
+	}
+	if !local {
+		return nil, fmt.Errorf("unicast output to %s is received elsewhere and cannot be monitored", addr.IP)
+	}
+	return net.ListenUDP("udp", &net.UDPAddr{Port: addr.Port})
+}
+
+// isLocalIP reports whether ip is an address of this host.
+func isLocalIP(ip net.IP) (bool, error) {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+		return true, nil
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false, fmt.Errorf("failed to list local addresses: %w", err)
+	}
+	for _, addr := range addrs {
+		if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *channelMonitor) run(ctx context.Context, conn *net.UDPConn) {
+	defer conn.Close()
+
+	// Picture and audio are analysed by FFmpeg, fed through a queue so that
+	// a slow decoder cannot hold up the transport stream analysis
+	pipeReader, pipeWriter := io.Pipe()
+	queue := make(chan []byte, 1024)
+	go func() {
+		for data := range queue {
+			pipeWriter.Write(data) // Fails once detection has stopped
+		}
+		pipeWriter.Close()
+	}()
+	go func() {
+		err := ffmpeg.Detect(ctx, pipeReader, outputDetectConfig, c.setCondition)
+		if err == nil {
+			err = errors.New("FFmpeg exited")
+		}
+		pipeReader.CloseWithError(err)
+		if ctx.Err() == nil {
+			c.mux.Lock()
+			c.report.Detection = err.Error()
+			c.mux.Unlock()
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(monitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				c.evaluate(now)
+			}
+		}
+	}()
+
+	defer close(queue)
+	buf := make([]byte, 65536)
+	for ctx.Err() == nil {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			c.events.Publish(events.Event{
+				ChannelID: c.channelID,
+				Type:      events.OutputAlarm,
+				Severity:  events.SeverityError,
+				Category:  events.CategoryChannel,
+				Message:   fmt.Sprintf("Output monitor stopped: %v", err),
+			})
+			return
+		}
+
+		c.analyzer.Write(buf[:n], time.Now())
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		select {
+		case queue <- data:
+		default: // Detection is behind; it only needs a sample
+		}
+	}
+}
+
+func (c *channelMonitor) setCondition(condition string, active bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.conditions[condition] = active
+}
+
+// evaluate updates the report and raises or clears alarms.
+func (c *channelMonitor) evaluate(now time.Time) {
+	stats := c.analyzer.Snapshot(now)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	active := make(map[string]bool)
+	if now.Sub(c.started) >= monitorGracePeriod {
+		signal := !stats.LastPacket.IsZero() && now.Sub(stats.LastPacket) < noSignalTimeout
+		active[alarmNoSignal] = !signal
+		if signal {
+			active[alarmContinuity] = stats.ContinuityErrors > c.lastErrors
+			active[alarmPCRJitter] = stats.PCRJitter > maxPCRJitterMs
+			active[alarmPATMissing] = now.Sub(stats.LastPAT) > tableTimeout
+			active[alarmPMTMissing] = now.Sub(stats.LastPMT) > tableTimeout
+			for condition, on := range c.conditions {
+				active[condition] = on
+			}
+		}
+	}
+	c.lastErrors = stats.ContinuityErrors
+
+	for alarm, on := range active {
+		if on != c.alarms[alarm] {
+			c.publishAlarm(alarm, on, stats)
+		}
+	}
+	for alarm := range c.alarms {
+		if _, evaluated := active[alarm]; !evaluated {
+			c.publishAlarm(alarm, false, stats)
+		}
+	}
+
+	c.alarms = make(map[string]bool)
+	alarms := []string{}
+	for alarm, on := range active {
+		if on {
+			c.alarms[alarm] = true
+			alarms = append(alarms, alarm)
+		}
+	}
+	sort.Strings(alarms)
+
+	c.report.Stats = stats
+	c.report.Alarms = alarms
+	c.report.UpdatedAt = now
+}
+
+func (c *channelMonitor) publishAlarm(alarm string, raised bool, stats tsmonitor.Stats) {
+	event := events.Event{
+		ChannelID: c.channelID,
+		Type:      events.OutputAlarm,
+		Severity:  events.SeverityWarning,
+		Category:  events.CategoryChannel,
+		Message:   fmt.Sprintf("Output alarm raised: %s", alarm),
+		Details: map[string]interface{}{
+			"alarm": alarm,
+			"stats": stats,
+		},
+	}
+	if !raised {
+		event.Type = events.OutputAlarmCleared
+		event.Severity = events.SeverityInfo
+		event.Message = fmt.Sprintf("Output alarm cleared: %s", alarm)
+	}
+	c.events.Publish(event)
+}
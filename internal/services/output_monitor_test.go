@@ -0,0 +1,23 @@
+package services
+
+import "testing"
+
+func TestListenOutput(t *testing.T) {
+	tests := []struct {
+		output  string
+		wantErr bool
+	}{
+		{"udp://127.0.0.1:0?pkt_size=1316", false},
+		{"udp://192.0.2.10:1234?pkt_size=1316", true}, // Another host
+		{"rtp://127.0.0.1:1234", true},
+	}
+	for _, tt := range tests {
+		conn, err := listenOutput(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("listenOutput(%q) error = %v, want error %v", tt.output, err, tt.wantErr)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// adBreakTag marks media files that are ad breaks.
+const adBreakTag = "ad"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// overlayApplies reports whether an overlay's media and item rules allow it
+// on an item. media is nil for items that are not media files. Date and
+// weekday rules depend on when the item plays and are left to
+// overlayEnableExpr.
+func overlayApplies(overlay *models.Overlay, item *models.PlaylistItem, media *models.MediaFile) bool {
+	if ids := splitList(overlay.PlaylistItemIDs); len(ids) > 0 {
+		if item == nil || !containsString(ids, strconv.Itoa(item.ItemID)) {
+			return false
+		}
+	}
+
+	var mediaTags []string
+	if media != nil {
+		mediaTags = splitList(media.Tags)
+	}
+
+	if tags := splitList(overlay.MediaTags); len(tags) > 0 {
+		found := false
+		for _, tag := range tags {
+			if containsString(mediaTags, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if overlay.HideDuringAds && containsString(mediaTags, adBreakTag) {
+		return false
+	}
+
+	return true
+}
+
+// dayAllowed reports whether an overlay's date range and weekdays include
+// the day starting at midnight.
+func dayAllowed(overlay *models.Overlay, midnight time.Time) bool {
+	if overlay.StartDate != nil && midnight.Before(dateOnly(*overlay.StartDate, midnight.Location())) {
+		return false
+	}
+	if overlay.EndDate != nil && midnight.After(dateOnly(*overlay.EndDate, midnight.Location())) {
+		return false
+	}
+
+	days := splitList(overlay.DaysOfWeek)
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if weekday, ok := weekdays[d]; ok && weekday == midnight.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// overlayEnableExpr turns an overlay's date range, weekdays and time-of-day
+// window into an FFmpeg timeline expression for an item that starts at the
+// given wall-clock time and plays for the given duration, so that an item
+// running past midnight picks up the next day's rules. A window that runs
+// past midnight belongs to the day it starts on. ok is false when the
+// overlay is not shown during the item at all; an empty expression means it
+// is shown for the whole item.
+func overlayEnableExpr(overlay *models.Overlay, start time.Time, duration time.Duration) (expr string, ok bool) {
+	if overlay.ActiveFrom == "" && overlay.ActiveUntil == "" && overlay.StartDate == nil &&
+		overlay.EndDate == nil && len(splitList(overlay.DaysOfWeek)) == 0 {
+		return "", true
+	}
+
+	from, err := parseTimeOfDay(overlay.ActiveFrom)
+	if err != nil {
+		from = 0
+	}
+	until, err := parseTimeOfDay(overlay.ActiveUntil)
+	untilMidnight := err != nil || overlay.ActiveUntil == ""
+
+	end := start.Add(duration)
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+
+	var ranges []string
+	covered := time.Duration(0)
+
+	// Windows from the day before the item, which may run past midnight,
+	// to the day it ends on can overlap it
+	for day := first.AddDate(0, 0, -1); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !dayAllowed(overlay, day) {
+			continue
+		}
+
+		windowStart := day.Add(from)
+		windowEnd := day.Add(until)
+		if untilMidnight {
+			// The day may not be 24 hours long
+			windowEnd = day.AddDate(0, 0, 1)
+		} else if until <= from {
+			// Window runs past midnight
+			windowEnd = day.AddDate(0, 0, 1).Add(until)
+		}
+
+		if windowStart.Before(start) {
+			windowStart = start
+		}
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+		if !windowEnd.After(windowStart) {
+			continue
+		}
+
+		covered += windowEnd.Sub(windowStart)
+		ranges = append(ranges, fmt.Sprintf("between(t,%.3f,%.3f)",
+			windowStart.Sub(start).Seconds(), windowEnd.Sub(start).Seconds()))
+	}
+
+	if len(ranges) == 0 {
+		return "", false
+	}
+	if covered >= duration {
+		return "", true
+	}
+	return strings.Join(ranges, "+"), true
+}
+
+// validateOverlayRules checks the activation rules of an overlay.
+func validateOverlayRules(overlay *models.Overlay) error {
+	for _, value := range []string{overlay.ActiveFrom, overlay.ActiveUntil} {
+		if value == "" {
+			continue
+		}
+		if _, err := parseTimeOfDay(value); err != nil {
+			return fmt.Errorf("invalid time of day %q, expected HH:MM or HH:MM:SS", value)
+		}
+	}
+
+	for _, day := range splitList(overlay.DaysOfWeek) {
+		if _, ok := weekdays[day]; !ok {
+			return fmt.Errorf("invalid day of week %q", day)
+		}
+	}
+
+	for _, id := range splitList(overlay.PlaylistItemIDs) {
+		if _, err := strconv.Atoi(id); err != nil {
+			return fmt.Errorf("invalid playlist item ID %q", id)
+		}
+	}
+
+	if overlay.StartDate != nil && overlay.EndDate != nil && overlay.EndDate.Before(*overlay.StartDate) {
+		return fmt.Errorf("end date is before start date")
+	}
+
+	return nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q", value)
+}
+
+func dateOnly(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// splitList splits a comma separated list into lower-cased, trimmed values.
+func splitList(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
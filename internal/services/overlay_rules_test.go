@@ -0,0 +1,153 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func TestOverlayEnableExpr(t *testing.T) {
+	// 14 March 2025 is a Friday
+	tests := []struct {
+		name     string
+		overlay  models.Overlay
+		start    time.Time
+		duration time.Duration
+		want     string
+		wantOK   bool
+	}{
+		{"no rules", models.Overlay{}, at(14, "10:00:00"), time.Hour, "", true},
+		{"window covers item", models.Overlay{ActiveFrom: "09:00", ActiveUntil: "12:00"},
+			at(14, "10:00:00"), time.Hour, "", true},
+		{"window inside item", models.Overlay{ActiveFrom: "10:15", ActiveUntil: "10:30:30"},
+			at(14, "10:00:00"), time.Hour, "between(t,900.000,1830.000)", true},
+		{"window misses item", models.Overlay{ActiveFrom: "18:00", ActiveUntil: "20:00"},
+			at(14, "10:00:00"), time.Hour, "", false},
+		{"open-ended window", models.Overlay{ActiveFrom: "10:30"},
+			at(14, "10:00:00"), time.Hour, "between(t,1800.000,3600.000)", true},
+		{"window wraps midnight, item before", models.Overlay{ActiveFrom: "23:00", ActiveUntil: "01:00"},
+			at(14, "22:30:00"), time.Hour, "between(t,1800.000,3600.000)", true},
+		{"window wraps midnight, item after", models.Overlay{ActiveFrom: "23:00", ActiveUntil: "01:00"},
+			at(15, "00:30:00"), time.Hour, "between(t,0.000,1800.000)", true},
+		{"window wraps midnight twice in a long item", models.Overlay{ActiveFrom: "23:00", ActiveUntil: "01:00"},
+			at(14, "00:00:00"), 48 * time.Hour,
+			"between(t,0.000,3600.000)+between(t,82800.000,90000.000)+between(t,169200.000,172800.000)", true},
+		{"weekday", models.Overlay{DaysOfWeek: "fri"}, at(14, "10:00:00"), time.Hour, "", true},
+		{"other weekday", models.Overlay{DaysOfWeek: "mon, Tue"}, at(14, "10:00:00"), time.Hour, "", false},
+		{"weekday ends at midnight", models.Overlay{DaysOfWeek: "fri"},
+			at(14, "23:30:00"), time.Hour, "between(t,0.000,1800.000)", true},
+		{"weekday starts at midnight", models.Overlay{DaysOfWeek: "sat"},
+			at(14, "23:30:00"), time.Hour, "between(t,1800.000,3600.000)", true},
+		{"consecutive weekdays", models.Overlay{DaysOfWeek: "fri,sat"},
+			at(14, "23:30:00"), time.Hour, "", true},
+		{"wrapping window belongs to its first day", models.Overlay{DaysOfWeek: "fri", ActiveFrom: "23:00", ActiveUntil: "01:00"},
+			at(15, "00:00:00"), 2 * time.Hour, "between(t,0.000,3600.000)", true},
+		{"before start date", models.Overlay{StartDate: date(2025, 3, 15)}, at(14, "10:00:00"), time.Hour, "", false},
+		{"from start date", models.Overlay{StartDate: date(2025, 3, 14)}, at(14, "10:00:00"), time.Hour, "", true},
+		{"start date at midnight", models.Overlay{StartDate: date(2025, 3, 15)},
+			at(14, "23:00:00"), 2 * time.Hour, "between(t,3600.000,7200.000)", true},
+		{"end date at midnight", models.Overlay{EndDate: date(2025, 3, 14)},
+			at(14, "23:00:00"), 2 * time.Hour, "between(t,0.000,3600.000)", true},
+		{"after end date", models.Overlay{EndDate: date(2025, 3, 13)}, at(14, "10:00:00"), time.Hour, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := overlayEnableExpr(&tt.overlay, tt.start, tt.duration)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("overlayEnableExpr = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestOverlayEnableExprOnLongDay(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("no timezone data")
+	}
+
+	// The clocks go back on 26 October 2025, making Sunday 25 hours long
+	start := time.Date(2025, 10, 26, 0, 0, 0, 0, london)
+	overlay := models.Overlay{DaysOfWeek: "sun"}
+	if got, ok := overlayEnableExpr(&overlay, start, 25*time.Hour); got != "" || !ok {
+		t.Errorf("overlayEnableExpr over the whole day = %q, %v; want the whole item", got, ok)
+	}
+}
+
+func TestOverlayApplies(t *testing.T) {
+	item := &models.PlaylistItem{ItemID: 7}
+	news := &models.MediaFile{Tags: "News, live"}
+	ad := &models.MediaFile{Tags: "ad"}
+
+	tests := []struct {
+		name    string
+		overlay models.Overlay
+		item    *models.PlaylistItem
+		media   *models.MediaFile
+		want    bool
+	}{
+		{"no rules", models.Overlay{}, item, news, true},
+		{"item listed", models.Overlay{PlaylistItemIDs: "3, 7"}, item, news, true},
+		{"item not listed", models.Overlay{PlaylistItemIDs: "3"}, item, news, false},
+		{"tag matches", models.Overlay{MediaTags: "sport,news"}, item, news, true},
+		{"tag missing", models.Overlay{MediaTags: "sport"}, item, news, false},
+		{"tags on a stream", models.Overlay{MediaTags: "news"}, item, nil, false},
+		{"hidden during ads", models.Overlay{HideDuringAds: true}, item, ad, false},
+		{"shown outside ads", models.Overlay{HideDuringAds: true}, item, news, true},
+		{"shown on ads", models.Overlay{}, item, ad, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overlayApplies(&tt.overlay, tt.item, tt.media); got != tt.want {
+				t.Errorf("overlayApplies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"06:30", 6*time.Hour + 30*time.Minute, false},
+		{"23:59:59", 24*time.Hour - time.Second, false},
+		{"00:00", 0, false},
+		{"24:00", 0, true},
+		{"6pm", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeOfDay(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseTimeOfDay(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateOverlayRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay models.Overlay
+		wantErr bool
+	}{
+		{"valid", models.Overlay{ActiveFrom: "22:00", ActiveUntil: "02:00", DaysOfWeek: "Mon,fri",
+			PlaylistItemIDs: "1, 2", StartDate: date(2025, 3, 1), EndDate: date(2025, 3, 31)}, false},
+		{"bad time", models.Overlay{ActiveFrom: "25:00"}, true},
+		{"bad weekday", models.Overlay{DaysOfWeek: "monday"}, true},
+		{"bad item ID", models.Overlay{PlaylistItemIDs: "1,two"}, true},
+		{"dates reversed", models.Overlay{StartDate: date(2025, 3, 31), EndDate: date(2025, 3, 1)}, true},
+	}
+	for _, tt := range tests {
+		if err := validateOverlayRules(&tt.overlay); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateOverlayRules = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -2,20 +2,65 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/euacreations/tvheadend/internal/database"
 	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
 )
 
+// ErrInvalidOverlay is returned when an overlay fails validation.
+var ErrInvalidOverlay = errors.New("invalid overlay")
+
+// ErrNotTicker is returned for headline requests on an overlay that is not
+// a ticker.
+var ErrNotTicker = errors.New("overlay is not a ticker")
+
+// overlayStore is what the overlay service needs from the database.
+type overlayStore interface {
+	database.ChannelStore
+	database.MediaStore
+	database.OverlayStore
+	database.LogStore
+}
+
 type OverlayService struct {
-	repo *database.Repository
+	repo      overlayStore
+	listeners []func(channelID int)
+	listenMux sync.RWMutex
 }
 
-func NewOverlayService(repo *database.Repository) *OverlayService {
+func NewOverlayService(repo overlayStore) *OverlayService {
 	return &OverlayService{repo: repo}
 }
 
+// OnChange registers a function that is called whenever the overlays of a
+// channel are created or modified, so running streams can pick them up.
+func (s *OverlayService) OnChange(listener func(channelID int)) {
+	s.listenMux.Lock()
+	defer s.listenMux.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *OverlayService) notifyChange(channelID int) {
+	s.listenMux.RLock()
+	listeners := s.listeners
+	s.listenMux.RUnlock()
+
+	for _, listener := range listeners {
+		listener(channelID)
+	}
+}
+
 func (s *OverlayService) ApplyOverlays(ctx context.Context, channelID int, ffmpegArgs []string) ([]string, error) {
 	overlays, err := s.repo.GetChannelOverlays(ctx, channelID)
 
@@ -59,16 +104,375 @@ func (s *OverlayService) getDynamicText(ctx context.Context, channelID int) stri
 func (s *OverlayService) CreateOverlay(ctx context.Context, overlay *models.Overlay) (*models.Overlay, error) {
 	// Validate input
 	if overlay.ChannelID == 0 {
-		return nil, fmt.Errorf("channel ID is required")
+		return nil, fmt.Errorf("%w: channel ID is required", ErrInvalidOverlay)
+	}
+	setOverlayDefaults(overlay)
+	if err := validateOverlay(overlay); err != nil {
+		return nil, err
+	}
+	if err := s.validateFeed(ctx, overlay); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateOverlay(ctx, overlay); err != nil {
+		return nil, fmt.Errorf("failed to create overlay: %w", err)
+	}
+
+	recordAudit(ctx, s.repo, "overlay_create", "overlay", overlay.OverlayID, sql.NullString{}, marshalAuditValue(overlay))
+	s.notifyChange(overlay.ChannelID)
+
+	return overlay, nil
+}
+
+// UpdateOverlay replaces the overlay with the given ID and applies the change
+// to the channel's running stream.
+func (s *OverlayService) UpdateOverlay(ctx context.Context, overlay *models.Overlay) (*models.Overlay, error) {
+	existing, err := s.repo.GetOverlay(ctx, overlay.OverlayID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overlay: %w", err)
+	}
+
+	// Overlays cannot move between channels
+	overlay.ChannelID = existing.ChannelID
+	setOverlayDefaults(overlay)
+	if err := validateOverlay(overlay); err != nil {
+		return nil, err
+	}
+	if err := s.validateFeed(ctx, overlay); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateOverlay(ctx, overlay); err != nil {
+		return nil, fmt.Errorf("failed to update overlay: %w", err)
+	}
+
+	updated, err := s.repo.GetOverlay(ctx, overlay.OverlayID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload overlay: %w", err)
+	}
+
+	recordAudit(ctx, s.repo, "overlay_update", "overlay", updated.OverlayID, marshalAuditValue(existing), marshalAuditValue(updated))
+	s.notifyChange(updated.ChannelID)
+
+	return updated, nil
+}
+
+// ListOverlays returns all overlays of a channel, including disabled ones,
+// bottom first.
+func (s *OverlayService) ListOverlays(ctx context.Context, channelID int) ([]*models.Overlay, error) {
+	if _, err := s.repo.GetChannelByID(ctx, channelID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAllChannelOverlays(ctx, channelID)
+}
+
+func (s *OverlayService) GetOverlay(ctx context.Context, overlayID int) (*models.Overlay, error) {
+	return s.repo.GetOverlay(ctx, overlayID)
+}
+
+// DeleteOverlay removes an overlay and takes it off the channel's running stream.
+func (s *OverlayService) DeleteOverlay(ctx context.Context, overlayID int) error {
+	existing, err := s.repo.GetOverlay(ctx, overlayID)
+	if err != nil {
+		return fmt.Errorf("failed to get overlay: %w", err)
+	}
+
+	if err := s.repo.DeleteOverlay(ctx, overlayID); err != nil {
+		return err
+	}
+
+	recordAudit(ctx, s.repo, "overlay_delete", "overlay", overlayID, marshalAuditValue(existing), sql.NullString{})
+	s.notifyChange(existing.ChannelID)
+
+	return nil
+}
+
+// ReorderOverlays sets the stacking order of a channel's overlays. overlayIDs
+// must list every overlay of the channel exactly once, bottom first.
+func (s *OverlayService) ReorderOverlays(ctx context.Context, channelID int, overlayIDs []int) ([]*models.Overlay, error) {
+	existing, err := s.ListOverlays(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool)
+	for _, id := range overlayIDs {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: overlay %d listed twice", ErrInvalidOverlay, id)
+		}
+		seen[id] = true
+	}
+	for _, overlay := range existing {
+		if !seen[overlay.OverlayID] {
+			return nil, fmt.Errorf("%w: overlay %d is missing from the order", ErrInvalidOverlay, overlay.OverlayID)
+		}
+	}
+	if len(overlayIDs) != len(existing) {
+		return nil, fmt.Errorf("%w: order lists overlays that do not belong to channel %d", ErrInvalidOverlay, channelID)
+	}
+
+	if err := s.repo.ReorderOverlays(ctx, channelID, overlayIDs); err != nil {
+		return nil, err
+	}
+
+	reordered, err := s.repo.GetAllChannelOverlays(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(ctx, s.repo, "overlay_reorder", "channel", channelID, marshalAuditValue(&existing), marshalAuditValue(&reordered))
+	s.notifyChange(channelID)
+
+	return reordered, nil
+}
+
+// assetExtensions are the files that may be uploaded into a channel's data directory.
+var assetExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true, ".webp": true,
+	".ttf": true, ".otf": true,
+}
+
+// SaveAsset stores an uploaded image or font in <storage_root>/data and
+// returns the path to use as the overlay's file_path or font_file.
+func (s *OverlayService) SaveAsset(ctx context.Context, channelID int, filename string, content io.Reader) (string, error) {
+	channel, err := s.repo.GetChannelByID(ctx, channelID)
+	if err != nil {
+		return "", err
+	}
+
+	name := filepath.Base(filename)
+	if !assetExtensions[strings.ToLower(filepath.Ext(name))] {
+		return "", fmt.Errorf("%w: unsupported asset type %q", ErrInvalidOverlay, filepath.Ext(name))
+	}
+	if err := validateAssetPath("file", name); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidOverlay, err)
+	}
+
+	dataDir := filepath.Join(channel.StorageRoot, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// Write to a temporary file first so a running stream never sees a half-written asset
+	tmp, err := os.CreateTemp(dataDir, ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dataDir, name)); err != nil {
+		return "", fmt.Errorf("failed to store asset: %w", err)
+	}
+
+	return name, nil
+}
+
+// Preview renders a still frame of a media file at the given position with
+// the channel's enabled overlays and the program title composited on top. If
+// overlayIDs is not empty only those overlays are drawn. Activation rules are ignored so that
+// any overlay can be checked regardless of when it is scheduled.
+func (s *OverlayService) Preview(ctx context.Context, channelID int, mediaID int, position time.Duration, overlayIDs []int) ([]byte, error) {
+	channel, err := s.repo.GetChannelByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := s.repo.GetMediaFile(ctx, sql.NullInt64{Int64: int64(mediaID), Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	if media.ChannelID != channelID {
+		return nil, fmt.Errorf("%w: media %d does not belong to channel %d", ErrInvalidOverlay, mediaID, channelID)
+	}
+
+	overlays, err := s.repo.GetChannelOverlays(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overlays: %w", err)
+	}
+
+	config := ffmpeg.PreviewConfig{
+		InputPath:        filepath.Join(channel.StorageRoot, "media", media.FilePath),
+		Position:         position,
+		OutputResolution: channel.OutputResolution,
+	}
+	for _, overlay := range overlays {
+		if len(overlayIDs) > 0 && !containsInt(overlayIDs, overlay.OverlayID) {
+			continue
+		}
+		o := resolveOverlay(channel, overlay)
+		if o.Type == ffmpeg.OverlayTypeTicker {
+			if headlines, err := s.repo.GetTickerHeadlines(ctx, overlay.OverlayID); err == nil && len(headlines) > 0 {
+				o.Text = tickerText(headlines)
+			}
+		}
+		config.Overlays = append(config.Overlays, o)
+	}
+
+	if len(overlayIDs) == 0 {
+		title, err := programTitleOverlay(ctx, s.repo, channel, media)
+		if err != nil {
+			return nil, err
+		}
+		if title != nil {
+			config.Overlays = append(config.Overlays, *title)
+		}
+	}
+
+	return ffmpeg.RenderPreview(ctx, config)
+}
+
+// resolveOverlay returns a copy of an overlay with its asset paths resolved
+// against the channel's data directory.
+func resolveOverlay(channel *models.Channel, overlay *models.Overlay) models.Overlay {
+	o := *overlay
+	o.FilePath = filepath.Join(channel.StorageRoot, "data", overlay.FilePath)
+	if overlay.FontFile != "" {
+		o.FontFile = filepath.Join(channel.StorageRoot, "data", overlay.FontFile)
+	}
+	return o
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHeadlines returns the headlines crawled by a ticker overlay.
+func (s *OverlayService) GetHeadlines(ctx context.Context, overlayID int) ([]*models.TickerHeadline, error) {
+	overlay, err := s.repo.GetOverlay(ctx, overlayID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overlay: %w", err)
+	}
+	if overlay.Type != "ticker" {
+		return nil, fmt.Errorf("%w: overlay %d", ErrNotTicker, overlayID)
+	}
+	return s.repo.GetTickerHeadlines(ctx, overlayID)
+}
+
+// SetHeadlines replaces the headlines of a ticker overlay and pushes them to
+// the channel's running stream.
+func (s *OverlayService) SetHeadlines(ctx context.Context, overlayID int, headlines []string) ([]*models.TickerHeadline, error) {
+	overlay, err := s.repo.GetOverlay(ctx, overlayID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overlay: %w", err)
+	}
+	if overlay.Type != "ticker" {
+		return nil, fmt.Errorf("%w: overlay %d", ErrNotTicker, overlayID)
+	}
+
+	var cleaned []string
+	for _, headline := range headlines {
+		if headline = strings.TrimSpace(headline); headline != "" {
+			cleaned = append(cleaned, headline)
+		}
+	}
+
+	existing, err := s.repo.GetTickerHeadlines(ctx, overlayID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceTickerHeadlines(ctx, overlayID, cleaned); err != nil {
+		return nil, fmt.Errorf("failed to store headlines: %w", err)
+	}
+	updated, err := s.repo.GetTickerHeadlines(ctx, overlayID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(ctx, s.repo, "ticker_headlines_update", "overlay", overlayID, marshalAuditValue(&existing), marshalAuditValue(&updated))
+	s.notifyChange(overlay.ChannelID)
+
+	return updated, nil
+}
+
+// validateFeed checks that a ticker's file feed lies within its channel's
+// storage root, so that the API cannot be used to read other files.
+func (s *OverlayService) validateFeed(ctx context.Context, overlay *models.Overlay) error {
+	if overlay.Type != "ticker" || overlay.FeedURL == "" {
+		return nil
+	}
+	u, err := url.Parse(overlay.FeedURL)
+	if err != nil || u.Scheme != "file" {
+		return nil
+	}
+
+	channel, err := s.repo.GetChannelByID(ctx, overlay.ChannelID)
+	if err != nil {
+		return fmt.Errorf("failed to get channel: %w", err)
+	}
+	if _, err := feedFilePath(channel.StorageRoot, u.Path); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOverlay, err)
+	}
+	return nil
+}
+
+func validateOverlay(overlay *models.Overlay) error {
+	if err := checkOverlay(overlay); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOverlay, err)
+	}
+	return nil
+}
+
+func checkOverlay(overlay *models.Overlay) error {
+	switch overlay.Type {
+	case "image":
+		if overlay.FilePath == "" {
+			return fmt.Errorf("file path is required for image overlays")
+		}
+	case "text":
+	case "ticker":
+		if overlay.Speed < 0 {
+			return fmt.Errorf("ticker speed must be positive")
+		}
+		if overlay.FeedURL != "" {
+			u, err := url.Parse(overlay.FeedURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+				return fmt.Errorf("ticker feed URL must be an http, https or file URL")
+			}
+		}
+		if err := validateColor("bg_color", overlay.BackgroundColor); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid overlay type")
+	}
+
+	if err := validateExpression("position_x", overlay.PositionX); err != nil {
+		return err
 	}
-	if overlay.Type != "image" && overlay.Type != "text" {
-		return nil, fmt.Errorf("invalid overlay type")
+	if err := validateExpression("position_y", overlay.PositionY); err != nil {
+		return err
 	}
-	if overlay.Type == "image" && overlay.FilePath == "" {
-		return nil, fmt.Errorf("file path is required for image overlays")
+	if overlay.Type != "image" {
+		if err := validateExpression("font_size", overlay.FontSize); err != nil {
+			return err
+		}
+		if err := validateColor("font_color", overlay.FontColor); err != nil {
+			return err
+		}
+	}
+	if err := validateAssetPath("file_path", overlay.FilePath); err != nil {
+		return err
+	}
+	if err := validateAssetPath("font_file", overlay.FontFile); err != nil {
+		return err
 	}
 
-	// Set defaults
+	return validateOverlayRules(overlay)
+}
+
+func setOverlayDefaults(overlay *models.Overlay) {
 	if overlay.PositionX == "" && overlay.PositionY == "" {
 		overlay.PositionX = "10"
 		overlay.PositionY = "10"
@@ -79,9 +483,13 @@ func (s *OverlayService) CreateOverlay(ctx context.Context, overlay *models.Over
 	if overlay.FontColor == "" {
 		overlay.FontColor = "white"
 	}
-
-	if err := s.repo.CreateOverlay(ctx, overlay); err != nil {
-		return nil, fmt.Errorf("failed to create overlay: %w", err)
+	if overlay.BackgroundColor == "" {
+		overlay.BackgroundColor = "black@0.6"
+	}
+	if overlay.Speed == 0 {
+		overlay.Speed = 100
+	}
+	if overlay.FeedInterval <= 0 {
+		overlay.FeedInterval = 300
 	}
-	return overlay, nil
 }
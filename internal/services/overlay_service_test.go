@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func TestReorderOverlays(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+
+	channel := &models.Channel{ChannelName: "one", StorageRoot: t.TempDir(), StartTimeStr: "00:00:00"}
+	if err := store.UpdateChannel(ctx, channel); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewOverlayService(store)
+	var changed []int
+	service.OnChange(func(channelID int) { changed = append(changed, channelID) })
+
+	var ids []int
+	for _, text := range []string{"one", "two", "three"} {
+		overlay, err := service.CreateOverlay(ctx, &models.Overlay{
+			ChannelID: channel.ChannelID,
+			Enabled:   true,
+			Type:      "text",
+			Text:      text,
+		})
+		if err != nil {
+			t.Fatalf("CreateOverlay: %v", err)
+		}
+		ids = append(ids, overlay.OverlayID)
+	}
+
+	reordered, err := service.ReorderOverlays(ctx, channel.ChannelID, []int{ids[2], ids[0], ids[1]})
+	if err != nil {
+		t.Fatalf("ReorderOverlays: %v", err)
+	}
+	for i, want := range []int{ids[2], ids[0], ids[1]} {
+		if reordered[i].OverlayID != want || reordered[i].ZIndex != i+1 {
+			t.Errorf("overlay %d = id %d z %d, want id %d z %d", i, reordered[i].OverlayID, reordered[i].ZIndex, want, i+1)
+		}
+	}
+	if len(changed) != 4 {
+		t.Errorf("change listeners called %d times, want 4", len(changed))
+	}
+
+	invalid := [][]int{
+		{ids[0], ids[1]},                 // Missing one
+		{ids[0], ids[1], ids[1]},         // Listed twice
+		{ids[0], ids[1], ids[2], ids[2]}, // Listed twice, all present
+		{ids[0], ids[1], ids[2], 999},    // Not on the channel
+	}
+	for _, order := range invalid {
+		if _, err := service.ReorderOverlays(ctx, channel.ChannelID, order); !errors.Is(err, ErrInvalidOverlay) {
+			t.Errorf("ReorderOverlays(%v) error = %v, want ErrInvalidOverlay", order, err)
+		}
+	}
+
+	// Audit entries were recorded for every change
+	logs, err := store.GetAuditLogs(ctx, models.AuditFilter{TargetType: "overlay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 3 {
+		t.Errorf("overlay audit entries = %d, want 3", len(logs))
+	}
+}
+
+func TestTickerHeadlinesAndFeeds(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+
+	channel := &models.Channel{ChannelName: "one", StorageRoot: t.TempDir(), StartTimeStr: "00:00:00"}
+	if err := store.UpdateChannel(ctx, channel); err != nil {
+		t.Fatal(err)
+	}
+	service := NewOverlayService(store)
+
+	text, err := service.CreateOverlay(ctx, &models.Overlay{ChannelID: channel.ChannelID, Type: "text", Text: "hello"})
+	if err != nil {
+		t.Fatalf("CreateOverlay: %v", err)
+	}
+	if _, err := service.GetHeadlines(ctx, text.OverlayID); !errors.Is(err, ErrNotTicker) {
+		t.Errorf("GetHeadlines on a text overlay = %v, want ErrNotTicker", err)
+	}
+	if _, err := service.SetHeadlines(ctx, text.OverlayID, []string{"news"}); !errors.Is(err, ErrNotTicker) {
+		t.Errorf("SetHeadlines on a text overlay = %v, want ErrNotTicker", err)
+	}
+
+	// File feeds are confined to the channel's storage root
+	feeds := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"file://" + filepath.Join(channel.StorageRoot, "feeds", "news.xml"), false},
+		{"file:///etc/passwd", true},
+		{"file://" + filepath.Join(channel.StorageRoot, "..", "other", "news.xml"), true},
+		{"https://example.com/news.xml", false},
+	}
+	for _, feed := range feeds {
+		_, err := service.CreateOverlay(ctx, &models.Overlay{ChannelID: channel.ChannelID, Type: "ticker", FeedURL: feed.url})
+		if feed.wantErr && !errors.Is(err, ErrInvalidOverlay) {
+			t.Errorf("CreateOverlay with feed %s = %v, want ErrInvalidOverlay", feed.url, err)
+		}
+		if !feed.wantErr && err != nil {
+			t.Errorf("CreateOverlay with feed %s: %v", feed.url, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Variables and functions that may appear in overlay position and size
+// expressions. Anything else is rejected so that a typo is caught when the
+// overlay is saved rather than when FFmpeg refuses to start on air.
+var (
+	expressionVariables = map[string]bool{
+		"W": true, "H": true, "w": true, "h": true,
+		"main_w": true, "main_h": true, "overlay_w": true, "overlay_h": true,
+		"text_w": true, "text_h": true, "tw": true, "th": true,
+		"line_h": true, "lh": true, "max_glyph_w": true, "max_glyph_h": true,
+		"x": true, "y": true, "t": true, "n": true, "PI": true, "E": true,
+	}
+	expressionFunctions = map[string]bool{
+		"abs": true, "min": true, "max": true, "mod": true, "if": true, "ifnot": true,
+		"between": true, "lt": true, "lte": true, "gt": true, "gte": true, "eq": true,
+		"not": true, "floor": true, "ceil": true, "round": true, "trunc": true,
+		"sqrt": true, "pow": true, "sin": true, "cos": true, "tan": true, "clip": true,
+		"random": true, "hypot": true,
+	}
+
+	expressionToken = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*|[0-9]*\.?[0-9]+|[-+*/(),\s]`)
+	colorPattern    = regexp.MustCompile(`^(#[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?|0x[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?|[A-Za-z]+)(@[0-9.]+)?$`)
+)
+
+// validateExpression checks that an FFmpeg expression only uses known
+// variables and functions and has balanced parentheses.
+func validateExpression(field, expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+
+	depth := 0
+	rest := expr
+	for rest != "" {
+		loc := expressionToken.FindStringIndex(rest)
+		if loc == nil || loc[0] != 0 {
+			return fmt.Errorf("%s: unexpected character %q in %q", field, rest[0], expr)
+		}
+		token := rest[:loc[1]]
+		rest = rest[loc[1]:]
+
+		switch {
+		case token == "(":
+			depth++
+		case token == ")":
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("%s: unbalanced parentheses in %q", field, expr)
+			}
+		case isIdentStart(token[0]):
+			isCall := strings.HasPrefix(strings.TrimLeft(rest, " "), "(")
+			if isCall && !expressionFunctions[token] {
+				return fmt.Errorf("%s: unknown function %q in %q", field, token, expr)
+			}
+			if !isCall && !expressionVariables[token] {
+				return fmt.Errorf("%s: unknown variable %q in %q", field, token, expr)
+			}
+		}
+	}
+
+	if depth != 0 {
+		return fmt.Errorf("%s: unbalanced parentheses in %q", field, expr)
+	}
+	return nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// validateColor accepts FFmpeg color names and hex values, with an optional
+// @alpha suffix.
+func validateColor(field, color string) error {
+	if !colorPattern.MatchString(color) {
+		return fmt.Errorf("%s: invalid color %q", field, color)
+	}
+	return nil
+}
+
+// validateAssetPath checks that an asset path stays inside the channel's data
+// directory and cannot break out of the FFmpeg filter graph quoting.
+func validateAssetPath(field, path string) error {
+	if path == "" {
+		return nil
+	}
+	if filepath.IsAbs(path) || strings.ContainsAny(path, "'\\:;[],") {
+		return fmt.Errorf("%s: invalid path %q", field, path)
+	}
+	clean := filepath.Clean(path)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("%s: path %q leaves the data directory", field, path)
+	}
+	return nil
+}
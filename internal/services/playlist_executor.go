@@ -3,59 +3,126 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
+	"github.com/euacreations/tvheadend/internal/config"
 	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/metrics"
 	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/clock"
 	"github.com/euacreations/tvheadend/pkg/ffmpeg"
 )
 
+// errRestartItem is returned by playItem when the current item has to be
+// restarted from its current position, e.g. because its overlays changed.
+var errRestartItem = errors.New("item restart requested")
+
+// playoutStore is what a channel's playout needs from the database.
+type playoutStore interface {
+	database.StateStore
+	database.MediaStore
+	database.PlaylistStore
+	database.OverlayStore
+	database.AsRunStore
+}
+
+// PlaylistExecutor plays out one channel. It owns the channel's FFmpeg
+// runner, and all of its state belongs to the goroutine running Execute:
+// other goroutines act on the playout by sending it commands (see Skip,
+// Seek and ReloadOverlays) and only read the runner's progress.
 type PlaylistExecutor struct {
-	repo       *database.Repository
-	ffmpeg     *ffmpeg.Streamer
+	repo       playoutStore
+	settings   *config.Settings
+	ffmpeg     ffmpeg.Runner
+	clock      clock.Clock
+	location   *time.Location // Timezone of the channel's schedule
+	events     *events.Bus
 	mediaCache map[sql.NullInt64]*models.MediaFile
-	cacheMux   sync.RWMutex
-	//positionUpdateMux sync.Mutex
+	commands   chan command
+	done       chan struct{} // Closed when Execute returns
+	// Overlays of the item on air, used to apply overlay changes live
+	live struct {
+		channel   *models.Channel
+		item      *models.PlaylistItem
+		startedAt time.Time
+		duration  time.Duration
+		layout    string
+	}
 	currentState struct {
 		playlist      *models.Playlist
 		items         []*models.PlaylistItem
 		currentIndex  int
+		current       *models.PlaylistItem // Item on air, kept when items is refreshed
 		startOffset   int
+		stallRestarts int // Watchdog restarts of the current item
 		nextIndex     int
 		playlistStart time.Time
+		asRun         *models.AsRunEntry // As-run entry of the item on air
+		slated        bool               // The slate is on air, the current item paused
 		streamCancel  context.CancelFunc
-		streamMux     sync.Mutex
 	}
 }
 
-func NewPlaylistExecutor(repo *database.Repository, ffmpeg *ffmpeg.Streamer) *PlaylistExecutor {
+func NewPlaylistExecutor(repo playoutStore, settings *config.Settings, ffmpeg ffmpeg.Runner, bus *events.Bus) *PlaylistExecutor {
 	return &PlaylistExecutor{
 		repo:       repo,
+		settings:   settings,
 		ffmpeg:     ffmpeg,
+		clock:      clock.Real(),
+		location:   time.Local,
+		events:     bus,
 		mediaCache: make(map[sql.NullInt64]*models.MediaFile),
+		commands:   make(chan command),
+		done:       make(chan struct{}),
 	}
 }
+
+// Execute plays the channel out until ctx is cancelled, then stops FFmpeg.
+// An executor runs once.
 func (e *PlaylistExecutor) Execute(ctx context.Context, channel *models.Channel) error {
+	defer close(e.done)
 	if err := e.initializePlaylist(ctx, channel); err != nil {
 		return fmt.Errorf("playlist initialization failed: %w", err)
 	}
+	defer e.cleanup(channel)
 
 	for {
 		select {
 		case <-ctx.Done():
-			e.cleanup()
 			return nil
 		default:
+			if e.currentState.slated {
+				nextDayStart := calculateNextDayStart(e.now(), channel.StartTime)
+				if err := e.playSlate(ctx, channel); err != nil {
+					if ctx.Err() != nil {
+						return nil // Stopped
+					}
+					return fmt.Errorf("slate failed: %w", err)
+				}
+
+				// The paused item belongs to a day that is over
+				if !e.clock.Now().Before(nextDayStart) {
+					e.currentState.startOffset = 0
+					if err := e.transitionToNextPlaylist(ctx, channel); err != nil {
+						return fmt.Errorf("playlist transition failed: %w", err)
+					}
+				}
+				continue
+			}
+
 			// Calculate time until next day's playlist starts
-			nextDayStart := calculateNextDayStart(time.Now(), channel.StartTime)
-			timeUntilTransition := time.Until(nextDayStart)
-			currentItem := e.currentState.items[e.currentState.currentIndex]
+			nextDayStart := calculateNextDayStart(e.now(), channel.StartTime)
+			timeUntilTransition := e.clock.Until(nextDayStart)
+			currentItem := e.currentState.current
 
 			// Get duration based on item type
 			var maxDuration int
@@ -113,36 +180,28 @@ func (e *PlaylistExecutor) Execute(ctx context.Context, channel *models.Channel)
 				maxDuration = int(timeUntilTransition.Seconds())
 			}
 
-			// Queue next item while playing current
-			prepDone := make(chan struct{})
-			go func() {
-				defer close(prepDone)
-				e.unlockItem(currentItem)
-
-				// Re-fetch playlist if needed
-				items, err := e.repo.GetPlaylistItems(ctx, e.currentState.playlist.PlaylistID)
-				if err == nil && len(items) > 0 {
-					e.currentState.items = items
-				}
-
-				// Prepare next item
-				nextIndex := (e.currentState.currentIndex + 1) % len(e.currentState.items)
-				e.currentState.nextIndex = nextIndex
-				e.lockItem(e.currentState.items[nextIndex])
-			}()
+			// Queue next item before playing current
+			e.prepareNext(ctx, currentItem)
 
 			// Play current item
 			err = e.playItem(ctx, channel, currentItem, inputPath, e.currentState.startOffset, maxDuration)
-			e.currentState.startOffset = 0
 
-			<-prepDone // Ensure next item was prepared
+			if errors.Is(err, errRestartItem) {
+				// playItem recorded where to resume; play the same item again
+				continue
+			}
+			e.currentState.startOffset = 0
+			e.currentState.stallRestarts = 0
 
 			if err != nil {
+				if ctx.Err() != nil {
+					return nil // Stopped
+				}
 				return fmt.Errorf("playback failed: %w", err)
 			}
 
 			// Check if it's time to transition
-			if time.Now().After(nextDayStart) {
+			if !e.clock.Now().Before(nextDayStart) {
 				if err := e.transitionToNextPlaylist(ctx, channel); err != nil {
 					return fmt.Errorf("playlist transition failed: %w", err)
 				}
@@ -151,33 +210,74 @@ func (e *PlaylistExecutor) Execute(ctx context.Context, channel *models.Channel)
 
 			// Move to next item
 			e.currentState.currentIndex = e.currentState.nextIndex
+			e.currentState.current = e.currentState.items[e.currentState.nextIndex]
 		}
 	}
 }
 
+// prepareNext picks up changes to the playlist and locks the item that
+// plays after the current one.
+func (e *PlaylistExecutor) prepareNext(ctx context.Context, currentItem *models.PlaylistItem) {
+	e.unlockItem(currentItem)
+
+	items, err := e.repo.GetPlaylistItems(ctx, e.currentState.playlist.PlaylistID)
+	if err == nil && len(items) > 0 {
+		e.currentState.items = items
+	}
+
+	// The refresh may have moved or removed the current item
+	items = e.currentState.items
+	nextIndex := 0
+	if index := itemIndex(items, currentItem.ItemID); index >= 0 {
+		e.currentState.currentIndex = index
+		nextIndex = (index + 1) % len(items)
+	} else if e.currentState.currentIndex < len(items) {
+		// Whatever took the removed item's place plays next
+		nextIndex = e.currentState.currentIndex
+	}
+	e.currentState.nextIndex = nextIndex
+	e.lockItem(e.currentState.items[nextIndex])
+}
+
 func (e *PlaylistExecutor) initializePlaylist(ctx context.Context, channel *models.Channel) error {
-	effectiveDate := calculateEffectiveDate(time.Now(), channel.StartTime)
+	location, err := channelLocation(channel)
+	if err != nil {
+		return err
+	}
+	e.location = location
+
+	dayStart := calculateEffectiveDate(e.now(), channel.StartTime)
+	effectiveDate := dayStart
 
 	playlist, err := e.repo.GetPlaylistForDate(ctx, channel.ChannelID, effectiveDate)
 
-	maxFallbackDays := 7
+	maxFallbackDays := e.settings.Int("max_playlist_fallback_days")
 	daysTried := 0
-	if val, ok := os.LookupEnv("MAX_PLAYLIST_FALLBACK_DAYS"); ok {
-		if parsed, err := strconv.Atoi(val); err == nil {
-			maxFallbackDays = parsed
-		}
-	}
 
 	for err != nil {
 		if !channel.UsePreviousDayFallback || daysTried >= maxFallbackDays {
 			return fmt.Errorf("no playlist found after fallback attempts: %w", err)
 		}
 
-		effectiveDate = effectiveDate.Add(-24 * time.Hour)
+		effectiveDate = effectiveDate.AddDate(0, 0, -1)
 		daysTried++
 		playlist, err = e.repo.GetPlaylistForDate(ctx, channel.ChannelID, effectiveDate)
 	}
 
+	if daysTried > 0 {
+		e.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.PlaylistFallback,
+			Severity:  events.SeverityWarning,
+			Category:  events.CategoryPlaylist,
+			Message:   fmt.Sprintf("No playlist for today, using playlist %d from %d days ago", playlist.PlaylistID, daysTried),
+			Details: map[string]interface{}{
+				"playlist_id": playlist.PlaylistID,
+				"days_back":   daysTried,
+			},
+		})
+	}
+
 	items, err := e.repo.GetPlaylistItems(ctx, playlist.PlaylistID)
 	if err != nil {
 		return fmt.Errorf("failed to get items: %w", err)
@@ -187,12 +287,17 @@ func (e *PlaylistExecutor) initializePlaylist(ctx context.Context, channel *mode
 		return fmt.Errorf("empty playlist")
 	}
 
-	startIndex, startOffset := e.calculateStartPosition(ctx, items, effectiveDate)
+	// A fallback playlist plays as if it had been scheduled for today
+	startIndex, startOffset, err := e.startPosition(ctx, channel, playlist, items, dayStart)
+	if err != nil {
+		return err
+	}
 	//fmt.Printf("Starting playback from item %d with offset %d seconds\n", startIndex, startOffset)
 	e.currentState.playlist = playlist
 	e.currentState.items = items
 	e.currentState.currentIndex = startIndex
-	e.currentState.playlistStart = effectiveDate
+	e.currentState.current = items[startIndex]
+	e.currentState.playlistStart = dayStart
 	e.currentState.startOffset = startOffset
 
 	// Lock initial items
@@ -206,17 +311,132 @@ func (e *PlaylistExecutor) initializePlaylist(ctx context.Context, channel *mode
 func (e *PlaylistExecutor) playItem(ctx context.Context, channel *models.Channel,
 	item *models.PlaylistItem, inputPath string, offset int, maxDuration int) error {
 
-	e.currentState.streamMux.Lock()
-	defer e.currentState.streamMux.Unlock()
-
 	// Reset the streamer before starting new stream
 	e.ffmpeg.Reset()
 	e.ffmpeg.SetProgressCallback(nil)
 
 	// Build FFmpeg config
-	config := ffmpeg.StreamConfig{
+	config := streamConfig(channel, inputPath, item.Type, offset, maxDuration)
+	config.Overlays = e.buildOverlays(ctx, channel, item, time.Duration(maxDuration)*time.Second)
+	config.AudioFilter = e.audioFilter(ctx, channel, item)
+	config.AudioLanguages = audioLanguages(channel)
+	config.PassSubtitles = channel.PassSubtitles
+	if channel.BurnSubtitles && item.Type == models.PlaylistItemTypeMedia {
+		config.SubtitleFile = sidecarSubtitles(inputPath)
+	}
+
+	// Create cancelable context
+	streamCtx, cancel := context.WithCancel(ctx)
+	e.currentState.streamCancel = cancel
+
+	e.ffmpeg.SetProgressCallback(func(progress ffmpeg.Progress) {
+		state := &models.ChannelState{
+			ChannelID:         channel.ChannelID,
+			CurrentPlaylistID: item.PlaylistID,
+			CurrentItemID:     item.ItemID,
+			CurrentPosition:   progress.Position,
+			LastUpdateTime:    e.clock.Now(),
+			Running:           true,
+			FFmpegPID:         e.ffmpeg.PID(),
+		}
+
+		if err := e.repo.UpdateChannelState(context.Background(), state); err != nil {
+			log.Printf("Failed to update position: %v", err)
+		}
+
+		e.events.Notify(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.Position,
+			Category:  events.CategoryChannel,
+			Details: map[string]interface{}{
+				"playlist_id": item.PlaylistID,
+				"item_id":     item.ItemID,
+				"position":    progress.Position,
+				"duration":    maxDuration,
+				"progress":    progress,
+			},
+		})
+	})
+
+	// Start FFmpeg stream
+	if err := e.ffmpeg.Start(streamCtx, config); err != nil {
+		return err
+	}
+
+	// Update channel state
+	state := &models.ChannelState{
+		ChannelID:         channel.ChannelID,
+		CurrentPlaylistID: item.PlaylistID,
+		CurrentItemID:     item.ItemID,
+		CurrentPosition:   float64(offset),
+		Running:           true,
+		FFmpegPID:         e.ffmpeg.PID(),
+		LastUpdateTime:    e.clock.Now(),
+	}
+	if err := e.repo.UpdateChannelState(ctx, state); err != nil {
+		e.ffmpeg.Stop()
+		return fmt.Errorf("state update failed: %w", err)
+	}
+
+	e.startAsRun(channel, item, offset)
+	metrics.ItemTransitions.Inc(strconv.Itoa(channel.ChannelID))
+	e.events.Publish(events.Event{
+		ChannelID: channel.ChannelID,
+		Type:      events.ItemStarted,
+		Category:  events.CategoryPlaylist,
+		Message:   fmt.Sprintf("Playing item %d (%s) from %ds", item.ItemID, filepath.Base(inputPath), offset),
+		Details: map[string]interface{}{
+			"playlist_id": item.PlaylistID,
+			"item_id":     item.ItemID,
+			"type":        item.Type,
+			"input":       inputPath,
+			"offset":      offset,
+			"duration":    maxDuration,
+			"pid":         e.ffmpeg.PID(),
+		},
+	})
+
+	watchdog := newWatchdog(e.settings, e.clock.Now())
+	healthCheck := e.clock.NewTicker(watchdog.interval)
+	defer healthCheck.Stop()
+
+	// Wait for completion, context cancellation or a command
+	for {
+		select {
+		case <-streamCtx.Done():
+			e.endAsRun(models.AsRunStopped)
+			return streamCtx.Err()
+		case <-e.ffmpeg.Done():
+			e.publishExit(channel, item)
+			switch {
+			case streamCtx.Err() != nil:
+				e.endAsRun(models.AsRunStopped)
+			case e.ffmpeg.ExitCode() != 0:
+				e.endAsRun(models.AsRunFailed)
+			default:
+				e.endAsRun(models.AsRunCompleted)
+			}
+			return nil
+		case cmd := <-e.commands:
+			if done, err := e.handleCommand(ctx, channel, item, cmd, cancel); done {
+				return err
+			}
+		case now := <-healthCheck.C():
+			reason := watchdog.check(e.ffmpeg.Progress(), now)
+			if reason == "" {
+				continue
+			}
+			return e.handleStall(channel, item, reason, watchdog.maxRestarts, cancel)
+		}
+	}
+}
+
+// streamConfig returns the FFmpeg configuration that plays an input out on
+// a channel.
+func streamConfig(channel *models.Channel, inputPath string, inputType models.PlaylistItemType, offset, maxDuration int) ffmpeg.StreamConfig {
+	return ffmpeg.StreamConfig{
 		InputPath:               inputPath,
-		InputType:               item.Type,
+		InputType:               inputType,
 		OutputURL:               channel.OutputUDP,
 		StartOffset:             time.Duration(offset) * time.Second,
 		Duration:                time.Duration(maxDuration) * time.Second,
@@ -237,97 +457,309 @@ func (e *PlaylistExecutor) playItem(ctx context.Context, channel *models.Channel
 		MetadataServiceProvider: channel.MetadataServiceProvider,
 		MmetadataServiceName:    channel.ChannelName,
 	}
+}
 
-	// Add overlays
-	overlays, _ := e.repo.GetChannelOverlays(ctx, channel.ChannelID)
-	for _, overlay := range overlays {
-		config.Overlays = append(config.Overlays, models.Overlay{
-			Type:      overlay.Type,
-			FilePath:  filepath.Join(channel.StorageRoot, "data", overlay.FilePath),
-			Text:      overlay.Text,
-			PositionX: overlay.PositionX,
-			PositionY: overlay.PositionY,
-			FontSize:  overlay.FontSize,
-			FontColor: overlay.FontColor,
-		})
+// restartItem stops the item on air so that Execute plays it again from the
+// current position.
+func (e *PlaylistExecutor) restartItem(channel *models.Channel, item *models.PlaylistItem, cancel context.CancelFunc) error {
+	if item.Type == models.PlaylistItemTypeMedia {
+		e.currentState.startOffset = int(e.ffmpeg.Position())
+	}
+	cancel()
+	e.endAsRun(models.AsRunRestarted)
+	metrics.FFmpegRestarts.Inc(strconv.Itoa(channel.ChannelID))
+	return errRestartItem
+}
+
+// handleStall restarts an item the watchdog found stalled, or skips it once
+// it has stalled too often.
+func (e *PlaylistExecutor) handleStall(channel *models.Channel, item *models.PlaylistItem, reason string, maxRestarts int, cancel context.CancelFunc) error {
+	e.currentState.stallRestarts++
+	skip := e.currentState.stallRestarts > maxRestarts
+
+	action := "restarting item"
+	severity := events.SeverityWarning
+	if skip {
+		action = "skipping item"
+		severity = events.SeverityError
+	}
+
+	e.events.Publish(events.Event{
+		ChannelID: channel.ChannelID,
+		Type:      events.StreamStalled,
+		Severity:  severity,
+		Category:  events.CategoryChannel,
+		Message:   fmt.Sprintf("Item %d stalled (%s), %s", item.ItemID, reason, action),
+		Details: map[string]interface{}{
+			"playlist_id": item.PlaylistID,
+			"item_id":     item.ItemID,
+			"reason":      reason,
+			"position":    e.ffmpeg.Position(),
+			"progress":    e.ffmpeg.Progress(),
+			"restarts":    e.currentState.stallRestarts,
+			"skipped":     skip,
+		},
+	})
+
+	if skip {
+		cancel()
+		e.ffmpeg.Reset()
+		e.endAsRun(models.AsRunSkipped)
+		return nil
+	}
+	return e.restartItem(channel, item, cancel)
+}
+
+// audioFilter returns the channel's loudness normalization for an item. Media
+// files measured during scanning get a fixed gain, anything else is
+// normalized as it plays.
+func (e *PlaylistExecutor) audioFilter(ctx context.Context, channel *models.Channel, item *models.PlaylistItem) string {
+	if !channel.LoudnessTarget.Valid {
+		return ""
+	}
+	target := ffmpeg.LoudnessTarget{
+		Integrated: channel.LoudnessTarget.Float64,
+		TruePeak:   channel.LoudnessTruePeak,
 	}
 
-	// Add program name overlay if this is a media file
 	if item.Type == models.PlaylistItemTypeMedia {
 		media, err := e.getMediaFile(ctx, item.MediaID)
-		if err == nil && media.ProgramName.String != "" {
-			programNameOverlay := models.Overlay{
-				Type:      "text",
-				Text:      media.ProgramName.String,
-				PositionX: "W/12",
-				PositionY: "H/12",
-				FontSize:  "H/30",
-				FontColor: "white",
-				FontFile:  filepath.Join(channel.StorageRoot, "data", "FM-Malithi-x.ttf"),
-			}
-			config.Overlays = append(config.Overlays, programNameOverlay)
+		if err == nil && media.IntegratedLoudness.Valid && media.TruePeak.Valid {
+			return ffmpeg.LoudnessFilter(target, &ffmpeg.Loudness{
+				Integrated: media.IntegratedLoudness.Float64,
+				TruePeak:   media.TruePeak.Float64,
+			})
 		}
 	}
+	return ffmpeg.LoudnessFilter(target, nil)
+}
 
-	// Create cancelable context
-	streamCtx, cancel := context.WithCancel(ctx)
-	e.currentState.streamCancel = cancel
+// audioLanguages returns the channel's valid ISO 639-2 audio language codes.
+func audioLanguages(channel *models.Channel) []string {
+	var languages []string
+	for _, language := range strings.Split(channel.AudioLanguages.String, ",") {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if languageCode.MatchString(language) {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
 
-	e.ffmpeg.SetProgressCallback(func(position float64) {
-		state := &models.ChannelState{
-			ChannelID:         channel.ChannelID,
-			CurrentPlaylistID: item.PlaylistID,
-			CurrentItemID:     item.ItemID,
-			CurrentPosition:   position,
-			LastUpdateTime:    time.Now(),
-			Running:           true,
-			FFmpegPID:         e.ffmpeg.PID(),
+var languageCode = regexp.MustCompile(`^[a-z]{3}$`)
+
+// sidecarSubtitles returns the SRT file next to a media file, if there is
+// one whose path can be used in a filter graph.
+func sidecarSubtitles(inputPath string) string {
+	path := strings.TrimSuffix(inputPath, filepath.Ext(inputPath)) + ".srt"
+	if strings.ContainsAny(path, "'\\") {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// publishExit records how FFmpeg exited at the end of an item.
+func (e *PlaylistExecutor) publishExit(channel *models.Channel, item *models.PlaylistItem) {
+	code := e.ffmpeg.ExitCode()
+	severity := events.SeverityInfo
+	if code != 0 {
+		severity = events.SeverityWarning
+		metrics.FFmpegFailures.Inc(strconv.Itoa(channel.ChannelID))
+	}
+
+	e.events.Publish(events.Event{
+		ChannelID: channel.ChannelID,
+		Type:      events.FFmpegExited,
+		Severity:  severity,
+		Category:  events.CategoryChannel,
+		Message:   fmt.Sprintf("FFmpeg exited with code %d at %.1fs of item %d", code, e.ffmpeg.Position(), item.ItemID),
+		Details: map[string]interface{}{
+			"item_id":   item.ItemID,
+			"exit_code": code,
+			"position":  e.ffmpeg.Position(),
+		},
+	})
+}
+
+// buildOverlays assembles the overlays for an item. Channel text overlays are
+// rendered from text files so their content can be changed while on air.
+func (e *PlaylistExecutor) buildOverlays(ctx context.Context, channel *models.Channel, item *models.PlaylistItem, duration time.Duration) []models.Overlay {
+	startedAt := e.now()
+	if duration <= 0 {
+		duration = 24 * time.Hour
+	}
+
+	result, _ := e.channelOverlays(ctx, channel, item, startedAt, duration)
+	for i := range result {
+		if result[i].TextFile == "" {
+			continue
+		}
+		if err := writeTextFile(result[i].TextFile, result[i].Text); err != nil {
+			e.overlayError(channel, result[i].OverlayID, fmt.Errorf("failed to write text: %w", err))
+			result[i].TextFile = ""
 		}
+	}
+	layout := overlayLayout(result)
 
-		if err := e.repo.UpdateChannelState(context.Background(), state); err != nil {
-			log.Printf("Failed to update position: %v", err)
+	e.live.channel = channel
+	e.live.item = item
+	e.live.startedAt = startedAt
+	e.live.duration = duration
+	e.live.layout = layout
+
+	return result
+}
+
+// channelOverlays loads the enabled overlays of a channel whose activation
+// rules allow them on the item, with their asset paths resolved, followed by
+// the program title. Text and ticker overlays are given a text file to read
+// from.
+func (e *PlaylistExecutor) channelOverlays(ctx context.Context, channel *models.Channel,
+	item *models.PlaylistItem, startedAt time.Time, duration time.Duration) ([]models.Overlay, error) {
+
+	overlays, err := e.repo.GetChannelOverlays(ctx, channel.ChannelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overlays: %w", err)
+	}
+
+	var media *models.MediaFile
+	if item.Type == models.PlaylistItemTypeMedia {
+		media, _ = e.getMediaFile(ctx, item.MediaID)
+	}
+
+	var result []models.Overlay
+	for _, overlay := range overlays {
+		if !overlayApplies(overlay, item, media) {
+			continue
+		}
+		enable, ok := overlayEnableExpr(overlay, startedAt, duration)
+		if !ok {
+			continue
+		}
+
+		o := resolveOverlay(channel, overlay)
+		o.Enable = enable
+
+		switch o.Type {
+		case ffmpeg.OverlayTypeText:
+			o.TextFile = liveTextFile(channel, overlay.OverlayID)
+		case ffmpeg.OverlayTypeTicker:
+			o.TextFile = liveTextFile(channel, overlay.OverlayID)
+			headlines, err := e.repo.GetTickerHeadlines(ctx, overlay.OverlayID)
+			if err != nil {
+				e.overlayError(channel, overlay.OverlayID, fmt.Errorf("failed to get headlines: %w", err))
+			}
+			if len(headlines) > 0 {
+				o.Text = tickerText(headlines)
+			}
 		}
+		result = append(result, o)
+	}
+
+	// The program title goes on top of the channel's own overlays
+	title, err := programTitleOverlay(ctx, e.repo, channel, media)
+	if err != nil {
+		e.overlayError(channel, 0, fmt.Errorf("failed to build program title for item %d: %w", item.ItemID, err))
+	} else if title != nil {
+		result = append(result, *title)
+	}
+	return result, nil
+}
+
+func (e *PlaylistExecutor) overlayError(channel *models.Channel, overlayID int, err error) {
+	e.events.Publish(events.Event{
+		ChannelID: channel.ChannelID,
+		Type:      events.OverlayError,
+		Severity:  events.SeverityWarning,
+		Category:  events.CategoryOverlay,
+		Message:   fmt.Sprintf("Overlay %d: %v", overlayID, err),
+		Details:   map[string]interface{}{"overlay_id": overlayID},
 	})
+}
 
-	// Start FFmpeg stream
-	if err := e.ffmpeg.Start(streamCtx, config); err != nil {
-		return err
+// reloadOverlays applies overlay changes to the item on air. Text changes are
+// written to the overlay text files, which FFmpeg re-reads every frame; any
+// other change needs the current item restarted from its current position,
+// which it reports.
+func (e *PlaylistExecutor) reloadOverlays(ctx context.Context) (bool, error) {
+	channel, item, layout := e.live.channel, e.live.item, e.live.layout
+	if channel == nil {
+		return false, nil
 	}
 
-	// Update channel state
-	state := &models.ChannelState{
-		ChannelID:         channel.ChannelID,
-		CurrentPlaylistID: item.PlaylistID,
-		CurrentItemID:     item.ItemID,
-		CurrentPosition:   float64(offset),
-		Running:           true,
-		FFmpegPID:         e.ffmpeg.PID(),
-		LastUpdateTime:    time.Now(),
+	overlays, err := e.channelOverlays(ctx, channel, item, e.live.startedAt, e.live.duration)
+	if err != nil {
+		return false, err
 	}
-	if err := e.repo.UpdateChannelState(ctx, state); err != nil {
-		e.ffmpeg.Stop()
-		return fmt.Errorf("state update failed: %w", err)
+	if overlayLayout(overlays) != layout {
+		return true, nil
 	}
 
-	// Wait for completion or context cancellation
-	select {
-	case <-streamCtx.Done():
-		return streamCtx.Err()
-	case <-e.ffmpeg.Done():
-		return nil
+	for _, o := range overlays {
+		if o.TextFile == "" {
+			continue
+		}
+		if err := writeTextFile(o.TextFile, o.Text); err != nil {
+			return false, fmt.Errorf("failed to update overlay %d: %w", o.OverlayID, err)
+		}
 	}
+	return false, nil
+}
+
+// tickerText joins headlines into the single line crawled by a ticker.
+func tickerText(headlines []*models.TickerHeadline) string {
+	texts := make([]string, 0, len(headlines))
+	for _, headline := range headlines {
+		texts = append(texts, headline.Text)
+	}
+	return strings.Join(texts, "   •   ")
+}
+
+// overlayLayout describes everything about a set of overlays that is baked
+// into the FFmpeg filter graph, i.e. everything except text-file contents.
+func overlayLayout(overlays []models.Overlay) string {
+	var layout string
+	for _, o := range overlays {
+		text := o.Text
+		if o.TextFile != "" {
+			text = ""
+		}
+		layout += fmt.Sprintf("%d|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%d|%s\n",
+			o.OverlayID, o.Type, o.FilePath, o.TextFile, text,
+			o.PositionX, o.PositionY, o.FontSize, o.FontColor, o.FontFile,
+			o.BackgroundColor, o.Speed, o.Enable)
+	}
+	return layout
+}
+
+func liveTextFile(channel *models.Channel, overlayID int) string {
+	return filepath.Join(channel.StorageRoot, "data", "live", fmt.Sprintf("overlay_%d.txt", overlayID))
+}
+
+// writeTextFile replaces the file atomically so FFmpeg never reads a
+// partially written text.
+func writeTextFile(path string, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(text), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
 
 func (e *PlaylistExecutor) transitionToNextPlaylist(ctx context.Context, channel *models.Channel) error {
 	// Stop current stream
-	e.currentState.streamMux.Lock()
 	if e.currentState.streamCancel != nil {
 		e.currentState.streamCancel()
 	}
-	e.currentState.streamMux.Unlock()
 
-	// Get next day's playlist
-	nextDay := calculateNextDayStart(time.Now(), channel.StartTime)
+	// Get the playlist of the day that has just begun
+	nextDay := calculateEffectiveDate(e.now(), channel.StartTime)
 	playlist, err := e.repo.GetPlaylistForDate(ctx, channel.ChannelID, nextDay)
 	if err != nil && !channel.UsePreviousDayFallback {
 		return fmt.Errorf("next playlist unavailable: %w", err)
@@ -336,6 +768,22 @@ func (e *PlaylistExecutor) transitionToNextPlaylist(ctx context.Context, channel
 	// Fallback to current playlist if needed
 	if playlist == nil {
 		playlist = e.currentState.playlist
+		e.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.PlaylistFallback,
+			Severity:  events.SeverityWarning,
+			Category:  events.CategoryPlaylist,
+			Message:   fmt.Sprintf("No playlist for %s, repeating playlist %d", nextDay.Format("2006-01-02"), playlist.PlaylistID),
+			Details:   map[string]interface{}{"playlist_id": playlist.PlaylistID},
+		})
+	} else {
+		e.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.PlaylistTransition,
+			Category:  events.CategoryPlaylist,
+			Message:   fmt.Sprintf("Switching to playlist %d for %s", playlist.PlaylistID, nextDay.Format("2006-01-02")),
+			Details:   map[string]interface{}{"playlist_id": playlist.PlaylistID},
+		})
 	}
 
 	// Reinitialize with new playlist
@@ -349,6 +797,7 @@ func (e *PlaylistExecutor) transitionToNextPlaylist(ctx context.Context, channel
 		return fmt.Errorf("failed to refresh items: %w", err)
 	}
 	e.currentState.items = items
+	e.currentState.current = items[0]
 
 	// Lock new items
 	e.lockItem(items[0])
@@ -367,15 +816,12 @@ func (e *PlaylistExecutor) unlockItem(item *models.PlaylistItem) {
 }
 
 func (e *PlaylistExecutor) getMediaFile(ctx context.Context, mediaID sql.NullInt64) (*models.MediaFile, error) {
-	useCache := os.Getenv("ENABLE_MEDIA_CACHE") == "true"
+	useCache := e.settings.Bool("enable_media_cache")
 
 	if useCache {
-		e.cacheMux.RLock()
 		if media, exists := e.mediaCache[mediaID]; exists {
-			e.cacheMux.RUnlock()
 			return media, nil
 		}
-		e.cacheMux.RUnlock()
 	}
 
 	media, err := e.repo.GetMediaFile(ctx, mediaID)
@@ -384,36 +830,97 @@ func (e *PlaylistExecutor) getMediaFile(ctx context.Context, mediaID sql.NullInt
 	}
 
 	if useCache {
-		e.cacheMux.Lock()
 		e.mediaCache[mediaID] = media
-		e.cacheMux.Unlock()
 	}
 
 	return media, nil
 
 }
 
-func (e *PlaylistExecutor) cleanup() {
-	e.currentState.streamMux.Lock()
-	defer e.currentState.streamMux.Unlock()
-
+// cleanup takes the channel off air as Execute returns. FFmpeg gets
+// ffmpegStopTimeout to exit after SIGTERM before it is killed.
+func (e *PlaylistExecutor) cleanup(channel *models.Channel) {
 	if e.currentState.streamCancel != nil {
 		e.currentState.streamCancel()
 	}
+	e.ffmpeg.SetProgressCallback(nil)
+	position := e.ffmpeg.Position()
+	if err := e.ffmpeg.Terminate(ffmpegStopTimeout); err != nil {
+		e.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.FFmpegExited,
+			Severity:  events.SeverityWarning,
+			Category:  events.CategoryChannel,
+			Message:   err.Error(),
+		})
+	}
+	e.live.channel = nil
 
 	// Unlock all items
 	for _, item := range e.currentState.items {
 		e.unlockItem(item)
 	}
+
+	e.writeStoppedState(channel, position)
+}
+
+// writeStoppedState records that the channel is off air once FFmpeg has
+// exited, keeping the item and position it stopped at so that it can
+// resume there.
+func (e *PlaylistExecutor) writeStoppedState(channel *models.Channel, position float64) {
+	ctx := context.Background()
+	state, err := e.repo.GetChannelState(ctx, channel.ChannelID)
+	if err != nil {
+		state = &models.ChannelState{ChannelID: channel.ChannelID}
+	}
+
+	// On the slate, FFmpeg's position is the slate's; the item's was kept
+	if position > 0 && !state.OnSlate {
+		state.CurrentPosition = position
+	}
+	state.Running = false
+	state.FFmpegPID = 0
+	state.OnSlate = false
+	state.LastUpdateTime = e.clock.Now()
+	if err := e.repo.UpdateChannelState(ctx, state); err != nil {
+		e.events.Publish(events.Event{
+			ChannelID: channel.ChannelID,
+			Type:      events.StateUpdateFailed,
+			Severity:  events.SeverityError,
+			Category:  events.CategoryChannel,
+			Message:   fmt.Sprintf("Failed to record that the channel stopped: %v", err),
+		})
+	}
+}
+
+// channelLocation returns the timezone a channel's schedule runs in.
+func channelLocation(channel *models.Channel) (*time.Location, error) {
+	if !channel.Timezone.Valid || channel.Timezone.String == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(channel.Timezone.String)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", channel.Timezone.String, err)
+	}
+	return location, nil
+}
+
+// now returns the current time in the channel's timezone.
+func (e *PlaylistExecutor) now() time.Time {
+	return e.clock.Now().In(e.location)
 }
 
 // Helper functions
+
+// Broadcast days start at the channel's start time in now's timezone. Days
+// are stepped by date rather than by 24 hours, so across a daylight saving
+// change a day is 23 or 25 hours long and still starts at the start time.
 func calculateEffectiveDate(now time.Time, startTime time.Time) time.Time {
 	todayStart := time.Date(now.Year(), now.Month(), now.Day(),
 		startTime.Hour(), startTime.Minute(), 0, 0, now.Location())
 
 	if now.Before(todayStart) {
-		return todayStart.Add(-24 * time.Hour)
+		return todayStart.AddDate(0, 0, -1)
 	}
 	return todayStart
 }
@@ -423,92 +930,167 @@ func calculateNextDayStart(now time.Time, startTime time.Time) time.Time {
 		startTime.Hour(), startTime.Minute(), 0, 0, now.Location())
 
 	if now.After(todayStart) {
-		return todayStart.Add(24 * time.Hour)
+		return todayStart.AddDate(0, 0, 1)
 	}
 	return todayStart
 }
 
-func (e *PlaylistExecutor) calculateStartPosition(ctx context.Context, items []*models.PlaylistItem,
-	playlistStart time.Time) (int, int) {
+// startPosition returns the item and offset a channel starts playing from,
+// as its start mode asks. Modes that pick up where the channel was fall back
+// to the schedule when what they need is missing or from an earlier day.
+func (e *PlaylistExecutor) startPosition(ctx context.Context, channel *models.Channel,
+	playlist *models.Playlist, items []*models.PlaylistItem, dayStart time.Time) (int, int, error) {
+
+	var index, offset int
+	resumed := false
+
+	switch channel.StartMode {
+	case "", models.StartModeSchedule:
+	case models.StartModeResume:
+		index, offset, resumed = e.resumePosition(ctx, channel, playlist, items, dayStart)
+	case models.StartModeScheduleLocked:
+		index, offset, resumed = e.asRunPosition(ctx, channel, playlist, items, dayStart)
+	default:
+		return 0, 0, fmt.Errorf("invalid start mode %q", channel.StartMode)
+	}
 
-	elapsed := time.Since(playlistStart)
-	if elapsed < 0 {
-		return 0, 0
+	if !resumed {
+		index, offset = e.calculateStartPosition(ctx, items, dayStart)
+		return index, offset, nil
 	}
 
-	totalDuration := 0
-	for _, item := range items {
-		var duration int
+	e.events.Publish(events.Event{
+		ChannelID: channel.ChannelID,
+		Type:      events.PlayoutResumed,
+		Category:  events.CategoryPlaylist,
+		Message:   fmt.Sprintf("Resuming item %d at %ds (%s)", items[index].ItemID, offset, channel.StartMode),
+		Details: map[string]interface{}{
+			"playlist_id": playlist.PlaylistID,
+			"item_id":     items[index].ItemID,
+			"offset":      offset,
+			"start_mode":  channel.StartMode,
+		},
+	})
+	return index, offset, nil
+}
 
-		switch item.Type {
-		case models.PlaylistItemTypeMedia:
-			media, err := e.getMediaFile(ctx, item.MediaID)
-			if err != nil {
-				return 0, 0
-			}
-			duration = media.DurationSeconds
+// resumePosition returns where the channel stopped today, from the item and
+// position in its state. The position is recorded as the item plays, so it
+// lags behind when the item left the air; the as-run log tells by how much.
+func (e *PlaylistExecutor) resumePosition(ctx context.Context, channel *models.Channel,
+	playlist *models.Playlist, items []*models.PlaylistItem, dayStart time.Time) (int, int, bool) {
 
-		case models.PlaylistItemTypeUDP:
-			// Get UDP stream duration (NULL means infinite)
-			if item.StreamID.Valid {
-				stream, err := e.repo.GetUDPStream(ctx, item.StreamID)
-				if err != nil {
-					return 0, 0
-				}
-				if stream.DurationSeconds != nil {
-					duration = *stream.DurationSeconds
-				} else {
-					// Infinite stream - use remaining time in playlist
-					duration = 24 * 3600 // Max 24 hours
-				}
-			} else {
-				duration = 24 * 3600 // Default to 24 hours if stream ID is invalid
-			}
+	state, err := e.repo.GetChannelState(ctx, channel.ChannelID)
+	if err != nil || state.CurrentPlaylistID != playlist.PlaylistID || state.LastUpdateTime.Before(dayStart) {
+		return 0, 0, false
+	}
+	index := itemIndex(items, state.CurrentItemID)
+	if index < 0 {
+		return 0, 0, false
+	}
+
+	position := state.CurrentPosition
+	if last := e.lastAsRun(ctx, channel.ChannelID); last != nil && last.ItemID == state.CurrentItemID && last.EndedAt.Valid {
+		if drift := last.EndedAt.Time.Sub(state.LastUpdateTime); drift > 0 {
+			position += drift.Seconds()
 		}
+	}
 
-		totalDuration += duration
+	index, offset := e.seekForward(ctx, items, index, int(position))
+	return index, offset, true
+}
+
+// asRunPosition returns where the channel would be had it kept playing
+// since the last item went to air today, so that skipped, cut and late
+// items earlier in the day still count as they actually ran.
+func (e *PlaylistExecutor) asRunPosition(ctx context.Context, channel *models.Channel,
+	playlist *models.Playlist, items []*models.PlaylistItem, dayStart time.Time) (int, int, bool) {
+
+	last := e.lastAsRun(ctx, channel.ChannelID)
+	if last == nil || last.PlaylistID != playlist.PlaylistID || last.StartedAt.Before(dayStart) {
+		return 0, 0, false
+	}
+	index := itemIndex(items, last.ItemID)
+	elapsed := e.clock.Since(last.StartedAt)
+	if index < 0 || elapsed < 0 {
+		return 0, 0, false
 	}
 
-	positionSec := int(elapsed.Seconds()) % (24 * 3600)
-	if totalDuration > 0 {
-		positionSec %= totalDuration
+	index, offset := e.seekForward(ctx, items, index, int(last.StartOffset+elapsed.Seconds()))
+	return index, offset, true
+}
+
+func itemIndex(items []*models.PlaylistItem, itemID int) int {
+	for i, item := range items {
+		if item.ItemID == itemID {
+			return i
+		}
 	}
+	return -1
+}
 
-	accumulated := 0
+func (e *PlaylistExecutor) calculateStartPosition(ctx context.Context, items []*models.PlaylistItem,
+	playlistStart time.Time) (int, int) {
+
+	elapsed := e.clock.Since(playlistStart)
+	if elapsed < 0 {
+		return 0, 0
+	}
+
+	// Within the broadcast day, which may be 25 hours long
+	return e.seekForward(ctx, items, 0, int(elapsed.Seconds()))
+}
+
+// seekForward returns the item and offset the given number of seconds after
+// the start of items[index], with the playlist looping.
+func (e *PlaylistExecutor) seekForward(ctx context.Context, items []*models.PlaylistItem, index, seconds int) (int, int) {
+	durations := make([]int, len(items))
+	totalDuration := 0
 	for i, item := range items {
-		var duration int
+		duration, err := e.itemDuration(ctx, item)
+		if err != nil {
+			return 0, 0
+		}
+		durations[i] = duration
+		totalDuration += duration
+	}
+	if totalDuration == 0 {
+		return 0, 0
+	}
 
-		switch item.Type {
-		case models.PlaylistItemTypeMedia:
-			media, err := e.getMediaFile(ctx, item.MediaID)
-			if err != nil {
-				return 0, 0
-			}
-			duration = media.DurationSeconds
+	seconds %= totalDuration
+	for seconds >= durations[index] {
+		seconds -= durations[index]
+		index = (index + 1) % len(items)
+	}
+	return index, seconds
+}
 
-		case models.PlaylistItemTypeUDP:
-			if item.StreamID.Valid {
-				stream, err := e.repo.GetUDPStream(ctx, item.StreamID)
-				if err != nil {
-					return 0, 0
-				}
-				if stream.DurationSeconds != nil {
-					duration = *stream.DurationSeconds
-				} else {
-					duration = 24 * 3600
-				}
-			} else {
-				duration = 24 * 3600
-			}
+// itemDuration returns how long an item plays, in seconds. Streams without a
+// duration count as a whole day.
+func (e *PlaylistExecutor) itemDuration(ctx context.Context, item *models.PlaylistItem) (int, error) {
+	switch item.Type {
+	case models.PlaylistItemTypeMedia:
+		media, err := e.getMediaFile(ctx, item.MediaID)
+		if err != nil {
+			return 0, err
 		}
+		return media.DurationSeconds, nil
 
-		if accumulated+duration > positionSec {
-			return i, positionSec - accumulated
+	case models.PlaylistItemTypeUDP:
+		if !item.StreamID.Valid {
+			return 24 * 3600, nil
+		}
+		stream, err := e.repo.GetUDPStream(ctx, item.StreamID)
+		if err != nil {
+			return 0, err
 		}
-		accumulated += duration
+		if stream.DurationSeconds == nil {
+			return 24 * 3600, nil
+		}
+		return *stream.DurationSeconds, nil
 	}
-
-	return 0, 0
+	return 0, nil
 }
 
 // func (e *PlaylistExecutor) calculateStartPosition(ctx context.Context, items []*models.PlaylistItem,
@@ -549,28 +1131,3 @@ func (e *PlaylistExecutor) calculateStartPosition(ctx context.Context, items []*
 
 // 	return 0, 0
 // }
-
-func (e *PlaylistExecutor) GetPlaylists(ctx context.Context, channelID int) ([]*models.Playlist, error) {
-	playlist, err := e.repo.GetPlaylists(ctx, channelID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get playlists: %w", err)
-	}
-
-	return playlist, err
-}
-
-func (e *PlaylistExecutor) GetPlaylist(ctx context.Context, playlistID int) (*models.Playlist, error) {
-	return e.repo.GetPlaylist(ctx, playlistID)
-}
-
-func (e *PlaylistExecutor) GetPlaylistItems(ctx context.Context, playlistID int) ([]*models.PlaylistItem, error) {
-	return e.repo.GetPlaylistItems(ctx, playlistID)
-}
-
-func (e *PlaylistExecutor) GetMediaFiles(ctx context.Context, channelID int, page, pageSize int) ([]*models.MediaFile, error) {
-	return e.repo.GetMediaFiles(ctx, channelID, page, pageSize)
-}
-
-func (e *PlaylistExecutor) CountMediaFiles(ctx context.Context, channelID int) (int, error) {
-	return e.repo.CountMediaFiles(ctx, channelID)
-}
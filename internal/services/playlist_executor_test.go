@@ -0,0 +1,836 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/clock"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+)
+
+func at(day int, clockTime string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04:05", fmt.Sprintf("2025-03-%02d %s", day, clockTime))
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestCalculateEffectiveDate(t *testing.T) {
+	start := at(1, "06:00:00")
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{at(14, "05:59:59"), at(13, "06:00:00")},
+		{at(14, "06:00:00"), at(14, "06:00:00")},
+		{at(14, "23:30:00"), at(14, "06:00:00")},
+		{at(1, "00:00:00"), time.Date(2025, 2, 28, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := calculateEffectiveDate(test.now, start); !got.Equal(test.want) {
+			t.Errorf("calculateEffectiveDate(%v) = %v, want %v", test.now, got, test.want)
+		}
+	}
+}
+
+func TestCalculateNextDayStart(t *testing.T) {
+	start := at(1, "06:00:00")
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{at(14, "05:59:59"), at(14, "06:00:00")},
+		{at(14, "06:00:00"), at(14, "06:00:00")},
+		{at(14, "06:00:01"), at(15, "06:00:00")},
+		{at(31, "12:00:00"), time.Date(2025, 4, 1, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := calculateNextDayStart(test.now, start); !got.Equal(test.want) {
+			t.Errorf("calculateNextDayStart(%v) = %v, want %v", test.now, got, test.want)
+		}
+	}
+}
+
+func TestBroadcastDayAcrossDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	start := at(1, "06:00:00")
+	local := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2025, month, day, hour, min, 0, 0, newYork)
+	}
+
+	// Clocks go forward at 02:00 on 9 March, so the day that began at 06:00
+	// on 8 March is 23 hours long
+	now := local(3, 9, 1, 0)
+	if got, want := calculateEffectiveDate(now, start), local(3, 8, 6, 0); !got.Equal(want) {
+		t.Errorf("effective date before spring forward = %v, want %v", got, want)
+	}
+	if got, want := calculateNextDayStart(now, start), local(3, 9, 6, 0); !got.Equal(want) {
+		t.Errorf("next day start after spring forward = %v, want %v", got, want)
+	}
+	if got := calculateNextDayStart(now, start).Sub(calculateEffectiveDate(now, start)); got != 23*time.Hour {
+		t.Errorf("spring forward day length = %v, want 23h", got)
+	}
+
+	// Clocks go back at 02:00 on 2 November, so the day that began at 06:00
+	// on 1 November is 25 hours long
+	now = local(11, 2, 5, 30)
+	if got, want := calculateEffectiveDate(now, start), local(11, 1, 6, 0); !got.Equal(want) {
+		t.Errorf("effective date before fall back = %v, want %v", got, want)
+	}
+	if got := calculateNextDayStart(now, start).Sub(calculateEffectiveDate(now, start)); got != 25*time.Hour {
+		t.Errorf("fall back day length = %v, want 25h", got)
+	}
+}
+
+func TestStartPositionOnLongDay(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	dayStart := time.Date(2025, 11, 1, 6, 0, 0, 0, newYork)
+
+	h := newPlayoutHarness(t, dayStart.Add(24*time.Hour+30*time.Minute))
+	h.addPlaylist(t, nil, "loop", 7*3600)
+	items, err := h.store.GetPlaylistItems(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 24.5 hours into the 25 hour day is 3.5 hours into the fourth loop
+	index, offset := h.executor.calculateStartPosition(context.Background(), items, dayStart)
+	if index != 0 || offset != 12600 {
+		t.Errorf("start position = item %d at %ds, want item 0 at 12600s", index, offset)
+	}
+}
+
+// playoutHarness plays a channel out on simulated FFmpeg in virtual time.
+type playoutHarness struct {
+	store    *database.MemoryStore
+	clock    *clock.Fake
+	sim      *ffmpeg.Simulator
+	channel  *models.Channel
+	executor *PlaylistExecutor
+}
+
+func newPlayoutHarness(t *testing.T, now time.Time) *playoutHarness {
+	t.Helper()
+	ctx := context.Background()
+
+	h := &playoutHarness{
+		store: database.NewMemoryStore(),
+		clock: clock.NewFake(now),
+	}
+	h.sim = ffmpeg.NewSimulator(h.clock)
+
+	channel := &models.Channel{
+		ChannelName:  "test",
+		StorageRoot:  t.TempDir(),
+		OutputUDP:    "udp://239.0.0.1:1234",
+		PlaylistType: "daily_playlist",
+		StartTimeStr: "06:00:00",
+		Timezone:     sql.NullString{String: "UTC", Valid: true},
+		Enabled:      true,
+	}
+	if err := h.store.UpdateChannel(ctx, channel); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if h.channel, err = h.store.GetChannelByID(ctx, channel.ChannelID); err != nil {
+		t.Fatal(err)
+	}
+
+	h.executor = NewPlaylistExecutor(h.store, config.DefaultSettings(), h.sim, nil)
+	h.executor.clock = h.clock
+	return h
+}
+
+// addPlaylist creates a playlist of media files of the given lengths, in
+// seconds, for a date or, without one, for every day.
+func (h *playoutHarness) addPlaylist(t *testing.T, date *time.Time, name string, seconds ...int) {
+	t.Helper()
+	ctx := context.Background()
+
+	playlist := &models.Playlist{ChannelID: h.channel.ChannelID, PlaylistName: name, PlaylistDate: date, Status: "active"}
+	if err := h.store.CreatePlaylist(ctx, playlist); err != nil {
+		t.Fatal(err)
+	}
+	for i, length := range seconds {
+		file := &models.MediaFile{
+			ChannelID:       h.channel.ChannelID,
+			FilePath:        fmt.Sprintf("%s%d.ts", name, i),
+			FileName:        fmt.Sprintf("%s%d.ts", name, i),
+			DurationSeconds: length,
+		}
+		if err := h.store.CreateMediaFile(ctx, file); err != nil {
+			t.Fatal(err)
+		}
+		h.sim.SetInputDuration(h.inputPath(file.FilePath), time.Duration(length)*time.Second)
+
+		item := &models.PlaylistItem{
+			PlaylistID: playlist.PlaylistID,
+			MediaID:    sql.NullInt64{Int64: int64(file.MediaID), Valid: true},
+			Type:       models.PlaylistItemTypeMedia,
+			Position:   i,
+		}
+		if err := h.store.CreatePlaylistItem(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (h *playoutHarness) inputPath(file string) string {
+	return filepath.Join(h.channel.StorageRoot, "media", file)
+}
+
+// run plays the channel out until the test ends.
+func (h *playoutHarness) run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.executor.Execute(ctx, h.channel)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitItem waits until the nth item has started and is waiting on the
+// clock: on the simulator's progress and end timers and the watchdog.
+func (h *playoutHarness) waitItem(t *testing.T, n int) ffmpeg.StreamConfig {
+	t.Helper()
+
+	started := make(chan ffmpeg.StreamConfig, 1)
+	go func() {
+		starts := h.sim.WaitStarts(n)
+		h.clock.BlockUntil(3)
+		started <- starts[n-1]
+	}()
+	select {
+	case config := <-started:
+		return config
+	case <-time.After(5 * time.Second):
+		t.Fatalf("item %d did not start; started %d items", n, len(h.sim.Starts()))
+		return ffmpeg.StreamConfig{}
+	}
+}
+
+func (h *playoutHarness) expectItem(t *testing.T, n int, file string, offset time.Duration) {
+	t.Helper()
+	config := h.waitItem(t, n)
+	if config.InputPath != h.inputPath(file) || config.StartOffset != offset {
+		t.Fatalf("item %d played %s from %v, want %s from %v",
+			n, filepath.Base(config.InputPath), config.StartOffset, file, offset)
+	}
+}
+
+func TestExecuteJoinsMidItem(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.run(t)
+
+	// 15 seconds into the day is 5 seconds into the second item
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+
+	h.clock.Advance(15 * time.Second)
+	h.expectItem(t, 2, "loop2.ts", 0)
+
+	// The playlist loops
+	h.clock.Advance(30 * time.Second)
+	h.expectItem(t, 3, "loop0.ts", 0)
+}
+
+func TestExecuteSeeksWithinItem(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.run(t)
+
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+	h.clock.Advance(4 * time.Second)
+
+	if err := h.executor.Seek(context.Background(), 9); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	h.expectItem(t, 2, "loop1.ts", 9*time.Second)
+
+	// Seeking to where the item was leaves its end where it would have been
+	h.clock.Advance(11 * time.Second)
+	h.expectItem(t, 3, "loop2.ts", 0)
+
+	if err := h.executor.Seek(context.Background(), 30); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Seek past the end of the item returned %v, want ErrInvalidCommand", err)
+	}
+}
+
+func TestExecuteSkipsItem(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.run(t)
+
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+	h.clock.Advance(3 * time.Second)
+
+	if err := h.executor.Skip(context.Background()); err != nil {
+		t.Fatalf("Skip: %v", err)
+	}
+	h.expectItem(t, 2, "loop2.ts", 0)
+
+	entries, err := h.store.GetAsRunLog(context.Background(), h.channel.ChannelID, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped := entries[1]; skipped.EndReason.String != models.AsRunSkipped || skipped.EndPosition.Float64 != 8 {
+		t.Errorf("skipped item ended %q at %.1fs, want skipped at 8s", skipped.EndReason.String, skipped.EndPosition.Float64)
+	}
+}
+
+func TestExecuteRestartsItem(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.run(t)
+
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+	h.clock.Advance(3 * time.Second)
+
+	if err := h.executor.Restart(context.Background()); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	h.expectItem(t, 2, "loop1.ts", 0)
+
+	entries, err := h.store.GetAsRunLog(context.Background(), h.channel.ChannelID, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted := entries[1]; restarted.EndReason.String != models.AsRunRestarted {
+		t.Errorf("restarted item ended %q, want %q", restarted.EndReason.String, models.AsRunRestarted)
+	}
+}
+
+// editedStore serves playlist items as edited behind the executor's back.
+type editedStore struct {
+	playoutStore
+	mux   sync.Mutex
+	items []*models.PlaylistItem
+}
+
+func (s *editedStore) GetPlaylistItems(ctx context.Context, playlistID int) ([]*models.PlaylistItem, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.items != nil {
+		return s.items, nil
+	}
+	return s.playoutStore.GetPlaylistItems(ctx, playlistID)
+}
+
+func (s *editedStore) edit(items ...*models.PlaylistItem) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.items = items
+}
+
+func TestExecuteRestartsEditedItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		playing  string
+		edit     []int         // Indexes of the original items left, in their new order
+		left     time.Duration // Left of the item on air after the restarts
+		nextFile string
+	}{
+		{"items deleted", at(14, "06:00:35"), "loop2.ts", []int{0}, 21 * time.Second, "loop0.ts"},
+		{"items reordered", at(14, "06:00:05"), "loop0.ts", []int{2, 1, 0}, time.Second, "loop2.ts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newPlayoutHarness(t, tt.now)
+			h.addPlaylist(t, nil, "loop", 10, 20, 30)
+			items, err := h.store.GetPlaylistItems(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := &editedStore{playoutStore: h.store}
+			h.executor.repo = store
+			h.run(t)
+			h.expectItem(t, 1, tt.playing, 5*time.Second)
+
+			var edited []*models.PlaylistItem
+			for _, index := range tt.edit {
+				edited = append(edited, items[index])
+			}
+			store.edit(edited...)
+
+			// Each restart refreshes the items; the item on air plays on
+			for n, offset := range []int{7, 9} {
+				if err := h.executor.Seek(context.Background(), offset); err != nil {
+					t.Fatalf("Seek: %v", err)
+				}
+				h.expectItem(t, n+2, tt.playing, time.Duration(offset)*time.Second)
+			}
+
+			h.clock.Advance(tt.left)
+			h.expectItem(t, 4, tt.nextFile, 0)
+		})
+	}
+}
+
+func TestExecuteJumpsToItem(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:05"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.run(t)
+	h.expectItem(t, 1, "loop0.ts", 5*time.Second)
+
+	ctx := context.Background()
+	playlists, err := h.store.GetPlaylists(ctx, h.channel.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := h.store.GetPlaylistItems(ctx, playlists[0].PlaylistID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.executor.Jump(ctx, -1); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Jump to an unknown item = %v, want ErrInvalidCommand", err)
+	}
+	if err := h.executor.Jump(ctx, items[2].ItemID); err != nil {
+		t.Fatalf("Jump: %v", err)
+	}
+	h.expectItem(t, 2, "loop2.ts", 0)
+
+	// Playout carries on from the item jumped to
+	h.clock.Advance(30 * time.Second)
+	h.expectItem(t, 3, "loop0.ts", 0)
+
+	entries, err := h.store.GetAsRunLog(ctx, h.channel.ChannelID, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jumped := entries[2]; jumped.ItemID != items[0].ItemID || jumped.EndReason.String != models.AsRunJumped {
+		t.Errorf("item %d ended %q, want item %d jumped", jumped.ItemID, jumped.EndReason.String, items[0].ItemID)
+	}
+}
+
+func TestExecuteSlate(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.channel.SlateFile = sql.NullString{String: "slate.ts", Valid: true}
+	h.run(t)
+
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+	h.clock.Advance(3 * time.Second)
+
+	ctx := context.Background()
+	if err := h.executor.Unslate(ctx); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Unslate off the slate = %v, want ErrInvalidCommand", err)
+	}
+	if err := h.executor.Slate(ctx); err != nil {
+		t.Fatalf("Slate: %v", err)
+	}
+
+	slate := h.sim.WaitStarts(2)[1]
+	if slate.InputPath != filepath.Join(h.channel.StorageRoot, "slate.ts") || !slate.Loop {
+		t.Errorf("slate played %s with loop %v, want slate.ts looped", slate.InputPath, slate.Loop)
+	}
+
+	// A command the slate takes shows it is on air
+	if err := h.executor.ReloadOverlays(ctx); err != nil {
+		t.Fatalf("ReloadOverlays: %v", err)
+	}
+	state, err := h.store.GetChannelState(ctx, h.channel.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.OnSlate || state.CurrentPosition != 8 {
+		t.Errorf("state on slate %v at %.1fs, want on slate at 8s", state.OnSlate, state.CurrentPosition)
+	}
+	if err := h.executor.Skip(ctx); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Skip on the slate = %v, want ErrInvalidCommand", err)
+	}
+
+	// The slate holds however long it stays up
+	h.clock.BlockUntil(1)
+	h.clock.Advance(time.Minute)
+	if err := h.executor.Unslate(ctx); err != nil {
+		t.Fatalf("Unslate: %v", err)
+	}
+	h.expectItem(t, 3, "loop1.ts", 8*time.Second)
+
+	if state, err = h.store.GetChannelState(ctx, h.channel.ChannelID); err != nil {
+		t.Fatal(err)
+	}
+	if state.OnSlate {
+		t.Error("state still on slate after Unslate")
+	}
+
+	entries, err := h.store.GetAsRunLog(ctx, h.channel.ChannelID, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("as-run log has %d entries, want the item, the slate and the item resumed", len(entries))
+	}
+	if slated := entries[2]; slated.Slate || slated.EndReason.String != models.AsRunSlated || slated.EndPosition.Float64 != 8 {
+		t.Errorf("slated item ended %q at %.1fs, want slated at 8s", slated.EndReason.String, slated.EndPosition.Float64)
+	}
+
+	// The slate period is logged against the paused item, which does not advance
+	slated := entries[1]
+	if !slated.Slate || slated.ItemID != entries[2].ItemID || slated.StartOffset != 8 || slated.EndPosition.Float64 != 8 {
+		t.Errorf("slate entry %+v, want the slate over item %d at 8s", slated, entries[2].ItemID)
+	}
+	if slated.EndReason.String != models.AsRunUnslated || slated.EndedAt.Time.Sub(slated.StartedAt) != time.Minute {
+		t.Errorf("slate ended %q after %v, want unslated after 1m", slated.EndReason.String, slated.EndedAt.Time.Sub(slated.StartedAt))
+	}
+	if resumed := entries[0]; resumed.Slate || resumed.StartOffset != 8 {
+		t.Errorf("resumed item entry %+v, want the item from 8s", resumed)
+	}
+}
+
+func TestSlateRequiresSlateFile(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:00"))
+	h.addPlaylist(t, nil, "loop", 60)
+	h.run(t)
+	h.expectItem(t, 1, "loop0.ts", 0)
+
+	if err := h.executor.Slate(context.Background()); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Slate without a slate file = %v, want ErrInvalidCommand", err)
+	}
+}
+
+// TestExecuteTakesCommandsConcurrently sends commands and reads progress
+// from several goroutines while the playout runs, for the race detector.
+func TestExecuteTakesCommandsConcurrently(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:00"))
+	h.addPlaylist(t, nil, "loop", 600)
+	h.run(t)
+	h.expectItem(t, 1, "loop0.ts", 0)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := h.executor.ReloadOverlays(ctx); err != nil {
+					t.Errorf("ReloadOverlays: %v", err)
+				}
+				_ = h.executor.Progress()
+				_ = h.executor.Position()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Unchanged overlays do not restart the item
+	if starts := len(h.sim.Starts()); starts != 1 {
+		t.Errorf("item started %d times, want once", starts)
+	}
+}
+
+func TestCommandAfterPlayoutStopped(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:00"))
+	h.addPlaylist(t, nil, "loop", 60)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.executor.Execute(ctx, h.channel); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if err := h.executor.Skip(context.Background()); !errors.Is(err, ErrPlayoutStopped) {
+		t.Errorf("Skip after playout stopped returned %v, want ErrPlayoutStopped", err)
+	}
+}
+
+func TestExecuteRollsOverToNextDay(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "05:59:00"))
+	yesterday, today := at(13, "00:00:00"), at(14, "00:00:00")
+	h.addPlaylist(t, &yesterday, "old", 30, 30)
+	h.addPlaylist(t, &today, "new", 40)
+	h.run(t)
+
+	h.expectItem(t, 1, "old0.ts", 0)
+	h.clock.Advance(30 * time.Second)
+	h.expectItem(t, 2, "old1.ts", 0)
+
+	// The second item ends exactly at the start of the new day
+	h.clock.Advance(30 * time.Second)
+	h.expectItem(t, 3, "new0.ts", 0)
+
+	ctx := context.Background()
+	playlist, err := h.store.GetPlaylistForDate(ctx, h.channel.ChannelID, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := h.store.GetChannelState(ctx, h.channel.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Running || state.CurrentPlaylistID != playlist.PlaylistID {
+		t.Errorf("channel state = playlist %d running %v, want playlist %d running", state.CurrentPlaylistID, state.Running, playlist.PlaylistID)
+	}
+}
+
+func TestExecuteCutsItemAtDayStart(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "05:59:50"))
+	yesterday, today := at(13, "00:00:00"), at(14, "00:00:00")
+	h.addPlaylist(t, &yesterday, "old", 30, 30)
+	h.addPlaylist(t, &today, "new", 40)
+	h.run(t)
+
+	// 23:59:50 into the old day is 20 seconds into its second item, which is
+	// cut 10 seconds later when the new day begins
+	h.expectItem(t, 1, "old1.ts", 20*time.Second)
+	if config := h.sim.Starts()[0]; config.Duration != 10*time.Second {
+		t.Errorf("first item duration = %v, want 10s", config.Duration)
+	}
+
+	h.clock.Advance(10 * time.Second)
+	h.expectItem(t, 2, "new0.ts", 0)
+}
+
+func TestExecuteInChannelTimezone(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip(err)
+	}
+
+	// 10:00 UTC is 06:00 in New York, where the channel's day begins
+	h := newPlayoutHarness(t, at(14, "10:00:15"))
+	h.channel.Timezone = sql.NullString{String: "America/New_York", Valid: true}
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.run(t)
+
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+}
+
+func TestInitializePlaylistRejectsUnknownTimezone(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "10:00:00"))
+	h.channel.Timezone = sql.NullString{String: "Mars/Olympus_Mons", Valid: true}
+	h.addPlaylist(t, nil, "loop", 60)
+
+	if err := h.executor.initializePlaylist(context.Background(), h.channel); err == nil {
+		t.Error("initializePlaylist accepted an unknown timezone")
+	}
+}
+
+func TestInitializePlaylistFallsBackToEarlierDay(t *testing.T) {
+	ctx := context.Background()
+
+	h := newPlayoutHarness(t, at(14, "10:00:00"))
+	earlier := at(12, "00:00:00")
+	h.addPlaylist(t, &earlier, "earlier", 60)
+
+	if err := h.executor.initializePlaylist(ctx, h.channel); err == nil {
+		t.Fatal("initializePlaylist succeeded without fallback enabled")
+	}
+
+	h.channel.UsePreviousDayFallback = true
+	if err := h.executor.initializePlaylist(ctx, h.channel); err != nil {
+		t.Fatalf("initializePlaylist: %v", err)
+	}
+	playlist := h.executor.currentState.playlist
+	if playlist.PlaylistName != "earlier" {
+		t.Errorf("playlist = %q, want earlier", playlist.PlaylistName)
+	}
+	// It plays as if scheduled for today
+	if want := at(14, "06:00:00"); !h.executor.currentState.playlistStart.Equal(want) {
+		t.Errorf("playlist start = %v, want %v", h.executor.currentState.playlistStart, want)
+	}
+}
+
+func TestInitializePlaylistFallbackLimit(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "10:00:00"))
+	h.channel.UsePreviousDayFallback = true
+	tooOld := at(1, "00:00:00")
+	h.addPlaylist(t, &tooOld, "old", 60)
+
+	if err := h.executor.initializePlaylist(context.Background(), h.channel); err == nil {
+		t.Error("initializePlaylist used a playlist from 13 days ago, beyond the default fallback of 7 days")
+	}
+}
+
+func TestExecuteRecordsAsRun(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "06:00:15"))
+	h.addPlaylist(t, nil, "loop", 10, 20, 30)
+	h.run(t)
+
+	h.expectItem(t, 1, "loop1.ts", 5*time.Second)
+	h.clock.Advance(15 * time.Second)
+	h.expectItem(t, 2, "loop2.ts", 0)
+
+	entries, err := h.store.GetAsRunLog(context.Background(), h.channel.ChannelID, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("as-run log has %d entries, want 2", len(entries))
+	}
+	played, onAir := entries[1], entries[0]
+	if !played.StartedAt.Equal(at(14, "06:00:15")) || played.StartOffset != 5 ||
+		!played.EndedAt.Valid || played.EndPosition.Float64 != 20 || played.EndReason.String != models.AsRunCompleted {
+		t.Errorf("first entry = %+v, want item from 5s to 20s completed", played)
+	}
+	if !onAir.StartedAt.Equal(at(14, "06:00:30")) || onAir.EndedAt.Valid {
+		t.Errorf("second entry = %+v, want on air since 06:00:30", onAir)
+	}
+}
+
+func TestStartModes(t *testing.T) {
+	ctx := context.Background()
+
+	// Items of 10, 20 and 30 seconds loop every minute, so by the schedule
+	// the channel is at the start of the first item on every full minute
+	tests := []struct {
+		name       string
+		mode       string
+		state      *models.ChannelState
+		asRun      *models.AsRunEntry
+		wantIndex  int
+		wantOffset int
+	}{
+		{name: "schedule", mode: models.StartModeSchedule, wantIndex: 0, wantOffset: 0},
+		{
+			name:       "resume",
+			mode:       models.StartModeResume,
+			state:      &models.ChannelState{CurrentItemID: 2, CurrentPosition: 12, LastUpdateTime: at(14, "06:30:20")},
+			wantIndex:  1,
+			wantOffset: 12,
+		},
+		{
+			// The position was recorded 2 seconds before the item left the air
+			name:  "resume compensates for drift",
+			mode:  models.StartModeResume,
+			state: &models.ChannelState{CurrentItemID: 2, CurrentPosition: 12, LastUpdateTime: at(14, "06:30:20")},
+			asRun: &models.AsRunEntry{ItemID: 2, StartedAt: at(14, "06:30:08"),
+				EndedAt: sql.NullTime{Time: at(14, "06:30:22"), Valid: true}},
+			wantIndex:  1,
+			wantOffset: 14,
+		},
+		{
+			name:       "resume past the end of the item",
+			mode:       models.StartModeResume,
+			state:      &models.ChannelState{CurrentItemID: 2, CurrentPosition: 25, LastUpdateTime: at(14, "06:30:20")},
+			wantIndex:  2,
+			wantOffset: 5,
+		},
+		{
+			name:       "resume from an earlier day",
+			mode:       models.StartModeResume,
+			state:      &models.ChannelState{CurrentItemID: 2, CurrentPosition: 12, LastUpdateTime: at(13, "23:00:00")},
+			wantIndex:  0,
+			wantOffset: 0,
+		},
+		{
+			// 5 seconds into the second item at 06:59:30 is 15 seconds into
+			// the third by 07:00
+			name:       "schedule locked",
+			mode:       models.StartModeScheduleLocked,
+			asRun:      &models.AsRunEntry{ItemID: 2, StartedAt: at(14, "06:59:30"), StartOffset: 5},
+			wantIndex:  2,
+			wantOffset: 15,
+		},
+		{name: "schedule locked without as-run", mode: models.StartModeScheduleLocked, wantIndex: 0, wantOffset: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newPlayoutHarness(t, at(14, "07:00:00"))
+			h.addPlaylist(t, nil, "loop", 10, 20, 30)
+			h.channel.StartMode = test.mode
+			if test.state != nil {
+				test.state.ChannelID = h.channel.ChannelID
+				test.state.CurrentPlaylistID = 1
+				if err := h.store.UpdateChannelState(ctx, test.state); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.asRun != nil {
+				test.asRun.ChannelID = h.channel.ChannelID
+				test.asRun.PlaylistID = 1
+				if err := h.store.CreateAsRunEntry(ctx, test.asRun); err != nil {
+					t.Fatal(err)
+				}
+				if test.asRun.EndedAt.Valid {
+					if err := h.store.EndAsRunEntry(ctx, test.asRun.AsRunID, test.asRun.EndedAt.Time, 0, models.AsRunStopped); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if err := h.executor.initializePlaylist(ctx, h.channel); err != nil {
+				t.Fatalf("initializePlaylist: %v", err)
+			}
+			if index, offset := h.executor.currentState.currentIndex, h.executor.currentState.startOffset; index != test.wantIndex || offset != test.wantOffset {
+				t.Errorf("start position = item %d at %ds, want item %d at %ds", index, offset, test.wantIndex, test.wantOffset)
+			}
+		})
+	}
+}
+
+func TestInitializePlaylistRejectsUnknownStartMode(t *testing.T) {
+	h := newPlayoutHarness(t, at(14, "07:00:00"))
+	h.addPlaylist(t, nil, "loop", 60)
+	h.channel.StartMode = "rewind"
+
+	if err := h.executor.initializePlaylist(context.Background(), h.channel); err == nil {
+		t.Error("initializePlaylist accepted an unknown start mode")
+	}
+}
+
+func TestAudioLanguages(t *testing.T) {
+	tests := []struct {
+		setting sql.NullString
+		want    []string
+	}{
+		{sql.NullString{}, nil},
+		{sql.NullString{String: "eng", Valid: true}, []string{"eng"}},
+		{sql.NullString{String: " ENG, fra ,,english,de", Valid: true}, []string{"eng", "fra"}},
+	}
+	for _, tt := range tests {
+		channel := &models.Channel{AudioLanguages: tt.setting}
+		if got := audioLanguages(channel); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("audioLanguages(%q) = %q, want %q", tt.setting.String, got, tt.want)
+		}
+	}
+}
+
+func TestSidecarSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	media := filepath.Join(dir, "film.mp4")
+	if got := sidecarSubtitles(media); got != "" {
+		t.Errorf("sidecarSubtitles without an SRT file = %q", got)
+	}
+
+	srt := filepath.Join(dir, "film.srt")
+	if err := os.WriteFile(srt, []byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := sidecarSubtitles(media); got != srt {
+		t.Errorf("sidecarSubtitles = %q, want %q", got, srt)
+	}
+
+	// A quote would break out of the filter graph's quoting
+	quoted := filepath.Join(dir, "it's.mp4")
+	if err := os.WriteFile(filepath.Join(dir, "it's.srt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := sidecarSubtitles(quoted); got != "" {
+		t.Errorf("sidecarSubtitles(%q) = %q, want none", quoted, got)
+	}
+}
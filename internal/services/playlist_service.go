@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// playlistStore is what the playlist service needs from the database.
+type playlistStore interface {
+	database.MediaStore
+	database.PlaylistStore
+}
+
+// PlaylistService reads the playlists and media files of channels. Playout
+// itself is done by each channel's PlaylistExecutor.
+type PlaylistService struct {
+	repo playlistStore
+}
+
+func NewPlaylistService(repo playlistStore) *PlaylistService {
+	return &PlaylistService{repo: repo}
+}
+
+func (s *PlaylistService) GetPlaylists(ctx context.Context, channelID int) ([]*models.Playlist, error) {
+	playlist, err := s.repo.GetPlaylists(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlists: %w", err)
+	}
+
+	return playlist, err
+}
+
+func (s *PlaylistService) GetPlaylist(ctx context.Context, playlistID int) (*models.Playlist, error) {
+	return s.repo.GetPlaylist(ctx, playlistID)
+}
+
+func (s *PlaylistService) GetPlaylistItems(ctx context.Context, playlistID int) ([]*models.PlaylistItem, error) {
+	return s.repo.GetPlaylistItems(ctx, playlistID)
+}
+
+func (s *PlaylistService) GetMediaFiles(ctx context.Context, channelID int, page, pageSize int) ([]*models.MediaFile, error) {
+	return s.repo.GetMediaFiles(ctx, channelID, page, pageSize)
+}
+
+func (s *PlaylistService) CountMediaFiles(ctx context.Context, channelID int) (int, error) {
+	return s.repo.CountMediaFiles(ctx, channelID)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/euacreations/tvheadend/internal/metrics"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+)
+
+// ErrPlayoutStopped is returned for commands sent to an executor that is no
+// longer playing.
+var ErrPlayoutStopped = errors.New("playout has stopped")
+
+// ErrInvalidCommand is returned for a command the item on air cannot carry
+// out, e.g. seeking within a live stream.
+var ErrInvalidCommand = errors.New("invalid playout command")
+
+// Kinds of command an executor takes while an item is on air.
+const (
+	cmdReloadOverlays = "reload_overlays"
+	cmdSkip           = "skip"
+	cmdRestart        = "restart"
+	cmdSeek           = "seek"
+	cmdJump           = "jump"
+	cmdSlate          = "slate"
+	cmdUnslate        = "unslate"
+)
+
+// command asks the executor's goroutine to act on the item on air. It
+// answers on reply once it has.
+type command struct {
+	kind     string
+	position int // Seconds into the item, for seek
+	itemID   int // Item to play next, for jump
+	reply    chan error
+}
+
+// send hands a command to the executor and waits for the result. Commands
+// are taken while an item is on air, so one sent between items waits for
+// the next to start.
+func (e *PlaylistExecutor) send(ctx context.Context, cmd command) error {
+	cmd.reply = make(chan error, 1)
+	select {
+	case e.commands <- cmd:
+	case <-e.done:
+		return ErrPlayoutStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return <-cmd.reply
+}
+
+// Skip ends the item on air and moves on to the next.
+func (e *PlaylistExecutor) Skip(ctx context.Context) error {
+	return e.send(ctx, command{kind: cmdSkip})
+}
+
+// Restart plays the item on air again from its start.
+func (e *PlaylistExecutor) Restart(ctx context.Context) error {
+	return e.send(ctx, command{kind: cmdRestart})
+}
+
+// Seek plays the media file on air from the given number of seconds in.
+func (e *PlaylistExecutor) Seek(ctx context.Context, position int) error {
+	return e.send(ctx, command{kind: cmdSeek, position: position})
+}
+
+// Jump ends the item on air and carries on from the given item of the
+// playlist.
+func (e *PlaylistExecutor) Jump(ctx context.Context, itemID int) error {
+	return e.send(ctx, command{kind: cmdJump, itemID: itemID})
+}
+
+// Slate takes the item on air off for the channel's emergency slate. The
+// item is paused and picks up where it left off on Unslate.
+func (e *PlaylistExecutor) Slate(ctx context.Context) error {
+	return e.send(ctx, command{kind: cmdSlate})
+}
+
+// Unslate takes the slate off air and resumes the paused item.
+func (e *PlaylistExecutor) Unslate(ctx context.Context) error {
+	return e.send(ctx, command{kind: cmdUnslate})
+}
+
+// ReloadOverlays applies overlay changes to the item on air.
+func (e *PlaylistExecutor) ReloadOverlays(ctx context.Context) error {
+	return e.send(ctx, command{kind: cmdReloadOverlays})
+}
+
+// Progress returns the latest encoding statistics of the item on air.
+func (e *PlaylistExecutor) Progress() ffmpeg.Progress {
+	return e.ffmpeg.Progress()
+}
+
+// Position returns how far into the item on air FFmpeg is, in seconds.
+func (e *PlaylistExecutor) Position() float64 {
+	return e.ffmpeg.Position()
+}
+
+// PID returns the process ID of FFmpeg, or 0 when it is not running.
+func (e *PlaylistExecutor) PID() int {
+	return e.ffmpeg.PID()
+}
+
+// handleCommand carries out a command on the item on air. It reports
+// whether the item has left the air, and what playItem returns if so.
+func (e *PlaylistExecutor) handleCommand(ctx context.Context, channel *models.Channel,
+	item *models.PlaylistItem, cmd command, cancel context.CancelFunc) (bool, error) {
+
+	switch cmd.kind {
+	case cmdReloadOverlays:
+		restart, err := e.reloadOverlays(ctx)
+		cmd.reply <- err
+		if restart {
+			return true, e.restartItem(channel, item, cancel)
+		}
+		return false, nil
+
+	case cmdSkip:
+		cancel()
+		e.ffmpeg.Reset()
+		e.endAsRun(models.AsRunSkipped)
+		cmd.reply <- nil
+		return true, nil
+
+	case cmdRestart:
+		e.currentState.startOffset = 0
+		cancel()
+		e.endAsRun(models.AsRunRestarted)
+		metrics.FFmpegRestarts.Inc(strconv.Itoa(channel.ChannelID))
+		cmd.reply <- nil
+		return true, errRestartItem
+
+	case cmdJump:
+		index := itemIndex(e.currentState.items, cmd.itemID)
+		if index < 0 {
+			cmd.reply <- fmt.Errorf("%w: item %d is not in playlist %d", ErrInvalidCommand, cmd.itemID, e.currentState.playlist.PlaylistID)
+			return false, nil
+		}
+
+		// Play the target next instead of the queued item
+		e.unlockItem(e.currentState.items[e.currentState.nextIndex])
+		e.currentState.nextIndex = index
+		e.lockItem(e.currentState.items[index])
+
+		cancel()
+		e.ffmpeg.Reset()
+		e.endAsRun(models.AsRunJumped)
+		cmd.reply <- nil
+		return true, nil
+
+	case cmdSlate:
+		if !channel.SlateFile.Valid || channel.SlateFile.String == "" {
+			cmd.reply <- fmt.Errorf("%w: channel %d has no slate", ErrInvalidCommand, channel.ChannelID)
+			return false, nil
+		}
+
+		// Resume a media file where it was paused; a stream is live
+		if item.Type == models.PlaylistItemTypeMedia {
+			e.currentState.startOffset = int(e.ffmpeg.Position())
+		}
+		e.currentState.slated = true
+		cancel()
+		e.endAsRun(models.AsRunSlated)
+		cmd.reply <- nil
+		return true, errRestartItem
+
+	case cmdUnslate:
+		cmd.reply <- fmt.Errorf("%w: channel is not on the slate", ErrInvalidCommand)
+		return false, nil
+
+	case cmdSeek:
+		if item.Type != models.PlaylistItemTypeMedia {
+			cmd.reply <- fmt.Errorf("%w: item %d is a stream and cannot seek", ErrInvalidCommand, item.ItemID)
+			return false, nil
+		}
+		duration, err := e.itemDuration(ctx, item)
+		if err != nil {
+			cmd.reply <- err
+			return false, nil
+		}
+		if cmd.position < 0 || cmd.position >= duration {
+			cmd.reply <- fmt.Errorf("%w: position %ds is outside item %d of %ds", ErrInvalidCommand, cmd.position, item.ItemID, duration)
+			return false, nil
+		}
+
+		e.currentState.startOffset = cmd.position
+		cancel()
+		e.endAsRun(models.AsRunSeeked)
+		metrics.FFmpegRestarts.Inc(strconv.Itoa(channel.ChannelID))
+		cmd.reply <- nil
+		return true, errRestartItem
+	}
+
+	cmd.reply <- fmt.Errorf("%w: %s", ErrInvalidCommand, cmd.kind)
+	return false, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/internal/database"
+)
+
+// settingsStore is what the settings service needs from the database.
+type settingsStore interface {
+	database.SettingStore
+	database.LogStore
+}
+
+// SettingsService reads and changes the runtime settings.
+type SettingsService struct {
+	repo     settingsStore
+	settings *config.Settings
+}
+
+func NewSettingsService(repo settingsStore, settings *config.Settings) *SettingsService {
+	return &SettingsService{repo: repo, settings: settings}
+}
+
+// ListSettings returns every runtime setting with its current value.
+func (s *SettingsService) ListSettings() []config.SettingValue {
+	return s.settings.All()
+}
+
+// UpdateSettings stores changed settings, which services pick up the next
+// time they read them.
+func (s *SettingsService) UpdateSettings(ctx context.Context, changes map[string]string) ([]config.SettingValue, error) {
+	existing := make(map[string]string, len(changes))
+	for key := range changes {
+		existing[key] = s.settings.Get(key)
+	}
+
+	if err := s.settings.Update(ctx, s.repo, changes); err != nil {
+		return nil, fmt.Errorf("failed to update settings: %w", err)
+	}
+
+	updated := make(map[string]string, len(changes))
+	for key := range changes {
+		updated[key] = s.settings.Get(key)
+	}
+	recordAudit(ctx, s.repo, "settings_update", "settings", 0, marshalAuditValue(&existing), marshalAuditValue(&updated))
+
+	return s.settings.All(), nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/euacreations/tvheadend/internal/events"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// slatePath returns where the channel's slate file lives. A relative path
+// is taken from the channel's storage root.
+func slatePath(channel *models.Channel) string {
+	if filepath.IsAbs(channel.SlateFile.String) {
+		return channel.SlateFile.String
+	}
+	return filepath.Join(channel.StorageRoot, channel.SlateFile.String)
+}
+
+// playSlate loops the channel's slate until an operator takes it off air.
+// The paused item stays current so that Execute resumes it afterwards.
+func (e *PlaylistExecutor) playSlate(ctx context.Context, channel *models.Channel) error {
+	item := e.currentState.current
+	inputPath := slatePath(channel)
+
+	e.ffmpeg.Reset()
+	e.ffmpeg.SetProgressCallback(nil)
+
+	config := streamConfig(channel, inputPath, models.PlaylistItemTypeMedia, 0, 0)
+	config.Loop = true
+	config.AudioLanguages = audioLanguages(channel)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	e.currentState.streamCancel = cancel
+
+	if err := e.ffmpeg.Start(streamCtx, config); err != nil {
+		return err
+	}
+	e.startSlateAsRun(channel, item, e.currentState.startOffset)
+
+	state := &models.ChannelState{
+		ChannelID:         channel.ChannelID,
+		CurrentPlaylistID: item.PlaylistID,
+		CurrentItemID:     item.ItemID,
+		CurrentPosition:   float64(e.currentState.startOffset),
+		Running:           true,
+		FFmpegPID:         e.ffmpeg.PID(),
+		OnSlate:           true,
+		LastUpdateTime:    e.clock.Now(),
+	}
+	if err := e.repo.UpdateChannelState(ctx, state); err != nil {
+		log.Printf("Failed to update channel %d state: %v", channel.ChannelID, err)
+	}
+
+	e.events.Publish(events.Event{
+		ChannelID: channel.ChannelID,
+		Type:      events.SlateOn,
+		Severity:  events.SeverityWarning,
+		Category:  events.CategoryChannel,
+		Message:   fmt.Sprintf("Slate on air, item %d paused at %ds", item.ItemID, e.currentState.startOffset),
+		Details: map[string]interface{}{
+			"playlist_id": item.PlaylistID,
+			"item_id":     item.ItemID,
+			"position":    e.currentState.startOffset,
+			"input":       inputPath,
+			"pid":         e.ffmpeg.PID(),
+		},
+	})
+
+	for {
+		select {
+		case <-streamCtx.Done():
+			e.endAsRun(models.AsRunStopped)
+			return streamCtx.Err()
+		case <-e.ffmpeg.Done():
+			// Programming beats dead air if the slate itself fails
+			e.currentState.slated = false
+			e.endAsRun(models.AsRunFailed)
+			e.events.Publish(events.Event{
+				ChannelID: channel.ChannelID,
+				Type:      events.SlateOff,
+				Severity:  events.SeverityError,
+				Category:  events.CategoryChannel,
+				Message:   fmt.Sprintf("Slate exited with code %d, resuming item %d", e.ffmpeg.ExitCode(), item.ItemID),
+			})
+			return nil
+		case cmd := <-e.commands:
+			switch cmd.kind {
+			case cmdUnslate:
+				e.currentState.slated = false
+				cancel()
+				e.ffmpeg.Reset()
+				e.endAsRun(models.AsRunUnslated)
+				e.events.Publish(events.Event{
+					ChannelID: channel.ChannelID,
+					Type:      events.SlateOff,
+					Category:  events.CategoryChannel,
+					Message:   fmt.Sprintf("Slate off air, resuming item %d from %ds", item.ItemID, e.currentState.startOffset),
+				})
+				cmd.reply <- nil
+				return nil
+			case cmdReloadOverlays:
+				// The slate carries no overlays; the item picks them up on resume
+				cmd.reply <- nil
+			default:
+				cmd.reply <- fmt.Errorf("%w: channel is on the slate", ErrInvalidCommand)
+			}
+		}
+	}
+}
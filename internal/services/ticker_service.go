@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// maxTickerHeadlines caps how many headlines are taken from a feed.
+const maxTickerHeadlines = 50
+
+// tickerStore is what the ticker service needs from the database.
+type tickerStore interface {
+	database.ChannelStore
+	database.OverlayStore
+}
+
+// TickerService keeps ticker overlays that have a feed URL up to date by
+// polling their feeds and storing the headlines.
+type TickerService struct {
+	repo        tickerStore
+	overlays    *OverlayService
+	client      *http.Client
+	lastPolled  map[int]time.Time
+	lastPollMux sync.Mutex
+}
+
+func NewTickerService(repo tickerStore, overlays *OverlayService) *TickerService {
+	return &TickerService{
+		repo:       repo,
+		overlays:   overlays,
+		client:     &http.Client{Timeout: 15 * time.Second},
+		lastPolled: make(map[int]time.Time),
+	}
+}
+
+// Run polls due feeds until the context is cancelled.
+func (s *TickerService) Run(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		s.pollDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *TickerService) pollDue(ctx context.Context) {
+	tickers, err := s.repo.GetFeedTickers(ctx)
+	if err != nil {
+		log.Printf("Failed to get feed tickers: %v", err)
+		return
+	}
+
+	for _, overlay := range tickers {
+		s.lastPollMux.Lock()
+		due := time.Since(s.lastPolled[overlay.OverlayID]) >= time.Duration(overlay.FeedInterval)*time.Second
+		if due {
+			s.lastPolled[overlay.OverlayID] = time.Now()
+		}
+		s.lastPollMux.Unlock()
+
+		if !due {
+			continue
+		}
+
+		if err := s.RefreshFeed(ctx, overlay); err != nil {
+			log.Printf("Failed to refresh feed for ticker %d: %v", overlay.OverlayID, err)
+		}
+	}
+}
+
+// RefreshFeed fetches the feed of a ticker overlay and replaces its headlines.
+func (s *TickerService) RefreshFeed(ctx context.Context, overlay *models.Overlay) error {
+	data, err := s.fetch(ctx, overlay)
+	if err != nil {
+		return err
+	}
+
+	headlines, err := parseHeadlines(data)
+	if err != nil {
+		return err
+	}
+	if len(headlines) == 0 {
+		// Keep showing the previous headlines rather than an empty band
+		return fmt.Errorf("feed returned no headlines")
+	}
+	if len(headlines) > maxTickerHeadlines {
+		headlines = headlines[:maxTickerHeadlines]
+	}
+
+	current, err := s.repo.GetTickerHeadlines(ctx, overlay.OverlayID)
+	if err == nil && sameHeadlines(current, headlines) {
+		return nil
+	}
+
+	_, err = s.overlays.SetHeadlines(ctx, overlay.OverlayID, headlines)
+	return err
+}
+
+func (s *TickerService) fetch(ctx context.Context, overlay *models.Overlay) ([]byte, error) {
+	u, err := url.Parse(overlay.FeedURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed URL: %w", err)
+	}
+
+	if u.Scheme == "file" {
+		channel, err := s.repo.GetChannelByID(ctx, overlay.ChannelID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get channel: %w", err)
+		}
+		return readFeedFile(channel.StorageRoot, u.Path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, overlay.FeedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+}
+
+// feedFilePath returns the cleaned path of a file feed, which must lie
+// within the storage root.
+func feedFilePath(storageRoot, path string) (string, error) {
+	path = filepath.Clean(path)
+	if storageRoot == "" || !filepath.IsAbs(path) {
+		return "", fmt.Errorf("file feed %s must be within the channel's storage root", path)
+	}
+	rel, err := filepath.Rel(filepath.Clean(storageRoot), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file feed %s must be within the channel's storage root", path)
+	}
+	return path, nil
+}
+
+// readFeedFile reads a file feed, checking it against the storage root
+// again once symlinks are resolved.
+func readFeedFile(storageRoot, path string) ([]byte, error) {
+	path, err := feedFilePath(storageRoot, path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(storageRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage root: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	if _, err := feedFilePath(root, resolved); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(resolved)
+}
+
+// parseHeadlines extracts headlines from an RSS or Atom document, or from JSON
+// that is either a list of strings, a list of objects with a "title", or an
+// object holding such a list under "headlines" or "items".
+func parseHeadlines(data []byte) ([]string, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	if trimmed[0] == '<' {
+		var feed struct {
+			Items []struct {
+				Title string `xml:"title"`
+			} `xml:"channel>item"`
+			Entries []struct {
+				Title string `xml:"title"`
+			} `xml:"entry"`
+		}
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("failed to parse XML feed: %w", err)
+		}
+
+		var headlines []string
+		for _, item := range feed.Items {
+			headlines = appendHeadline(headlines, item.Title)
+		}
+		for _, entry := range feed.Entries {
+			headlines = appendHeadline(headlines, entry.Title)
+		}
+		return headlines, nil
+	}
+
+	var list []json.RawMessage
+	if trimmed[0] == '{' {
+		var wrapper struct {
+			Headlines []json.RawMessage `json:"headlines"`
+			Items     []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON feed: %w", err)
+		}
+		list = append(wrapper.Headlines, wrapper.Items...)
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON feed: %w", err)
+	}
+
+	var headlines []string
+	for _, raw := range list {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			headlines = appendHeadline(headlines, text)
+			continue
+		}
+		var item struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(raw, &item); err == nil {
+			headlines = appendHeadline(headlines, item.Title)
+		}
+	}
+	return headlines, nil
+}
+
+func appendHeadline(headlines []string, text string) []string {
+	// Feeds often carry line breaks and runs of spaces that would break the crawl
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return headlines
+	}
+	return append(headlines, text)
+}
+
+func sameHeadlines(current []*models.TickerHeadline, headlines []string) bool {
+	if len(current) != len(headlines) {
+		return false
+	}
+	for i, headline := range current {
+		if headline.Text != headlines[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func TestParseHeadlines(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{"rss", `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>News</title>
+  <item><title>First  story</title></item>
+  <item><title>
+    Second story
+  </title></item>
+  <item><title></title></item>
+</channel></rss>`, []string{"First story", "Second story"}, false},
+		{"atom", `<feed xmlns="http://www.w3.org/2005/Atom"><title>News</title>
+  <entry><title>Atom story</title></entry>
+  <entry><title type="html">Another</title></entry>
+</feed>`, []string{"Atom story", "Another"}, false},
+		{"json strings", `["one", " two ", ""]`, []string{"one", "two"}, false},
+		{"json objects", `[{"title": "one"}, {"title": "two", "link": "x"}, {"link": "y"}]`, []string{"one", "two"}, false},
+		{"json headlines", `{"headlines": ["one", {"title": "two"}]}`, []string{"one", "two"}, false},
+		{"json items", `{"items": [{"title": "one"}]}`, []string{"one"}, false},
+		{"empty", "  \n", nil, false},
+		{"invalid xml", `<rss><channel><item>`, nil, true},
+		{"invalid json", `{"headlines": [`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHeadlines([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHeadlines error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseHeadlines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedFilePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"/srv/one/feeds/news.xml", false},
+		{"/srv/one/feeds/../news.xml", false},
+		{"/srv/one", false},
+		{"/srv/one/../two/news.xml", true},
+		{"/srv/onetwo/news.xml", true},
+		{"/etc/passwd", true},
+		{"feeds/news.xml", true},
+	}
+	for _, tt := range tests {
+		if _, err := feedFilePath("/srv/one/", tt.path); (err != nil) != tt.wantErr {
+			t.Errorf("feedFilePath(%q) error = %v, want error %v", tt.path, err, tt.wantErr)
+		}
+	}
+	if _, err := feedFilePath("", "/srv/one/news.xml"); err == nil {
+		t.Error("feedFilePath accepted a channel without a storage root")
+	}
+}
+
+func TestReadFeedFile(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "news.json"), []byte(`["one"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "link.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := readFeedFile(root, filepath.Join(root, "news.json")); err != nil || string(data) != `["one"]` {
+		t.Errorf("readFeedFile = %q, %v", data, err)
+	}
+	if _, err := readFeedFile(root, filepath.Join(outside, "secret")); err == nil {
+		t.Error("readFeedFile read a file outside the storage root")
+	}
+	if _, err := readFeedFile(root, filepath.Join(root, "link.json")); err == nil {
+		t.Error("readFeedFile followed a symlink out of the storage root")
+	}
+}
+
+func TestTickerTextIsWrittenAsIs(t *testing.T) {
+	headlines := []*models.TickerHeadline{{Text: "Inflation hits 5%"}, {Text: `C:\feeds says %{pts}`}}
+	want := `Inflation hits 5%   •   C:\feeds says %{pts}`
+	if got := tickerText(headlines); got != want {
+		t.Fatalf("tickerText = %q, want %q", got, want)
+	}
+
+	// FFmpeg reads the file with expansion off, so it must hold the text unescaped
+	path := filepath.Join(t.TempDir(), "overlay_1.txt")
+	if err := writeTextFile(path, tickerText(headlines)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != want {
+		t.Errorf("text file = %q, %v; want %q", data, err, want)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+
+	"github.com/euacreations/tvheadend/internal/database"
+	"github.com/euacreations/tvheadend/internal/models"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+)
+
+// defaultTitleFont is used for program titles when neither the channel's
+// title style nor the media file's language names a font.
+const defaultTitleFont = "FM-Malithi-x.ttf"
+
+// defaultTitleStyle is how program titles are drawn on channels that have
+// no title styles configured.
+var defaultTitleStyle = models.TitleStyle{
+	Enabled:   true,
+	FontSize:  "H/30",
+	FontColor: "white",
+	PositionX: "W/12",
+	PositionY: "H/12",
+}
+
+// selectTitleStyle picks the style for a media file's language, falling back
+// to the channel default and then to the built-in style.
+func selectTitleStyle(styles []*models.TitleStyle, languageID sql.NullInt64) models.TitleStyle {
+	var channelDefault *models.TitleStyle
+	for _, style := range styles {
+		if !style.LanguageID.Valid {
+			channelDefault = style
+			continue
+		}
+		if languageID.Valid && style.LanguageID.Int64 == languageID.Int64 {
+			return *style
+		}
+	}
+	if channelDefault != nil {
+		return *channelDefault
+	}
+	return defaultTitleStyle
+}
+
+// programTitleOverlay builds the overlay that shows a media file's program
+// name. It returns nil when the file has no program name or the title is
+// disabled for its language.
+func programTitleOverlay(ctx context.Context, repo database.OverlayStore, channel *models.Channel, media *models.MediaFile) (*models.Overlay, error) {
+	if media == nil || media.ProgramName.String == "" {
+		return nil, nil
+	}
+
+	styles, err := repo.GetTitleStyles(ctx, channel.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	style := selectTitleStyle(styles, media.LanguageID)
+	if !style.Enabled {
+		return nil, nil
+	}
+
+	fontFile := style.FontFile
+	if fontFile == "" && media.LanguageID.Valid {
+		language, err := repo.GetLanguage(ctx, int(media.LanguageID.Int64))
+		if err != nil {
+			return nil, err
+		}
+		fontFile = language.FontFile
+	}
+	if fontFile == "" {
+		fontFile = defaultTitleFont
+	}
+
+	return &models.Overlay{
+		Type:      ffmpeg.OverlayTypeText,
+		Text:      media.ProgramName.String,
+		PositionX: style.PositionX,
+		PositionY: style.PositionY,
+		FontSize:  style.FontSize,
+		FontColor: style.FontColor,
+		FontFile:  filepath.Join(channel.StorageRoot, "data", fontFile),
+	}, nil
+}
+
+// GetTitleStyles returns the program title styles of a channel.
+func (s *OverlayService) GetTitleStyles(ctx context.Context, channelID int) ([]*models.TitleStyle, error) {
+	if _, err := s.repo.GetChannelByID(ctx, channelID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTitleStyles(ctx, channelID)
+}
+
+// SetTitleStyles replaces the program title styles of a channel. At most one
+// style may be given per language, and at most one without a language.
+func (s *OverlayService) SetTitleStyles(ctx context.Context, channelID int, styles []*models.TitleStyle) ([]*models.TitleStyle, error) {
+	existing, err := s.GetTitleStyles(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	for _, style := range styles {
+		setTitleStyleDefaults(style)
+
+		key := int64(-1)
+		if style.LanguageID.Valid {
+			key = style.LanguageID.Int64
+			if _, err := s.repo.GetLanguage(ctx, int(key)); err != nil {
+				return nil, fmt.Errorf("%w: unknown language %d", ErrInvalidOverlay, key)
+			}
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: more than one title style for the same language", ErrInvalidOverlay)
+		}
+		seen[key] = true
+
+		if err := checkTitleStyle(style); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOverlay, err)
+		}
+	}
+
+	if err := s.repo.ReplaceTitleStyles(ctx, channelID, styles); err != nil {
+		return nil, fmt.Errorf("failed to store title styles: %w", err)
+	}
+	updated, err := s.repo.GetTitleStyles(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(ctx, s.repo, "title_styles_update", "channel", channelID, marshalAuditValue(&existing), marshalAuditValue(&updated))
+	s.notifyChange(channelID)
+
+	return updated, nil
+}
+
+// ListLanguages returns the languages media files can be tagged with.
+func (s *OverlayService) ListLanguages(ctx context.Context) ([]*models.Language, error) {
+	return s.repo.GetLanguages(ctx)
+}
+
+func setTitleStyleDefaults(style *models.TitleStyle) {
+	if style.FontSize == "" {
+		style.FontSize = defaultTitleStyle.FontSize
+	}
+	if style.FontColor == "" {
+		style.FontColor = defaultTitleStyle.FontColor
+	}
+	if style.PositionX == "" {
+		style.PositionX = defaultTitleStyle.PositionX
+	}
+	if style.PositionY == "" {
+		style.PositionY = defaultTitleStyle.PositionY
+	}
+}
+
+func checkTitleStyle(style *models.TitleStyle) error {
+	if err := validateExpression("position_x", style.PositionX); err != nil {
+		return err
+	}
+	if err := validateExpression("position_y", style.PositionY); err != nil {
+		return err
+	}
+	if err := validateExpression("font_size", style.FontSize); err != nil {
+		return err
+	}
+	if err := validateColor("font_color", style.FontColor); err != nil {
+		return err
+	}
+	return validateAssetPath("font_file", style.FontFile)
+}
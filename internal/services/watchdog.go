@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/config"
+	"github.com/euacreations/tvheadend/pkg/ffmpeg"
+)
+
+// watchdog notices when the FFmpeg process of the item on air keeps running
+// but stops producing output, or produces it too slowly to keep up with
+// real time.
+type watchdog struct {
+	interval     time.Duration
+	stallTimeout time.Duration
+	slowTimeout  time.Duration
+	minSpeed     float64
+	maxRestarts  int
+
+	lastPosition float64
+	lastAdvance  time.Time
+	slowSince    time.Time
+}
+
+// newWatchdog watches an item with the settings current when it starts.
+func newWatchdog(settings *config.Settings, started time.Time) *watchdog {
+	return &watchdog{
+		interval:     settings.Seconds("health_check_interval"),
+		stallTimeout: settings.Seconds("stall_timeout_seconds"),
+		slowTimeout:  settings.Seconds("slow_speed_timeout_seconds"),
+		minSpeed:     settings.Float("min_encode_speed"),
+		maxRestarts:  settings.Int("max_stall_restarts"),
+		lastAdvance:  started,
+	}
+}
+
+// check looks at the latest progress and returns why the item has to be
+// restarted, or an empty string while it is healthy.
+func (w *watchdog) check(progress ffmpeg.Progress, now time.Time) string {
+	if progress.Ended {
+		return ""
+	}
+
+	if progress.Position > w.lastPosition {
+		w.lastPosition = progress.Position
+		w.lastAdvance = now
+	} else if now.Sub(w.lastAdvance) >= w.stallTimeout {
+		return fmt.Sprintf("output stopped advancing at %.1fs for %s", w.lastPosition, now.Sub(w.lastAdvance).Round(time.Second))
+	}
+
+	// Speed is only reported once FFmpeg has written some output
+	if progress.UpdatedAt.IsZero() || w.minSpeed <= 0 || progress.Speed >= w.minSpeed {
+		w.slowSince = time.Time{}
+		return ""
+	}
+	if w.slowSince.IsZero() {
+		w.slowSince = now
+	} else if now.Sub(w.slowSince) >= w.slowTimeout {
+		return fmt.Sprintf("encoding at %.2fx, below %.2fx for %s", progress.Speed, w.minSpeed, now.Sub(w.slowSince).Round(time.Second))
+	}
+	return ""
+}
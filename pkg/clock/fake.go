@@ -0,0 +1,162 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a virtual clock for tests. Time stands still until Advance moves
+// it, firing the tickers and timers that fall due on the way in order.
+// AfterFunc calls run synchronously within Advance.
+type Fake struct {
+	mux     sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	seq     int
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock  *Fake
+	when   time.Time
+	seq    int // Orders waiters due at the same time
+	period time.Duration
+	ticks  chan time.Time // Tickers
+	f      func()         // Timers
+}
+
+// NewFake returns a virtual clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.changed = sync.NewCond(&f.mux)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration { return f.Now().Sub(t) }
+func (f *Fake) Until(t time.Time) time.Duration { return t.Sub(f.Now()) }
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{period: d, ticks: make(chan time.Time, 1)}
+	f.add(w, d)
+	return fakeTicker{w}
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	w := &fakeWaiter{f: fn}
+	f.add(w, d)
+	return w
+}
+
+func (f *Fake) add(w *fakeWaiter, d time.Duration) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	w.clock = f
+	w.when = f.now.Add(d)
+	f.schedule(w)
+}
+
+// schedule queues a waiter. The caller must hold mux.
+func (f *Fake) schedule(w *fakeWaiter) {
+	f.seq++
+	w.seq = f.seq
+	f.waiters = append(f.waiters, w)
+	sort.SliceStable(f.waiters, func(i, j int) bool {
+		if !f.waiters[i].when.Equal(f.waiters[j].when) {
+			return f.waiters[i].when.Before(f.waiters[j].when)
+		}
+		return f.waiters[i].seq < f.waiters[j].seq
+	})
+	f.changed.Broadcast()
+}
+
+// remove unqueues a waiter and reports whether it was queued. The caller
+// must hold mux.
+func (f *Fake) remove(w *fakeWaiter) bool {
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// Set moves the clock to t, firing whatever falls due on the way.
+func (f *Fake) Set(t time.Time) {
+	f.Advance(t.Sub(f.Now()))
+}
+
+// Advance moves the clock forward by d. Tickers drop ticks their reader has
+// not taken yet, as real ones do.
+func (f *Fake) Advance(d time.Duration) {
+	f.mux.Lock()
+	target := f.now.Add(d)
+
+	for len(f.waiters) > 0 && !f.waiters[0].when.After(target) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		f.now = w.when
+
+		if w.ticks != nil {
+			select {
+			case w.ticks <- w.when:
+			default:
+			}
+			w.when = w.when.Add(w.period)
+			f.schedule(w)
+			continue
+		}
+
+		f.changed.Broadcast()
+		f.mux.Unlock()
+		w.f()
+		f.mux.Lock()
+	}
+
+	if target.After(f.now) {
+		f.now = target
+	}
+	f.mux.Unlock()
+}
+
+// Pending returns how many tickers and timers are waiting.
+func (f *Fake) Pending() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil waits until n tickers and timers are waiting, which tells a
+// test that the code under test has settled before it advances the clock.
+func (f *Fake) BlockUntil(n int) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for len(f.waiters) != n {
+		f.changed.Wait()
+	}
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mux.Lock()
+	defer w.clock.mux.Unlock()
+	return w.clock.remove(w)
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.ticks }
+func (t fakeTicker) Stop()               { t.fakeWaiter.Stop() }
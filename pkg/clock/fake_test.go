@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAdvance(t *testing.T) {
+	start := time.Date(2025, 3, 14, 6, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+
+	var fired []time.Time
+	clk.AfterFunc(3*time.Second, func() { fired = append(fired, clk.Now()) })
+	stopped := clk.AfterFunc(4*time.Second, func() { t.Error("stopped timer fired") })
+	ticker := clk.NewTicker(2 * time.Second)
+
+	if !stopped.Stop() {
+		t.Error("Stop of a pending timer reported false")
+	}
+	if clk.Pending() != 2 {
+		t.Errorf("Pending = %d, want 2", clk.Pending())
+	}
+
+	clk.Advance(5 * time.Second)
+	if !clk.Now().Equal(start.Add(5 * time.Second)) {
+		t.Errorf("Now = %v, want %v", clk.Now(), start.Add(5*time.Second))
+	}
+	if len(fired) != 1 || !fired[0].Equal(start.Add(3*time.Second)) {
+		t.Errorf("timer fired at %v, want once at %v", fired, start.Add(3*time.Second))
+	}
+
+	// The tick at 4s was dropped as the one at 2s had not been read
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("tick at %v, want %v", tick, start.Add(2*time.Second))
+		}
+	default:
+		t.Error("ticker did not tick")
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("unexpected tick at %v", tick)
+	default:
+	}
+
+	ticker.Stop()
+	if clk.Pending() != 0 {
+		t.Errorf("Pending = %d after stopping everything, want 0", clk.Pending())
+	}
+}
@@ -0,0 +1,78 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Conditions reported by Detect.
+const (
+	ConditionBlack  = "black"
+	ConditionFrozen = "frozen"
+	ConditionSilent = "silent"
+)
+
+// DetectConfig sets how long a condition must last before it is reported.
+type DetectConfig struct {
+	BlackSeconds  float64
+	FreezeSeconds float64
+	SilentSeconds float64
+}
+
+// detectMarkers maps what the detect filters log to a condition. The end
+// marker is checked first since blackdetect logs both on one line.
+var detectMarkers = []struct {
+	condition  string
+	start, end string
+}{
+	{ConditionBlack, "black_start", "black_end"},
+	{ConditionFrozen, "freeze_start", "freeze_end"},
+	{ConditionSilent, "silence_start", "silence_end"},
+}
+
+// Detect decodes the transport stream read from input and reports black
+// pictures, frozen pictures and silent audio as they start and end. It runs
+// until input ends or the context is cancelled.
+func Detect(ctx context.Context, input io.Reader, config DetectConfig, report func(condition string, active bool)) error {
+	video := fmt.Sprintf("scale=320:-2,blackdetect=d=%.1f:pic_th=0.98,freezedetect=n=-60dB:d=%.1f,metadata=mode=print",
+		config.BlackSeconds, config.FreezeSeconds)
+	audio := fmt.Sprintf("silencedetect=n=-60dB:d=%.1f", config.SilentSeconds)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats", "-loglevel", "info",
+		"-f", "mpegts", "-i", "pipe:0",
+		"-vf", video, "-af", audio,
+		"-f", "null", "-",
+	)
+	cmd.Stdin = input
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get FFmpeg stderr: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start FFmpeg: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, marker := range detectMarkers {
+			switch {
+			case strings.Contains(line, marker.end):
+				report(marker.condition, false)
+			case strings.Contains(line, marker.start):
+				report(marker.condition, true)
+			}
+		}
+	}
+
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("detection stopped: %w", err)
+	}
+	return nil
+}
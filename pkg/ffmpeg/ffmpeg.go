@@ -2,6 +2,7 @@ package ffmpeg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -22,6 +23,7 @@ type StreamConfig struct {
 	OutputURL   string
 	StartOffset time.Duration
 	Duration    time.Duration
+	Loop        bool // Repeat a media input until stopped, e.g. a slate
 	// Video Parameters
 	VideoCodec       string
 	VideoBitrate     string
@@ -33,6 +35,12 @@ type StreamConfig struct {
 	// Audio Parameters
 	AudioCodec   string
 	AudioBitrate string
+	AudioFilter  string // e.g. loudness normalization
+
+	// Stream Mapping
+	AudioLanguages []string // ISO 639-2 codes of the audio tracks to carry; empty carries all
+	PassSubtitles  bool     // Carry DVB subtitle and teletext streams
+	SubtitleFile   string   // SRT file to burn into the picture
 
 	// MPEG-TS Metadata
 	PacketSize              int
@@ -48,19 +56,36 @@ type StreamConfig struct {
 }
 
 const (
-	OverlayTypeText  string = "text"
-	OverlayTypeImage string = "image"
+	OverlayTypeText   string = "text"
+	OverlayTypeImage  string = "image"
+	OverlayTypeTicker string = "ticker"
 )
 
+// Progress is the latest encoding statistics reported by FFmpeg.
+type Progress struct {
+	Position         float64   `json:"position"` // Seconds, including the start offset
+	Frame            int64     `json:"frame"`
+	FPS              float64   `json:"fps"`
+	Bitrate          float64   `json:"bitrate"` // Bits per second
+	TotalSize        int64     `json:"total_size"`
+	Speed            float64   `json:"speed"`
+	DroppedFrames    int64     `json:"dropped_frames"`
+	DuplicatedFrames int64     `json:"duplicated_frames"`
+	Ended            bool      `json:"ended"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
 type Streamer struct {
 	cmd             *exec.Cmd
 	running         bool
 	pid             int
 	mux             sync.Mutex
-	logBuffer       strings.Builder
+	logBuffer       *LogBuffer
 	currentPosition float64
+	progress        Progress
+	exitCode        int
 	done            chan struct{}
-	onProgress      func(position float64)
+	onProgress      func(progress Progress)
 	stopOnce        sync.Once // Ensures cleanup happens only once
 	ctx             context.Context
 	cancel          context.CancelFunc
@@ -69,13 +94,17 @@ type Streamer struct {
 func New() *Streamer {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Streamer{
-		done:   make(chan struct{}),
-		ctx:    ctx,
-		cancel: cancel,
+		logBuffer: NewLogBuffer(DefaultLogLines),
+		done:      make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 }
 
 func (s *Streamer) Start(ctx context.Context, config StreamConfig) error {
+	// Probe before taking the lock; it may take a while on a network input
+	streams := s.probeStreams(ctx, config)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -97,7 +126,9 @@ func (s *Streamer) Start(ctx context.Context, config StreamConfig) error {
 
 	} else if config.InputType == models.PlaylistItemTypeMedia {
 		args = append(args, "-re") // Use -re for file input to simulate real-time
-
+		if config.Loop {
+			args = append(args, "-stream_loop", "-1")
+		}
 	}
 
 	// Place -ss and -t before -i for faster input-level seek/truncate
@@ -145,11 +176,17 @@ func (s *Streamer) Start(ctx context.Context, config StreamConfig) error {
 		"-c:a", config.AudioCodec,
 		"-b:a", config.AudioBitrate,
 	)
+	if config.AudioFilter != "" {
+		args = append(args, "-af", config.AudioFilter)
+	}
 
-	if len(config.Overlays) > 0 {
-		args = append(args, "-filter_complex", s.buildOverlayFilter(config.Overlays, config.OutputResolution))
-		args = append(args, "-map", "[outv]", "-map", "0:a")
+	if len(config.Overlays) > 0 || config.SubtitleFile != "" {
+		args = append(args, "-filter_complex", s.buildOverlayFilter(config.Overlays, config.OutputResolution, config.SubtitleFile, config.StartOffset))
+		args = append(args, "-map", "[outv]")
+	} else {
+		args = append(args, "-map", "0:v:0")
 	}
+	args = append(args, streamMaps(config, streams)...)
 
 	// MPEG-TS parameters
 	args = append(args,
@@ -168,8 +205,10 @@ func (s *Streamer) Start(ctx context.Context, config StreamConfig) error {
 
 	args = append(args, "-progress", "pipe:2")
 
-	// Use the streamer's context for the command
-	s.cmd = exec.CommandContext(s.ctx, "ffmpeg", args...)
+	// The process and its goroutines live until Reset cancels this context.
+	// They are handed it rather than read s.ctx, which Reset replaces.
+	processCtx := s.ctx
+	s.cmd = exec.CommandContext(processCtx, "ffmpeg", args...)
 
 	// Setup stderr log capture
 	stderrPipe, err := s.cmd.StderrPipe()
@@ -177,12 +216,13 @@ func (s *Streamer) Start(ctx context.Context, config StreamConfig) error {
 		return fmt.Errorf("failed to get FFmpeg stderr: %w", err)
 	}
 	s.currentPosition = 0
+	s.progress = Progress{}
 
 	// Progress parsing goroutine
-	go s.parseProgress(stderrPipe, config.StartOffset)
+	go s.parseProgress(processCtx, stderrPipe, config.StartOffset)
 
 	// Progress callback goroutine
-	go s.progressCallback()
+	go s.progressCallback(processCtx)
 
 	if err := s.cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start FFmpeg: %w", err)
@@ -192,12 +232,12 @@ func (s *Streamer) Start(ctx context.Context, config StreamConfig) error {
 	s.pid = s.cmd.Process.Pid
 
 	// Process monitoring goroutine
-	go s.monitorProcess()
+	go s.monitorProcess(s.cmd)
 
 	return nil
 }
 
-func (s *Streamer) parseProgress(stderrPipe io.ReadCloser, startOffset time.Duration) {
+func (s *Streamer) parseProgress(ctx context.Context, stderrPipe io.ReadCloser, startOffset time.Duration) {
 	defer stderrPipe.Close()
 
 	buf := make([]byte, 1024)
@@ -205,7 +245,7 @@ func (s *Streamer) parseProgress(stderrPipe io.ReadCloser, startOffset time.Dura
 
 	for {
 		select {
-		case <-s.ctx.Done():
+		case <-ctx.Done():
 			return
 		default:
 		}
@@ -216,22 +256,29 @@ func (s *Streamer) parseProgress(stderrPipe io.ReadCloser, startOffset time.Dura
 			lineBuf += string(buf[:n])
 
 			for {
-				idx := strings.Index(lineBuf, "\n")
+				// FFmpeg ends some status lines with a carriage return only
+				idx := strings.IndexAny(lineBuf, "\r\n")
 				if idx == -1 {
 					break
 				}
 				line := strings.TrimSpace(lineBuf[:idx])
 				lineBuf = lineBuf[idx+1:]
 
-				// Parse FFmpeg progress key=value lines
-				if strings.HasPrefix(line, "out_time=") {
-					timestamp := strings.TrimPrefix(line, "out_time=")
-					position, err := parseFFmpegTime(timestamp)
-					if err == nil {
-						s.mux.Lock()
-						s.currentPosition = position + startOffset.Seconds()
-						s.mux.Unlock()
-					}
+				if line == "" {
+					continue
+				}
+				s.mux.Lock()
+				if ctx.Err() != nil {
+					// Reset has moved on; the output is of a process it replaced
+					s.mux.Unlock()
+					return
+				}
+				isProgress := parseProgressLine(&s.progress, line, startOffset, time.Now())
+				s.currentPosition = s.progress.Position
+				logBuffer := s.logBuffer
+				s.mux.Unlock()
+				if !isProgress {
+					logBuffer.Add(line)
 				}
 			}
 		}
@@ -241,7 +288,81 @@ func (s *Streamer) parseProgress(stderrPipe io.ReadCloser, startOffset time.Dura
 	}
 }
 
-func (s *Streamer) progressCallback() {
+// progressKeys are the keys FFmpeg writes with -progress.
+var progressKeys = map[string]bool{
+	"frame": true, "fps": true, "bitrate": true, "total_size": true,
+	"out_time_us": true, "out_time_ms": true, "out_time": true,
+	"dup_frames": true, "drop_frames": true, "speed": true, "progress": true,
+}
+
+// parseProgressLine applies one key=value line of FFmpeg's -progress output
+// to progress. It reports whether the line was progress output rather than a
+// log message.
+func parseProgressLine(progress *Progress, line string, startOffset time.Duration, now time.Time) bool {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok || strings.ContainsAny(key, " \t") {
+		return false
+	}
+	if !progressKeys[key] && !strings.HasPrefix(key, "stream_") {
+		return false
+	}
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "out_time":
+		if position, err := parseFFmpegTime(value); err == nil {
+			progress.Position = position + startOffset.Seconds()
+		}
+	case "frame":
+		progress.Frame, _ = strconv.ParseInt(value, 10, 64)
+	case "fps":
+		progress.FPS, _ = strconv.ParseFloat(value, 64)
+	case "bitrate":
+		// e.g. "4012.3kbits/s", or "N/A" before the first packet
+		if kbits, err := strconv.ParseFloat(strings.TrimSuffix(value, "kbits/s"), 64); err == nil {
+			progress.Bitrate = kbits * 1000
+		}
+	case "total_size":
+		progress.TotalSize, _ = strconv.ParseInt(value, 10, 64)
+	case "speed":
+		progress.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+	case "drop_frames":
+		progress.DroppedFrames, _ = strconv.ParseInt(value, 10, 64)
+	case "dup_frames":
+		progress.DuplicatedFrames, _ = strconv.ParseInt(value, 10, 64)
+	case "progress":
+		// Ends each block of statistics
+		progress.Ended = value == "end"
+		progress.UpdatedAt = now
+	}
+	return true
+}
+
+// SetLogBuffer makes the streamer log into buf, so that a channel's log
+// survives the streamer.
+func (s *Streamer) SetLogBuffer(buf *LogBuffer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.logBuffer = buf
+}
+
+// Logs returns up to limit of the most recent lines FFmpeg logged, oldest
+// first. The lines are kept across items and restarts.
+func (s *Streamer) Logs(limit int) []LogLine {
+	s.mux.Lock()
+	logBuffer := s.logBuffer
+	s.mux.Unlock()
+	return logBuffer.Lines(limit)
+}
+
+// Progress returns the latest encoding statistics of the running process.
+func (s *Streamer) Progress() Progress {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.progress
+}
+
+func (s *Streamer) progressCallback(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -249,29 +370,36 @@ func (s *Streamer) progressCallback() {
 		select {
 		case <-ticker.C:
 			s.mux.Lock()
-			position := s.currentPosition
+			progress := s.progress
+			progress.Position = s.currentPosition
 			callback := s.onProgress
 			s.mux.Unlock()
 
 			// Call back to update DB
 			if callback != nil {
-				callback(position)
+				callback(progress)
 			}
-		case <-s.ctx.Done():
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (s *Streamer) monitorProcess() {
-	_ = s.cmd.Wait()
+func (s *Streamer) monitorProcess(cmd *exec.Cmd) {
+	_ = cmd.Wait()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// A process replaced by Reset must not end the next one
+	if s.cmd != cmd {
+		return
+	}
+	s.running = false
+	s.exitCode = cmd.ProcessState.ExitCode()
 
 	// Use sync.Once to ensure cleanup happens only once
 	s.stopOnce.Do(func() {
-		s.mux.Lock()
-		s.running = false
-		s.mux.Unlock()
-
 		// Close the done channel to signal completion
 		select {
 		case <-s.done:
@@ -283,29 +411,31 @@ func (s *Streamer) monitorProcess() {
 }
 
 func (s *Streamer) Reset() {
-	// Cancel the context to stop all goroutines
-	s.cancel()
-
 	s.mux.Lock()
-	defer s.mux.Unlock()
-
-	// Kill the process if it's running
-	if s.running && s.cmd != nil && s.cmd.Process != nil {
-		s.cmd.Process.Signal(syscall.SIGTERM)
+	cmd, running, done, cancel := s.cmd, s.running, s.done, s.cancel
+	s.mux.Unlock()
 
-		// Give it a moment to terminate gracefully
-		time.Sleep(100 * time.Millisecond)
-
-		// Force kill if still running
-		if s.IsRunning() {
-			s.cmd.Process.Kill()
+	// Give a running process a moment to terminate gracefully
+	if running && cmd != nil && cmd.Process != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(100 * time.Millisecond):
 		}
 	}
 
+	// Cancel the context to stop all goroutines; this kills the process if
+	// it is still running
+	cancel()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
 	// Reset state
 	s.running = false
 	s.currentPosition = 0
-	s.logBuffer.Reset()
+	s.progress = Progress{}
+	s.exitCode = 0
 	s.cmd = nil
 	s.pid = 0
 	s.onProgress = nil
@@ -337,17 +467,89 @@ func parseFFmpegTime(timeStr string) (float64, error) {
 	return float64(hours*3600+minutes*60) + secs, nil
 }
 
-func (s *Streamer) SetProgressCallback(callback func(position float64)) {
+func (s *Streamer) SetProgressCallback(callback func(progress Progress)) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 	s.onProgress = callback
 }
 
-func (s *Streamer) buildOverlayFilter(overlays []models.Overlay, outputResolution string) string {
+// streamMaps maps the audio and subtitle streams to carry, given the
+// input's probed streams. Language tags are copied from the input, so the
+// PMT announces each track's language. Without a probe, all audio is carried
+// and no subtitles, rather than risk a silent channel or a failed mux.
+func streamMaps(config StreamConfig, streams []StreamInfo) []string {
+	if streams == nil {
+		return []string{"-map", "0:a?"}
+	}
+
+	var args []string
+	if len(config.AudioLanguages) == 0 {
+		args = append(args, "-map", "0:a?")
+	} else {
+		mapped := make(map[int]bool)
+		for _, language := range config.AudioLanguages {
+			for _, stream := range streams {
+				if stream.CodecType == "audio" && strings.EqualFold(stream.Language, language) && !mapped[stream.Index] {
+					mapped[stream.Index] = true
+					args = append(args, "-map", fmt.Sprintf("0:%d", stream.Index))
+				}
+			}
+		}
+		// Untagged audio is common for file ingest; carry the first track
+		if len(mapped) == 0 {
+			args = append(args, "-map", "0:a:0?")
+		}
+	}
+
+	if config.PassSubtitles {
+		// Only DVB subtitles and teletext can be carried in MPEG-TS as they are
+		subtitles := 0
+		for _, stream := range streams {
+			if stream.CodecName == "dvb_subtitle" || stream.CodecName == "dvb_teletext" {
+				args = append(args, "-map", fmt.Sprintf("0:%d", stream.Index))
+				subtitles++
+			}
+		}
+		if subtitles > 0 {
+			args = append(args, "-c:s", "copy")
+		}
+	}
+	return args
+}
+
+// probeStreams lists the input's streams when the stream maps depend on
+// them. It returns nil if they do not or the probe fails.
+func (s *Streamer) probeStreams(ctx context.Context, config StreamConfig) []StreamInfo {
+	if len(config.AudioLanguages) == 0 && !config.PassSubtitles {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	streams, err := ProbeStreams(ctx, config.InputPath)
+	if err != nil {
+		s.logBuffer.Add(err.Error())
+		return nil
+	}
+	return streams
+}
+
+// subtitleFilter burns an SRT file into the picture. The subtitles filter
+// times cues by the frame timestamps, which start from zero after seeking,
+// so they are shifted by the start offset while it runs.
+func subtitleFilter(subtitleFile string, startOffset time.Duration) string {
+	filter := fmt.Sprintf("subtitles=filename='%s'", subtitleFile)
+	if startOffset <= 0 {
+		return filter
+	}
+	offset := startOffset.Seconds()
+	return fmt.Sprintf("setpts=PTS+%.3f/TB,%s,setpts=PTS-%.3f/TB", offset, filter, offset)
+}
+
+func (s *Streamer) buildOverlayFilter(overlays []models.Overlay, outputResolution string, subtitleFile string, startOffset time.Duration) string {
 	var filters []string
 	currentLabel := "0:v"
 	filterIndex := 0
-	imageCount := 1 // FFmpeg input indices start from 1 for overlays
 
 	// Scale input video
 	scaledLabel := fmt.Sprintf("v%d", filterIndex)
@@ -361,32 +563,69 @@ func (s *Streamer) buildOverlayFilter(overlays []models.Overlay, outputResolutio
 	currentLabel = downloadLabel
 	filterIndex++
 
+	// Burn in subtitles below the overlays
+	if subtitleFile != "" {
+		subtitleLabel := fmt.Sprintf("v%d", filterIndex)
+		filters = append(filters, fmt.Sprintf("[%s]%s[%s]", currentLabel, subtitleFilter(subtitleFile, startOffset), subtitleLabel))
+		currentLabel = subtitleLabel
+		filterIndex++
+	}
+
 	// Apply overlays
+	overlayFilters, currentLabel := buildOverlayChain(overlays, currentLabel, filterIndex)
+	filters = append(filters, overlayFilters...)
+
+	filters = append(filters, fmt.Sprintf("[%s]format=nv12,hwupload_cuda,format=cuda[outv]", currentLabel))
+
+	return strings.Join(filters, ";")
+}
+
+// buildOverlayChain draws the overlays on top of the video labelled
+// currentLabel, in order. Image overlays take their pictures from inputs 1..n.
+// It returns the filters and the label of the resulting video. Text is drawn
+// as it is, without drawtext's %{...} expansion, so that a headline such as
+// "up 5%" cannot fail the filter.
+func buildOverlayChain(overlays []models.Overlay, currentLabel string, filterIndex int) ([]string, string) {
+	var filters []string
+	imageCount := 1 // FFmpeg input indices start from 1 for overlays
+
 	for _, overlay := range overlays {
 		switch overlay.Type {
 		case OverlayTypeText:
 			textLabel := fmt.Sprintf("v%d", filterIndex)
+			// Text backed by a file is re-read every frame so it can be changed while streaming
+			textSource := "text=" + escapeFilterText(overlay.Text)
+			if overlay.TextFile != "" {
+				textSource = fmt.Sprintf("textfile='%s':reload=1", overlay.TextFile)
+			}
 			filters = append(filters, fmt.Sprintf(
-				"[%s]drawtext=fontfile='%s':text='%s':x=%s:y=%s:fontsize=%s:fontcolor=%s:shadowcolor=black:shadowx=2:shadowy=2[%s]",
+				"[%s]drawtext=fontfile='%s':%s:expansion=none:x='%s':y='%s':fontsize='%s':fontcolor=%s:shadowcolor=black:shadowx=2:shadowy=2%s[%s]",
 				currentLabel,
 				overlay.FontFile,
-				overlay.Text,
+				textSource,
 				overlay.PositionX,
 				overlay.PositionY,
 				overlay.FontSize,
 				overlay.FontColor,
+				enableOption(overlay),
 				textLabel,
 			))
 			currentLabel = textLabel
 			filterIndex++
+		case OverlayTypeTicker:
+			tickerLabel := fmt.Sprintf("v%d", filterIndex)
+			filters = append(filters, fmt.Sprintf("[%s]%s[%s]", currentLabel, buildTickerFilter(overlay), tickerLabel))
+			currentLabel = tickerLabel
+			filterIndex++
 		case OverlayTypeImage:
 			overlayLabel := fmt.Sprintf("v%d", filterIndex)
 			filters = append(filters, fmt.Sprintf(
-				"[%s][%d:v]overlay=%s:%s[%s]",
+				"[%s][%d:v]overlay=x='%s':y='%s'%s[%s]",
 				currentLabel,
 				imageCount,
 				overlay.PositionX,
 				overlay.PositionY,
+				enableOption(overlay),
 				overlayLabel,
 			))
 			currentLabel = overlayLabel
@@ -395,9 +634,92 @@ func (s *Streamer) buildOverlayFilter(overlays []models.Overlay, outputResolutio
 		}
 	}
 
-	filters = append(filters, fmt.Sprintf("[%s]format=nv12,hwupload_cuda,format=cuda[outv]", currentLabel))
+	return filters, currentLabel
+}
 
-	return strings.Join(filters, ";")
+// buildTickerFilter draws a full-width band at the overlay's Y position and
+// crawls the text through it from right to left, wrapping around once the
+// whole text has left the screen.
+func buildTickerFilter(overlay models.Overlay) string {
+	speed := overlay.Speed
+	if speed <= 0 {
+		speed = 100
+	}
+	bandHeight := fmt.Sprintf("(%s)*1.6", overlay.FontSize)
+
+	textSource := "text=" + escapeFilterText(overlay.Text)
+	if overlay.TextFile != "" {
+		textSource = fmt.Sprintf("textfile='%s':reload=1", overlay.TextFile)
+	}
+
+	// drawbox names the frame size iw/ih where drawtext uses W/H
+	band := fmt.Sprintf("drawbox=x=0:y='%s':w=iw:h='%s':color=%s:t=fill%s",
+		drawboxExpr(overlay.PositionY), drawboxExpr(bandHeight), overlay.BackgroundColor, enableOption(overlay))
+
+	text := fmt.Sprintf(
+		"drawtext=fontfile='%s':%s:expansion=none:x='w-mod(t*%d,w+tw)':y='(%s)+(%s-th)/2':fontsize='%s':fontcolor=%s%s",
+		overlay.FontFile,
+		textSource,
+		speed,
+		overlay.PositionY,
+		bandHeight,
+		overlay.FontSize,
+		overlay.FontColor,
+		enableOption(overlay),
+	)
+
+	return band + "," + text
+}
+
+// enableOption returns the timeline option limiting when an overlay is drawn.
+func enableOption(overlay models.Overlay) string {
+	if overlay.Enable == "" {
+		return ""
+	}
+	return fmt.Sprintf(":enable='%s'", overlay.Enable)
+}
+
+var (
+	// optionEscaper escapes a value for the filter's option parser, which
+	// splits options on colons
+	optionEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+	// graphEscaper escapes a filter's options for the filter graph parser,
+	// which splits filters on commas, semicolons and link labels
+	graphEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`)
+)
+
+// escapeFilterText escapes text given inline to drawtext so that quotes,
+// colons, commas and backslashes, e.g. in "Children's Hour" or "News: Late",
+// are drawn rather than read as filter syntax. FFmpeg strips the text of
+// surrounding whitespace.
+func escapeFilterText(text string) string {
+	return graphEscaper.Replace(optionEscaper.Replace(strings.TrimSpace(text)))
+}
+
+var frameSizeVars = strings.NewReplacer("main_w", "iw", "main_h", "ih")
+
+// drawboxExpr rewrites a drawtext position expression for use in drawbox.
+func drawboxExpr(expr string) string {
+	expr = frameSizeVars.Replace(expr)
+
+	var b strings.Builder
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		standalone := (i == 0 || !isIdentByte(expr[i-1])) && (i == len(expr)-1 || !isIdentByte(expr[i+1]))
+		switch {
+		case c == 'W' && standalone:
+			b.WriteString("iw")
+		case c == 'H' && standalone:
+			b.WriteString("ih")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
 
 func (s *Streamer) Stop() error {
@@ -415,6 +737,36 @@ func (s *Streamer) Stop() error {
 	return nil
 }
 
+// Terminate asks FFmpeg to exit with SIGTERM, so that it can flush its
+// output, and kills it if it has not exited within timeout.
+func (s *Streamer) Terminate(timeout time.Duration) error {
+	s.mux.Lock()
+	cmd, running, done := s.cmd, s.running, s.done
+	s.mux.Unlock()
+
+	if !running || cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to stop FFmpeg: %w", err)
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to kill FFmpeg: %w", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+	}
+	return fmt.Errorf("FFmpeg %d did not exit within %s and was killed", cmd.Process.Pid, timeout)
+}
+
 func (s *Streamer) IsRunning() bool {
 	s.mux.Lock()
 	defer s.mux.Unlock()
@@ -435,12 +787,30 @@ func (s *Streamer) IsRunning() bool {
 	return true
 }
 
+// Position returns the last stream position reported by FFmpeg, in seconds,
+// including the start offset of the current item.
+func (s *Streamer) Position() float64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.currentPosition
+}
+
 func (s *Streamer) PID() int {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 	return s.pid
 }
 
+// ExitCode returns the exit code of the last FFmpeg process once it has
+// exited; -1 means it was killed by a signal.
+func (s *Streamer) ExitCode() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.exitCode
+}
+
 func (s *Streamer) Done() <-chan struct{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	return s.done
 }
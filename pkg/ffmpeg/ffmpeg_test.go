@@ -0,0 +1,303 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+func TestBuildTickerFilter(t *testing.T) {
+	overlay := models.Overlay{
+		Type:            OverlayTypeTicker,
+		FontFile:        "/fonts/sans.ttf",
+		TextFile:        "/live/overlay_3.txt",
+		PositionY:       "H-60",
+		FontSize:        "32",
+		FontColor:       "white",
+		BackgroundColor: "black@0.6",
+		Speed:           120,
+		Enable:          "between(t,0,30)",
+	}
+
+	want := "drawbox=x=0:y='ih-60':w=iw:h='(32)*1.6':color=black@0.6:t=fill:enable='between(t,0,30)'," +
+		"drawtext=fontfile='/fonts/sans.ttf':textfile='/live/overlay_3.txt':reload=1:expansion=none:" +
+		"x='w-mod(t*120,w+tw)':y='(H-60)+((32)*1.6-th)/2':fontsize='32':fontcolor=white:enable='between(t,0,30)'"
+	if got := buildTickerFilter(overlay); got != want {
+		t.Errorf("buildTickerFilter =\n%s\nwant\n%s", got, want)
+	}
+
+	filters, label := buildOverlayChain([]models.Overlay{overlay}, "v1", 2)
+	if len(filters) != 1 || filters[0] != "[v1]"+want+"[v2]" || label != "v2" {
+		t.Errorf("buildOverlayChain = %q, %q", filters, label)
+	}
+}
+
+func TestOverlayTextIsNotExpanded(t *testing.T) {
+	// drawtext would read the % as the start of a %{...} expansion
+	text := models.Overlay{Type: OverlayTypeText, TextFile: "/live/overlay_1.txt", Text: `Inflation hits 5% \ 100%`}
+	ticker := text
+	ticker.Type = OverlayTypeTicker
+
+	filters, _ := buildOverlayChain([]models.Overlay{text, ticker}, "v0", 1)
+	if len(filters) != 2 {
+		t.Fatalf("buildOverlayChain = %q", filters)
+	}
+	for i, filter := range append(filters, buildTickerFilter(ticker)) {
+		if !strings.Contains(filter, "textfile='/live/overlay_1.txt':reload=1:expansion=none:") {
+			t.Errorf("filter %d draws expanded text: %s", i, filter)
+		}
+	}
+}
+
+// getToken reads one token the way FFmpeg's av_get_token does: up to a
+// terminator, unescaping backslashes and quotes and trimming whitespace.
+func getToken(s, term string) (string, string) {
+	s = strings.TrimLeft(s, " \n\t\r")
+	var out []byte
+	end, i := 0, 0
+	for i < len(s) && !strings.ContainsRune(term, rune(s[i])) {
+		c := s[i]
+		i++
+		switch {
+		case c == '\\' && i < len(s):
+			out = append(out, s[i])
+			i++
+			end = len(out)
+		case c == '\'':
+			for i < len(s) && s[i] != '\'' {
+				out = append(out, s[i])
+				i++
+			}
+			if i < len(s) {
+				i++
+				end = len(out)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	for len(out) > end && strings.ContainsRune(" \n\t\r", rune(out[len(out)-1])) {
+		out = out[:len(out)-1]
+	}
+	return string(out), s[i:]
+}
+
+// drawtextOptions parses the options of the first drawtext filter in a
+// filter graph the way FFmpeg does: once for the graph and once for the
+// filter. It returns the options and the rest of the graph.
+func drawtextOptions(t *testing.T, graph string) (map[string]string, string) {
+	t.Helper()
+	_, after, ok := strings.Cut(graph, "drawtext=")
+	if !ok {
+		t.Fatalf("no drawtext in %s", graph)
+	}
+	args, rest := getToken(after, "[],;")
+	if rest != "" && !strings.ContainsRune("[,;", rune(rest[0])) {
+		t.Fatalf("filter graph continues after drawtext with %q", rest)
+	}
+
+	options := make(map[string]string)
+	for args != "" {
+		key, value, ok := strings.Cut(args, "=")
+		if !ok {
+			t.Fatalf("option without a value in %q", args)
+		}
+		options[key], args = getToken(value, ":")
+		args = strings.TrimPrefix(args, ":")
+	}
+	return options, rest
+}
+
+func TestInlineTextIsEscaped(t *testing.T) {
+	texts := []string{
+		"Children's Hour",
+		"News: Late",
+		`C:\media\news`,
+		"Sport, weather; [live]",
+		"Up 5% at %{pts}",
+	}
+	for _, text := range texts {
+		overlay := models.Overlay{Type: OverlayTypeText, FontFile: "/fonts/sans.ttf", Text: text,
+			PositionX: "10", PositionY: "10", FontSize: "24", FontColor: "white"}
+		filters, _ := buildOverlayChain([]models.Overlay{overlay}, "v0", 1)
+		if options, _ := drawtextOptions(t, filters[0]); options["text"] != text {
+			got := options["text"]
+			t.Errorf("text overlay draws %q, want %q", got, text)
+		}
+
+		overlay.Type = OverlayTypeTicker
+		if options, _ := drawtextOptions(t, buildTickerFilter(overlay)); options["text"] != text {
+			got := options["text"]
+			t.Errorf("ticker draws %q, want %q", got, text)
+		}
+	}
+}
+
+func TestStreamMaps(t *testing.T) {
+	streams := []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "mp2", Language: "eng"},
+		{Index: 2, CodecType: "audio", CodecName: "mp2", Language: "FRA"},
+		{Index: 3, CodecType: "audio", CodecName: "ac3", Language: "eng"},
+		{Index: 4, CodecType: "subtitle", CodecName: "dvb_subtitle", Language: "eng"},
+		{Index: 5, CodecType: "subtitle", CodecName: "dvb_teletext"},
+	}
+	untagged := []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac"},
+		{Index: 2, CodecType: "subtitle", CodecName: "mov_text"},
+	}
+
+	tests := []struct {
+		name    string
+		config  StreamConfig
+		streams []StreamInfo
+		want    []string
+	}{
+		{"all audio", StreamConfig{}, streams, []string{"-map", "0:a?"}},
+		{"unprobed", StreamConfig{AudioLanguages: []string{"eng"}, PassSubtitles: true}, nil, []string{"-map", "0:a?"}},
+		{"languages in order", StreamConfig{AudioLanguages: []string{"fra", "eng"}}, streams,
+			[]string{"-map", "0:2", "-map", "0:1", "-map", "0:3"}},
+		{"no language matches", StreamConfig{AudioLanguages: []string{"deu"}}, streams, []string{"-map", "0:a:0?"}},
+		{"untagged audio", StreamConfig{AudioLanguages: []string{"eng"}}, untagged, []string{"-map", "0:a:0?"}},
+		{"dvb subtitles and teletext", StreamConfig{PassSubtitles: true}, streams,
+			[]string{"-map", "0:a?", "-map", "0:4", "-map", "0:5", "-c:s", "copy"}},
+		{"text subtitles dropped", StreamConfig{PassSubtitles: true}, untagged, []string{"-map", "0:a?"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamMaps(tt.config, tt.streams); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("streamMaps = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	output := `{"streams": [
+		{"index": 0, "codec_name": "h264", "codec_type": "video"},
+		{"index": 1, "codec_name": "aac", "codec_type": "audio", "tags": {"language": "eng"}},
+		{"index": 2, "codec_name": "dvb_teletext", "codec_type": "subtitle", "tags": {"language": "fin"}}
+	]}`
+	streams, err := parseProbe([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac", Language: "eng"},
+		{Index: 2, CodecType: "subtitle", CodecName: "dvb_teletext", Language: "fin"},
+	}
+	if !reflect.DeepEqual(streams, want) {
+		t.Errorf("parseProbe = %+v, want %+v", streams, want)
+	}
+
+	if _, err := parseProbe([]byte("not json")); err == nil {
+		t.Error("parseProbe accepted invalid output")
+	}
+}
+
+func TestSubtitleFilter(t *testing.T) {
+	tests := []struct {
+		offset time.Duration
+		want   string
+	}{
+		{0, "subtitles=filename='/media/a.srt'"},
+		{90500 * time.Millisecond, "setpts=PTS+90.500/TB,subtitles=filename='/media/a.srt',setpts=PTS-90.500/TB"},
+	}
+	for _, tt := range tests {
+		if got := subtitleFilter("/media/a.srt", tt.offset); got != tt.want {
+			t.Errorf("subtitleFilter(%v) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestBuildOverlayFilterBurnsSubtitlesBelowOverlays(t *testing.T) {
+	overlays := []models.Overlay{{Type: OverlayTypeImage, PositionX: "10", PositionY: "10"}}
+	got := (&Streamer{}).buildOverlayFilter(overlays, "1920:1080", "/media/a.srt", 0)
+
+	want := strings.Join([]string{
+		"[0:v]scale_cuda=1920:1080[v0]",
+		"[v0]hwdownload,format=nv12[v1]",
+		"[v1]subtitles=filename='/media/a.srt'[v2]",
+		"[v2][1:v]overlay=x='10':y='10'[v3]",
+		"[v3]format=nv12,hwupload_cuda,format=cuda[outv]",
+	}, ";")
+	if got != want {
+		t.Errorf("buildOverlayFilter =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseProgressLine(t *testing.T) {
+	now := time.Date(2025, 3, 14, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		line     string
+		want     Progress
+		progress bool
+	}{
+		{"out_time=00:01:02.500000", Progress{Position: 72.5}, true},
+		{"out_time=N/A", Progress{}, true},
+		{"out_time_us=62500000", Progress{}, true},
+		{"frame=1500", Progress{Frame: 1500}, true},
+		{"fps=25.03", Progress{FPS: 25.03}, true},
+		{"bitrate=4012.5kbits/s", Progress{Bitrate: 4012500}, true},
+		{"bitrate=N/A", Progress{}, true},
+		{"total_size=1048576", Progress{TotalSize: 1048576}, true},
+		{"speed=1.01x", Progress{Speed: 1.01}, true},
+		{"speed= 0.98x", Progress{Speed: 0.98}, true},
+		{"drop_frames=3", Progress{DroppedFrames: 3}, true},
+		{"dup_frames=2", Progress{DuplicatedFrames: 2}, true},
+		{"stream_0_0_q=28.0", Progress{}, true},
+		{"progress=continue", Progress{UpdatedAt: now}, true},
+		{"progress=end", Progress{Ended: true, UpdatedAt: now}, true},
+		{"[mpegts @ 0x55d] PES packet size mismatch", Progress{}, false},
+		{"Stream mapping: size=1", Progress{}, false},
+		{"encoder=Lavf60.3.100", Progress{}, false},
+		{"", Progress{}, false},
+	}
+	for _, tt := range tests {
+		var progress Progress
+		ok := parseProgressLine(&progress, tt.line, 10*time.Second, now)
+		if ok != tt.progress || progress != tt.want {
+			t.Errorf("parseProgressLine(%q) = %+v, %v; want %+v, %v", tt.line, progress, ok, tt.want, tt.progress)
+		}
+	}
+}
+
+func TestPreviewArgsEscapeText(t *testing.T) {
+	texts := []string{"Children's Hour", "News: Late", "Sport, weather; [live]"}
+	overlays := []models.Overlay{
+		{Type: OverlayTypeText, FontFile: "/fonts/sans.ttf", Text: texts[0],
+			PositionX: "10", PositionY: "10", FontSize: "24", FontColor: "white"},
+		{Type: OverlayTypeTicker, FontFile: "/fonts/sans.ttf", Text: texts[1],
+			PositionY: "h-40", FontSize: "24", FontColor: "white"},
+		{Type: OverlayTypeText, FontFile: "/fonts/sans.ttf", Text: texts[2],
+			PositionX: "10", PositionY: "50", FontSize: "24", FontColor: "white"},
+	}
+	args := previewArgs(PreviewConfig{InputPath: "/media/news.mp4", OutputResolution: "1280x720", Overlays: overlays})
+
+	graph := ""
+	for i, arg := range args {
+		if arg == "-filter_complex" && i+1 < len(args) {
+			graph = args[i+1]
+		}
+	}
+	if graph == "" {
+		t.Fatalf("previewArgs() has no -filter_complex: %v", args)
+	}
+
+	rest := graph
+	for _, text := range texts {
+		var options map[string]string
+		options, rest = drawtextOptions(t, rest)
+		if options["text"] != text {
+			t.Errorf("preview draws %q, want %q", options["text"], text)
+		}
+	}
+	if !strings.HasSuffix(rest, "format=rgb24[outv]") {
+		t.Errorf("filter graph ends with %q, want the rgb24 output", rest)
+	}
+}
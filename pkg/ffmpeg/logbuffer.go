@@ -0,0 +1,60 @@
+package ffmpeg
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultLogLines is how many FFmpeg log lines a streamer keeps.
+const DefaultLogLines = 500
+
+// LogLine is one line FFmpeg wrote to stderr.
+type LogLine struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+// LogBuffer keeps the most recent FFmpeg log lines, dropping the oldest once
+// it is full.
+type LogBuffer struct {
+	mux   sync.Mutex
+	lines []LogLine
+	next  int
+	full  bool
+}
+
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = DefaultLogLines
+	}
+	return &LogBuffer{lines: make([]LogLine, size)}
+}
+
+func (b *LogBuffer) Add(line string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.lines[b.next] = LogLine{Time: time.Now(), Line: line}
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Lines returns up to limit of the most recent lines, oldest first. A limit
+// of zero or less returns everything kept.
+func (b *LogBuffer) Lines(limit int) []LogLine {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	var lines []LogLine
+	if b.full {
+		lines = append(lines, b.lines[b.next:]...)
+	}
+	lines = append(lines, b.lines[:b.next]...)
+
+	if limit > 0 && len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines
+}
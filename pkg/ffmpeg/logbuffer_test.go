@@ -0,0 +1,52 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLogBuffer(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		added int
+		limit int
+		want  []string
+	}{
+		{"empty", 3, 0, 0, nil},
+		{"partly filled", 3, 2, 0, []string{"line 0", "line 1"}},
+		{"exactly full", 3, 3, 0, []string{"line 0", "line 1", "line 2"}},
+		{"wrapped", 3, 5, 0, []string{"line 2", "line 3", "line 4"}},
+		{"limited", 3, 5, 2, []string{"line 3", "line 4"}},
+		{"limit above kept", 3, 2, 10, []string{"line 0", "line 1"}},
+		{"negative limit", 3, 4, -1, []string{"line 1", "line 2", "line 3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := NewLogBuffer(tt.size)
+			for i := 0; i < tt.added; i++ {
+				buf.Add(fmt.Sprintf("line %d", i))
+			}
+
+			var got []string
+			for _, line := range buf.Lines(tt.limit) {
+				got = append(got, line.Line)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%d) = %q, want %q", tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLogBufferDefaultSize(t *testing.T) {
+	buf := NewLogBuffer(0)
+	for i := 0; i < DefaultLogLines+1; i++ {
+		buf.Add(fmt.Sprintf("line %d", i))
+	}
+	lines := buf.Lines(0)
+	if len(lines) != DefaultLogLines || lines[0].Line != "line 1" {
+		t.Errorf("buffer of default size kept %d lines from %q, want %d from line 1", len(lines), lines[0].Line, DefaultLogLines)
+	}
+}
@@ -0,0 +1,94 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Loudness is the measured loudness of a file's audio.
+type Loudness struct {
+	Integrated float64 // LUFS
+	TruePeak   float64 // dBTP
+}
+
+// LoudnessTarget is the level a channel normalizes its audio to.
+type LoudnessTarget struct {
+	Integrated float64 // LUFS, e.g. -23 for EBU R128 or -24 for ATSC A/85
+	TruePeak   float64 // dBTP
+}
+
+// MeasureLoudness runs the first, measuring pass of loudnorm over the whole
+// file. It decodes the audio as fast as it can, so it takes a while on long
+// files.
+func MeasureLoudness(ctx context.Context, path string) (Loudness, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", path,
+		"-vn", "-af", "loudnorm=print_format=json",
+		"-f", "null", "-",
+	)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return Loudness{}, fmt.Errorf("failed to measure loudness: %w", err)
+	}
+
+	return parseLoudness(stderr.String())
+}
+
+// parseLoudness reads loudnorm's measurements from FFmpeg's output, where
+// they are printed as the last JSON object.
+func parseLoudness(output string) (Loudness, error) {
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start == -1 || end < start {
+		return Loudness{}, fmt.Errorf("failed to measure loudness: no measurement in FFmpeg output")
+	}
+
+	var measured struct {
+		InputI  string `json:"input_i"`
+		InputTP string `json:"input_tp"`
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &measured); err != nil {
+		return Loudness{}, fmt.Errorf("failed to parse loudness measurement: %w", err)
+	}
+
+	integrated, err := strconv.ParseFloat(measured.InputI, 64)
+	if err != nil || math.IsInf(integrated, 0) {
+		// Silent files measure as -inf
+		return Loudness{}, fmt.Errorf("failed to parse loudness measurement: input_i %q", measured.InputI)
+	}
+	truePeak, err := strconv.ParseFloat(measured.InputTP, 64)
+	if err != nil || math.IsInf(truePeak, 0) {
+		return Loudness{}, fmt.Errorf("failed to parse loudness measurement: input_tp %q", measured.InputTP)
+	}
+
+	return Loudness{Integrated: integrated, TruePeak: truePeak}, nil
+}
+
+// broadcastSampleRate is the audio sample rate channels are encoded at.
+const broadcastSampleRate = 48000
+
+// LoudnessFilter returns the audio filter that brings audio to the target.
+// With a measurement it is a fixed gain, limited so the true peak stays
+// under the target's; without one loudnorm adjusts the level as it plays.
+// loudnorm upsamples to 192 kHz in that mode, so its output is resampled
+// back to the broadcast rate.
+func LoudnessFilter(target LoudnessTarget, measured *Loudness) string {
+	if measured == nil {
+		return fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=11,aresample=%d", target.Integrated, target.TruePeak, broadcastSampleRate)
+	}
+
+	gain := target.Integrated - measured.Integrated
+	if headroom := target.TruePeak - measured.TruePeak; gain > headroom {
+		gain = headroom
+	}
+	return fmt.Sprintf("volume=%.2fdB", gain)
+}
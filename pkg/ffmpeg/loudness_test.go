@@ -0,0 +1,58 @@
+package ffmpeg
+
+import "testing"
+
+func TestLoudnessFilter(t *testing.T) {
+	ebu := LoudnessTarget{Integrated: -23, TruePeak: -1}
+	tests := []struct {
+		name     string
+		measured *Loudness
+		want     string
+	}{
+		{"unmeasured", nil, "loudnorm=I=-23.0:TP=-1.0:LRA=11,aresample=48000"},
+		{"too loud", &Loudness{Integrated: -14, TruePeak: -0.5}, "volume=-9.00dB"},
+		{"too quiet", &Loudness{Integrated: -30, TruePeak: -12}, "volume=7.00dB"},
+		{"gain capped by true peak", &Loudness{Integrated: -30, TruePeak: -4}, "volume=3.00dB"},
+		{"on target", &Loudness{Integrated: -23, TruePeak: -3}, "volume=0.00dB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LoudnessFilter(ebu, tt.measured); got != tt.want {
+				t.Errorf("LoudnessFilter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLoudness(t *testing.T) {
+	measurement := `[Parsed_loudnorm_0 @ 0x55d5c8a3c240]
+{
+	"input_i" : "-18.52",
+	"input_tp" : "-2.10",
+	"input_lra" : "6.40",
+	"input_thresh" : "-28.80",
+	"output_i" : "-23.01",
+	"target_offset" : "0.01"
+}
+`
+	tests := []struct {
+		name    string
+		output  string
+		want    Loudness
+		wantErr bool
+	}{
+		{"measurement", "Input #0, mov,mp4 from 'a.mp4': {metadata}\n" + measurement, Loudness{Integrated: -18.52, TruePeak: -2.1}, false},
+		{"no measurement", "Error opening input file", Loudness{}, true},
+		{"silent", `{"input_i" : "-inf", "input_tp" : "-inf"}`, Loudness{}, true},
+		{"truncated", `{"input_i" : "-18.52"`, Loudness{}, true},
+		{"not a number", `{"input_i" : "loud", "input_tp" : "-2.10"}`, Loudness{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoudness(tt.output)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseLoudness = %+v, %v; want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/euacreations/tvheadend/internal/models"
+)
+
+// PreviewConfig describes a still frame to render with overlays composited.
+type PreviewConfig struct {
+	InputPath        string
+	Position         time.Duration
+	OutputResolution string
+	Overlays         []models.Overlay
+}
+
+// RenderPreview renders a single PNG frame of the input at the given position
+// with the overlays drawn on top. It runs on the CPU so it can be used while
+// the GPU is busy encoding channels.
+func RenderPreview(ctx context.Context, config PreviewConfig) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", previewArgs(config)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to render preview: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("failed to render preview: no frame at %.2fs", config.Position.Seconds())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// previewArgs builds the FFmpeg arguments that draw the overlays on one
+// frame of the input and write it to stdout as a PNG.
+// previewArgs builds the FFmpeg arguments that render one preview frame.
+func previewArgs(config PreviewConfig) []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+
+	if config.Position > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.2f", config.Position.Seconds()))
+	}
+	args = append(args, "-i", config.InputPath)
+
+	for _, overlay := range config.Overlays {
+		if overlay.Type == OverlayTypeImage {
+			args = append(args, "-i", overlay.FilePath)
+		}
+	}
+
+	filters := []string{fmt.Sprintf("[0:v]scale=size=%s[v0]", config.OutputResolution)}
+	overlayFilters, currentLabel := buildOverlayChain(config.Overlays, "v0", 1)
+	filters = append(filters, overlayFilters...)
+	filters = append(filters, fmt.Sprintf("[%s]format=rgb24[outv]", currentLabel))
+
+	return append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "[outv]",
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-c:v", "png",
+		"pipe:1",
+	)
+}
@@ -0,0 +1,67 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// probeTimeout bounds how long an item's start waits for ffprobe.
+const probeTimeout = 5 * time.Second
+
+// StreamInfo describes one stream of an input.
+type StreamInfo struct {
+	Index     int    // Absolute stream index, as used by -map 0:<index>
+	CodecType string // video, audio, subtitle or data
+	CodecName string // e.g. aac, dvb_subtitle, dvb_teletext, mov_text
+	Language  string // ISO 639-2 tag, empty when untagged
+}
+
+// ProbeStreams lists the streams of an input with ffprobe.
+func ProbeStreams(ctx context.Context, input string) ([]StreamInfo, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "stream=index,codec_type,codec_name:stream_tags=language",
+		"-of", "json",
+		input,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to probe %s: %w: %s", input, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parseProbe(stdout.Bytes())
+}
+
+// parseProbe reads the streams from ffprobe's JSON output.
+func parseProbe(output []byte) ([]StreamInfo, error) {
+	var probed struct {
+		Streams []struct {
+			Index     int    `json:"index"`
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Tags      struct {
+				Language string `json:"language"`
+			} `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probed); err != nil {
+		return nil, fmt.Errorf("failed to parse probe output: %w", err)
+	}
+
+	streams := make([]StreamInfo, 0, len(probed.Streams))
+	for _, stream := range probed.Streams {
+		streams = append(streams, StreamInfo{
+			Index:     stream.Index,
+			CodecType: stream.CodecType,
+			CodecName: stream.CodecName,
+			Language:  stream.Tags.Language,
+		})
+	}
+	return streams, nil
+}
@@ -0,0 +1,131 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Process is an FFmpeg process found in /proc rather than started by this
+// server, e.g. one left streaming by an earlier run that was killed.
+type Process struct {
+	PID  int
+	Args []string
+}
+
+const procRoot = "/proc"
+
+// FindProcess returns the process with the given PID if it is FFmpeg
+// streaming to outputURL, which tells it apart from an unrelated program
+// that has been given a recycled PID.
+func FindProcess(pid int, outputURL string) (*Process, error) {
+	process, err := readProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	if !process.StreamsTo(outputURL) {
+		return nil, fmt.Errorf("process %d is not FFmpeg streaming to %s", pid, outputURL)
+	}
+	return process, nil
+}
+
+// FindProcesses returns every FFmpeg process streaming to outputURL.
+func FindProcesses(outputURL string) ([]*Process, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	var processes []*Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		process, err := readProcess(pid)
+		if err != nil {
+			continue // Exited meanwhile, or not ours to read
+		}
+		if process.StreamsTo(outputURL) {
+			processes = append(processes, process)
+		}
+	}
+	return processes, nil
+}
+
+// readProcess reads the command line of a live process.
+func readProcess(pid int) (*Process, error) {
+	if !alive(pid) {
+		return nil, fmt.Errorf("process %d is not running", pid)
+	}
+	cmdline, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command line of process %d: %w", pid, err)
+	}
+
+	var args []string
+	for _, arg := range bytes.Split(bytes.TrimSuffix(cmdline, []byte{0}), []byte{0}) {
+		args = append(args, string(arg))
+	}
+	return &Process{PID: pid, Args: args}, nil
+}
+
+// StreamsTo reports whether the process is FFmpeg, run as Streamer runs it,
+// writing MPEG-TS to outputURL.
+func (p *Process) StreamsTo(outputURL string) bool {
+	if len(p.Args) == 0 || filepath.Base(p.Args[0]) != "ffmpeg" || outputURL == "" {
+		return false
+	}
+	for i := 2; i < len(p.Args); i++ {
+		if p.Args[i] == outputURL && p.Args[i-1] == "mpegts" && p.Args[i-2] == "-f" {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminate asks the process to exit with SIGTERM and kills it if it has not
+// exited within timeout. It is not a child of this server, so its exit is
+// noticed by polling.
+func (p *Process) Terminate(timeout time.Duration) error {
+	if err := syscall.Kill(p.PID, syscall.SIGTERM); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		return fmt.Errorf("failed to stop FFmpeg %d: %w", p.PID, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !alive(p.PID) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := syscall.Kill(p.PID, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to kill FFmpeg %d: %w", p.PID, err)
+	}
+	return fmt.Errorf("FFmpeg %d did not exit within %s and was killed", p.PID, timeout)
+}
+
+// alive reports whether a process exists and has not exited. An exited
+// process stays in /proc as a zombie until its parent reaps it.
+func alive(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is in parentheses and may
+	// itself contain them
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 || end+2 >= len(stat) {
+		return false
+	}
+	return stat[end+2] != 'Z' && stat[end+2] != 'X'
+}
@@ -0,0 +1,122 @@
+package ffmpeg
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const testOutput = "udp://239.0.0.1:1234?pkt_size=1316"
+
+// TestHelperProcess stands in for an FFmpeg process left behind by an
+// earlier run. It is not a real test.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("FFMPEG_HELPER_PROCESS") == "" {
+		return
+	}
+	if os.Getenv("FFMPEG_HELPER_PROCESS") == "ignore-term" {
+		signal.Ignore(syscall.SIGTERM)
+	} else {
+		terminated := make(chan os.Signal, 1)
+		signal.Notify(terminated, syscall.SIGTERM)
+		go func() {
+			<-terminated
+			os.Exit(0)
+		}()
+	}
+	os.Stdout.WriteString("ready\n")
+	time.Sleep(time.Minute)
+	os.Exit(1)
+}
+
+// startOrphan runs the helper process with FFmpeg's name and arguments.
+func startOrphan(t *testing.T, mode string, args ...string) *exec.Cmd {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Args = append([]string{"ffmpeg", "-test.run=^TestHelperProcess$", "--"}, args...)
+	cmd.Env = append(os.Environ(), "FFMPEG_HELPER_PROCESS="+mode)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reap it when it exits, so that it does not linger as a zombie
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		<-exited
+	})
+
+	ready := make([]byte, 6)
+	if _, err := stdout.Read(ready); err != nil {
+		t.Fatalf("helper process did not start: %v", err)
+	}
+	return cmd
+}
+
+func TestFindProcess(t *testing.T) {
+	ours := startOrphan(t, "exit", "-i", "in.ts", "-f", "mpegts", testOutput, "-progress", "pipe:2")
+	other := startOrphan(t, "exit", "-i", "in.ts", "-f", "mpegts", "udp://239.0.0.2:1234")
+
+	process, err := FindProcess(ours.Process.Pid, testOutput)
+	if err != nil {
+		t.Fatalf("FindProcess: %v", err)
+	}
+	if process.Args[0] != "ffmpeg" {
+		t.Errorf("args = %q, want FFmpeg's", process.Args)
+	}
+	if _, err := FindProcess(other.Process.Pid, testOutput); err == nil {
+		t.Error("FindProcess matched FFmpeg streaming elsewhere")
+	}
+	if _, err := FindProcess(os.Getpid(), testOutput); err == nil {
+		t.Error("FindProcess matched a process that is not FFmpeg")
+	}
+
+	processes, err := FindProcesses(testOutput)
+	if err != nil {
+		t.Fatalf("FindProcesses: %v", err)
+	}
+	if len(processes) != 1 || processes[0].PID != ours.Process.Pid {
+		t.Errorf("FindProcesses found %v, want only process %d", processes, ours.Process.Pid)
+	}
+}
+
+func TestProcessTerminate(t *testing.T) {
+	for _, test := range []struct {
+		mode       string
+		timeout    time.Duration
+		wantKilled bool
+	}{
+		{"exit", 5 * time.Second, false},
+		{"ignore-term", 200 * time.Millisecond, true},
+	} {
+		cmd := startOrphan(t, test.mode, "-f", "mpegts", testOutput)
+		process, err := FindProcess(cmd.Process.Pid, testOutput)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = process.Terminate(test.timeout)
+		if killed := err != nil; killed != test.wantKilled {
+			t.Errorf("%s: Terminate returned %v, want killed %v", test.mode, err, test.wantKilled)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for alive(cmd.Process.Pid) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if alive(cmd.Process.Pid) {
+			t.Errorf("%s: process is still running", test.mode)
+		}
+	}
+}
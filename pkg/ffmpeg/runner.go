@@ -0,0 +1,31 @@
+package ffmpeg
+
+import (
+	"context"
+	"time"
+)
+
+// Runner plays a channel's items one at a time. Streamer runs them with
+// FFmpeg; Simulator pretends to, for tests.
+type Runner interface {
+	Start(ctx context.Context, config StreamConfig) error
+	Stop() error
+	Terminate(timeout time.Duration) error
+	Reset()
+	Done() <-chan struct{}
+	IsRunning() bool
+
+	Progress() Progress
+	Position() float64
+	PID() int
+	ExitCode() int
+	SetProgressCallback(callback func(progress Progress))
+
+	Logs(limit int) []LogLine
+	SetLogBuffer(buf *LogBuffer)
+}
+
+var (
+	_ Runner = (*Streamer)(nil)
+	_ Runner = (*Simulator)(nil)
+)
@@ -0,0 +1,336 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/euacreations/tvheadend/pkg/clock"
+)
+
+// Simulator pretends to be FFmpeg so that playout can be tested without a
+// GPU. It plays items against a clock, normally a virtual one, writing
+// -progress output once a second as FFmpeg does and ending each item when
+// its input runs out.
+type Simulator struct {
+	clock   clock.Clock
+	mux     sync.Mutex
+	started *sync.Cond
+
+	durations map[string]time.Duration
+	speed     float64
+	starts    []StreamConfig
+	nextPID   int
+
+	generation int // Starts and resets, so timers of an old item do nothing
+	running    bool
+	config     StreamConfig
+	length     time.Duration // Output the item will produce; 0 is endless
+	encoded    time.Duration // Output produced so far
+	lastUpdate time.Time
+	progress   Progress
+	pid        int
+	exitCode   int
+	done       chan struct{}
+	onProgress func(progress Progress)
+	logBuffer  *LogBuffer
+	tick       clock.Timer
+	end        clock.Timer
+}
+
+// NewSimulator returns a simulator that runs on clk at real-time speed.
+func NewSimulator(clk clock.Clock) *Simulator {
+	s := &Simulator{
+		clock:     clk,
+		durations: make(map[string]time.Duration),
+		speed:     1,
+		nextPID:   10000,
+		done:      make(chan struct{}),
+		logBuffer: NewLogBuffer(DefaultLogLines),
+	}
+	s.started = sync.NewCond(&s.mux)
+	return s
+}
+
+// SetInputDuration sets how long an input lasts. Inputs without a duration
+// last as long as the item's configured duration, or forever without one.
+func (s *Simulator) SetInputDuration(inputPath string, d time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.durations[inputPath] = d
+}
+
+// SetSpeed sets how fast the simulated encoder runs relative to real time.
+// Below 1 it falls behind; 0 stalls it.
+func (s *Simulator) SetSpeed(speed float64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.update()
+	s.speed = speed
+	if s.running {
+		s.scheduleEnd()
+	}
+}
+
+// Crash makes the running process exit with the given code.
+func (s *Simulator) Crash(exitCode int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.finish(s.generation, exitCode)
+}
+
+// Starts returns the configuration of every item started so far.
+func (s *Simulator) Starts() []StreamConfig {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]StreamConfig(nil), s.starts...)
+}
+
+// WaitStarts waits until n items have been started and returns their
+// configurations.
+func (s *Simulator) WaitStarts(n int) []StreamConfig {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for len(s.starts) < n {
+		s.started.Wait()
+	}
+	return append([]StreamConfig(nil), s.starts...)
+}
+
+func (s *Simulator) Start(ctx context.Context, config StreamConfig) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.running {
+		return fmt.Errorf("stream is already running")
+	}
+
+	s.generation++
+	s.starts = append(s.starts, config)
+	s.running = true
+	s.config = config
+	s.encoded = 0
+	s.lastUpdate = s.clock.Now()
+	s.progress = Progress{Position: config.StartOffset.Seconds()}
+	s.exitCode = 0
+	s.pid = s.nextPID
+	s.nextPID++
+
+	s.length = 0
+	if input, ok := s.durations[config.InputPath]; ok && !config.Loop {
+		s.length = input - config.StartOffset
+		if s.length <= 0 {
+			s.length = time.Millisecond // Seeking past the end
+		}
+	}
+	if config.Duration > 0 && (s.length == 0 || config.Duration < s.length) {
+		s.length = config.Duration
+	}
+
+	s.logBuffer.Add(fmt.Sprintf("Input #0, mpegts, from '%s':", config.InputPath))
+	s.logBuffer.Add(fmt.Sprintf("Output #0, mpegts, to '%s':", config.OutputURL))
+
+	generation := s.generation
+	s.tick = s.clock.AfterFunc(time.Second, func() { s.writeProgress(generation) })
+	s.scheduleEnd()
+	s.started.Broadcast()
+	return nil
+}
+
+// update accounts for the output produced since the last update. The caller
+// must hold mux.
+func (s *Simulator) update() {
+	now := s.clock.Now()
+	if s.running {
+		s.encoded += time.Duration(float64(now.Sub(s.lastUpdate)) * s.speed)
+		if s.length > 0 && s.encoded > s.length {
+			s.encoded = s.length
+		}
+	}
+	s.lastUpdate = now
+}
+
+// scheduleEnd sets the timer for the end of the input at the current speed.
+// The caller must hold mux.
+func (s *Simulator) scheduleEnd() {
+	if s.end != nil {
+		s.end.Stop()
+		s.end = nil
+	}
+	if s.length == 0 || s.speed <= 0 {
+		return
+	}
+
+	remaining := time.Duration(float64(s.length-s.encoded) / s.speed)
+	generation := s.generation
+	s.end = s.clock.AfterFunc(remaining, func() {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		s.finish(generation, 0)
+	})
+}
+
+// writeProgress writes a block of -progress output, as FFmpeg does every
+// second, and passes the result to the progress callback.
+func (s *Simulator) writeProgress(generation int) {
+	s.mux.Lock()
+	if generation != s.generation || !s.running {
+		s.mux.Unlock()
+		return
+	}
+	s.emit("continue")
+	progress := s.progress
+	callback := s.onProgress
+	s.tick = s.clock.AfterFunc(time.Second, func() { s.writeProgress(generation) })
+	s.mux.Unlock()
+
+	if callback != nil {
+		callback(progress)
+	}
+}
+
+// emit writes a block of -progress output through the same parser FFmpeg's
+// output goes through. The caller must hold mux.
+func (s *Simulator) emit(state string) {
+	s.update()
+
+	out := s.encoded
+	frames := int64(out.Seconds() * 30)
+	bitrate := 4000.0 // kbit/s
+	lines := []string{
+		fmt.Sprintf("frame=%d", frames),
+		"fps=30.00",
+		"stream_0_0_q=-1.0",
+		fmt.Sprintf("bitrate=%.1fkbits/s", bitrate),
+		fmt.Sprintf("total_size=%d", int64(out.Seconds()*bitrate*1000/8)),
+		fmt.Sprintf("out_time_us=%d", out.Microseconds()),
+		fmt.Sprintf("out_time_ms=%d", out.Microseconds()),
+		fmt.Sprintf("out_time=%02d:%02d:%09.6f", int(out.Hours()), int(out.Minutes())%60, out.Seconds()-float64(int(out.Minutes())*60)),
+		"dup_frames=0",
+		"drop_frames=0",
+		fmt.Sprintf("speed=%.3gx", s.speed),
+		"progress=" + state,
+	}
+	for _, line := range lines {
+		parseProgressLine(&s.progress, line, s.config.StartOffset, s.clock.Now())
+	}
+}
+
+// finish ends the item of the given generation with an exit code. The caller
+// must hold mux.
+func (s *Simulator) finish(generation int, exitCode int) {
+	if generation != s.generation || !s.running {
+		return
+	}
+
+	s.emit("end")
+	s.running = false
+	s.exitCode = exitCode
+	s.stopTimers()
+	s.logBuffer.Add(fmt.Sprintf("%s: exiting with code %d", filepath.Base(s.config.InputPath), exitCode))
+	close(s.done)
+}
+
+// stopTimers cancels the progress and end timers. The caller must hold mux.
+func (s *Simulator) stopTimers() {
+	if s.tick != nil {
+		s.tick.Stop()
+		s.tick = nil
+	}
+	if s.end != nil {
+		s.end.Stop()
+		s.end = nil
+	}
+}
+
+// Stop ends the running item the way SIGTERM ends FFmpeg.
+func (s *Simulator) Stop() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.finish(s.generation, 255)
+	return nil
+}
+
+// Terminate stops the running item. The simulated process always exits on
+// SIGTERM straight away.
+func (s *Simulator) Terminate(timeout time.Duration) error {
+	return s.Stop()
+}
+
+func (s *Simulator) Reset() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.stopTimers()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	s.generation++
+	s.running = false
+	s.progress = Progress{}
+	s.encoded = 0
+	s.exitCode = 0
+	s.pid = 0
+	s.onProgress = nil
+	s.done = make(chan struct{})
+}
+
+func (s *Simulator) Done() <-chan struct{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.done
+}
+
+func (s *Simulator) IsRunning() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.running
+}
+
+func (s *Simulator) Progress() Progress {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.progress
+}
+
+func (s *Simulator) Position() float64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.progress.Position
+}
+
+func (s *Simulator) PID() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.pid
+}
+
+func (s *Simulator) ExitCode() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.exitCode
+}
+
+func (s *Simulator) SetProgressCallback(callback func(progress Progress)) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.onProgress = callback
+}
+
+func (s *Simulator) Logs(limit int) []LogLine {
+	s.mux.Lock()
+	logBuffer := s.logBuffer
+	s.mux.Unlock()
+	return logBuffer.Lines(limit)
+}
+
+func (s *Simulator) SetLogBuffer(buf *LogBuffer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.logBuffer = buf
+}
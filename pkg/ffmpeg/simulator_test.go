@@ -0,0 +1,84 @@
+package ffmpeg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/euacreations/tvheadend/pkg/clock"
+)
+
+func TestSimulatorProgress(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 3, 14, 6, 0, 0, 0, time.UTC))
+	sim := NewSimulator(clk)
+	sim.SetInputDuration("/media/a.ts", 60*time.Second)
+
+	var reported []Progress
+	sim.SetProgressCallback(func(progress Progress) { reported = append(reported, progress) })
+
+	config := StreamConfig{InputPath: "/media/a.ts", StartOffset: 50 * time.Second, Duration: 60 * time.Second}
+	if err := sim.Start(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(3 * time.Second)
+	progress := sim.Progress()
+	if progress.Position != 53 || progress.Frame != 90 || progress.Speed != 1 || progress.Bitrate != 4000000 {
+		t.Errorf("progress after 3s = %+v", progress)
+	}
+	if len(reported) != 3 {
+		t.Errorf("progress reported %d times, want 3", len(reported))
+	}
+
+	// Half speed falls behind real time
+	sim.SetSpeed(0.5)
+	clk.Advance(4 * time.Second)
+	if position := sim.Position(); position != 55 {
+		t.Errorf("position at half speed = %v, want 55", position)
+	}
+
+	// The remaining 5 seconds of input take 10 at half speed
+	clk.Advance(10 * time.Second)
+	select {
+	case <-sim.Done():
+	default:
+		t.Fatal("simulator still running at the end of its input")
+	}
+	if sim.Position() != 60 || !sim.Progress().Ended || sim.ExitCode() != 0 || sim.IsRunning() {
+		t.Errorf("after the end: position %v progress %+v exit code %d", sim.Position(), sim.Progress(), sim.ExitCode())
+	}
+	if clk.Pending() != 0 {
+		t.Errorf("%d timers left after the end", clk.Pending())
+	}
+}
+
+func TestSimulatorStopAndReset(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 3, 14, 6, 0, 0, 0, time.UTC))
+	sim := NewSimulator(clk)
+
+	if err := sim.Start(context.Background(), StreamConfig{InputPath: "udp://239.0.0.2:1234"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Start(context.Background(), StreamConfig{}); err == nil {
+		t.Error("second Start succeeded while running")
+	}
+
+	sim.Stop()
+	<-sim.Done()
+	if sim.ExitCode() != 255 {
+		t.Errorf("exit code after Stop = %d, want 255", sim.ExitCode())
+	}
+
+	sim.Reset()
+	select {
+	case <-sim.Done():
+		t.Error("Done is closed after Reset")
+	default:
+	}
+	if err := sim.Start(context.Background(), StreamConfig{InputPath: "udp://239.0.0.2:1234"}); err != nil {
+		t.Fatalf("Start after Reset: %v", err)
+	}
+	if len(sim.Starts()) != 2 || sim.PID() == 0 {
+		t.Errorf("starts = %d, pid = %d", len(sim.Starts()), sim.PID())
+	}
+}
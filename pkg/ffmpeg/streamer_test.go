@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg puts a script named ffmpeg first on PATH that reports
+// progress until it is stopped.
+func fakeFFmpeg(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\nwhile true; do\n\techo 'out_time=00:00:01.500000' >&2\n\techo 'progress=continue' >&2\n\tsleep 0.01\ndone\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// TestStreamerResetWhileRunning replaces the process on air while other
+// goroutines read the streamer's state, for the race detector.
+func TestStreamerResetWhileRunning(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell to stand in for FFmpeg")
+	}
+	fakeFFmpeg(t)
+
+	s := New()
+	defer s.Reset()
+	config := StreamConfig{InputPath: "in.ts", InputType: "media", OutputURL: "udp://127.0.0.1:1", StartOffset: 10 * time.Second}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-s.Done():
+			default:
+			}
+			_ = s.Position()
+			_ = s.Progress()
+			_ = s.PID()
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		if err := s.Start(context.Background(), config); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for s.Position() != 11.5 {
+			if time.Now().After(deadline) {
+				t.Fatalf("position = %.1f, want 11.5", s.Position())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		s.Reset()
+		if position := s.Position(); position != 0 {
+			t.Errorf("position after Reset = %.1f, want 0", position)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,212 @@
+// Package tsmonitor analyses an MPEG transport stream as it is received:
+// continuity-counter errors, PCR jitter, PAT/PMT repetition and bitrate.
+package tsmonitor
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	packetSize = 188
+	syncByte   = 0x47
+	patPID     = 0x0000
+	nullPID    = 0x1FFF
+
+	pcrClock = 27000000 // PCR ticks per second
+)
+
+// Stats is a snapshot of what the analyzer has seen.
+type Stats struct {
+	Packets          int64     `json:"packets"`
+	Bytes            int64     `json:"bytes"`
+	SyncErrors       int64     `json:"sync_errors"`
+	ContinuityErrors int64     `json:"continuity_errors"`
+	Bitrate          float64   `json:"bitrate"`       // Bits per second over the last window
+	PCRJitter        float64   `json:"pcr_jitter_ms"` // Largest PCR jitter over the last window
+	PCRPID           int       `json:"pcr_pid"`
+	LastPacket       time.Time `json:"last_packet"`
+	LastPAT          time.Time `json:"last_pat"`
+	LastPMT          time.Time `json:"last_pmt"`
+}
+
+// Analyzer consumes transport stream packets. It is safe for concurrent use.
+type Analyzer struct {
+	mux   sync.Mutex
+	stats Stats
+
+	continuity map[uint16]uint8
+	pmtPIDs    map[uint16]bool
+
+	lastPCR        uint64
+	lastPCRArrival time.Time
+	maxJitter      float64
+
+	windowStart time.Time
+	windowBytes int64
+}
+
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		continuity: make(map[uint16]uint8),
+		pmtPIDs:    make(map[uint16]bool),
+	}
+}
+
+// Write analyses the packets in data, which arrived at the given time. UDP
+// datagrams carry whole packets, usually seven.
+func (a *Analyzer) Write(data []byte, arrival time.Time) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.windowStart.IsZero() {
+		a.windowStart = arrival
+	}
+	a.stats.Bytes += int64(len(data))
+	a.windowBytes += int64(len(data))
+	a.stats.LastPacket = arrival
+
+	for len(data) >= packetSize {
+		if data[0] != syncByte {
+			a.stats.SyncErrors++
+			data = data[1:]
+			continue
+		}
+		a.packet(data[:packetSize], arrival)
+		data = data[packetSize:]
+	}
+}
+
+func (a *Analyzer) packet(p []byte, arrival time.Time) {
+	a.stats.Packets++
+
+	pid := uint16(p[1]&0x1F)<<8 | uint16(p[2])
+	if pid == nullPID {
+		return
+	}
+	payloadStart := p[1]&0x40 != 0
+	adaptation := (p[3] >> 4) & 0x3
+	counter := p[3] & 0x0F
+
+	payload := p[4:]
+	discontinuity := false
+	if adaptation&0x2 != 0 {
+		length := int(p[4])
+		if length > 0 && length <= 183 {
+			flags := p[5]
+			discontinuity = flags&0x80 != 0
+			if flags&0x10 != 0 && length >= 7 {
+				a.pcr(pid, p[6:12], arrival, discontinuity)
+			}
+		}
+		if 5+length > len(p) {
+			return
+		}
+		payload = p[5+length:]
+	}
+
+	// The counter only advances on packets with a payload, and a packet may
+	// be sent twice
+	if adaptation&0x1 != 0 {
+		if last, seen := a.continuity[pid]; seen && !discontinuity {
+			if counter != (last+1)&0x0F && counter != last {
+				a.stats.ContinuityErrors++
+			}
+		}
+		a.continuity[pid] = counter
+	}
+
+	if !payloadStart || adaptation&0x1 == 0 {
+		return
+	}
+	switch {
+	case pid == patPID:
+		if section := sectionData(payload, 0x00); section != nil {
+			a.parsePAT(section)
+			a.stats.LastPAT = arrival
+		}
+	case a.pmtPIDs[pid]:
+		if section := sectionData(payload, 0x02); section != nil {
+			if len(section) >= 10 {
+				a.stats.PCRPID = int(section[8]&0x1F)<<8 | int(section[9])
+			}
+			a.stats.LastPMT = arrival
+		}
+	}
+}
+
+// pcr compares the time between two PCRs with the time between their
+// arrival. Only the PCR PID announced in the PMT is measured.
+func (a *Analyzer) pcr(pid uint16, field []byte, arrival time.Time, discontinuity bool) {
+	if int(pid) != a.stats.PCRPID {
+		return
+	}
+	base := uint64(field[0])<<25 | uint64(field[1])<<17 | uint64(field[2])<<9 | uint64(field[3])<<1 | uint64(field[4])>>7
+	extension := uint64(field[4]&0x01)<<8 | uint64(field[5])
+	value := base*300 + extension
+
+	if !a.lastPCRArrival.IsZero() && !discontinuity && value > a.lastPCR {
+		streamDelta := float64(value-a.lastPCR) / pcrClock
+		arrivalDelta := arrival.Sub(a.lastPCRArrival).Seconds()
+		jitter := math.Abs(arrivalDelta-streamDelta) * 1000
+		if jitter > a.maxJitter {
+			a.maxJitter = jitter
+		}
+	}
+	a.lastPCR = value
+	a.lastPCRArrival = arrival
+}
+
+func (a *Analyzer) parsePAT(section []byte) {
+	// Programs follow the 8 byte header, the CRC ends the section
+	if len(section) < 12 {
+		return
+	}
+	for i := 8; i+4 <= len(section)-4; i += 4 {
+		program := uint16(section[i])<<8 | uint16(section[i+1])
+		pid := uint16(section[i+2]&0x1F)<<8 | uint16(section[i+3])
+		if program != 0 { // Program 0 points at the NIT
+			a.pmtPIDs[pid] = true
+		}
+	}
+}
+
+// sectionData returns the PSI section starting in payload if it has the
+// given table ID and fits in the packet, which PAT and PMT of a single
+// service always do.
+func sectionData(payload []byte, tableID byte) []byte {
+	if len(payload) < 1 {
+		return nil
+	}
+	pointer := int(payload[0])
+	if 1+pointer+3 > len(payload) {
+		return nil
+	}
+	section := payload[1+pointer:]
+	if section[0] != tableID {
+		return nil
+	}
+	length := int(section[1]&0x0F)<<8 | int(section[2])
+	if 3+length > len(section) {
+		return nil
+	}
+	return section[:3+length]
+}
+
+// Snapshot returns the statistics and starts a new measuring window for
+// the bitrate and PCR jitter.
+func (a *Analyzer) Snapshot(now time.Time) Stats {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if elapsed := now.Sub(a.windowStart).Seconds(); !a.windowStart.IsZero() && elapsed > 0 {
+		a.stats.Bitrate = float64(a.windowBytes*8) / elapsed
+	}
+	a.stats.PCRJitter = a.maxJitter
+
+	a.windowStart = now
+	a.windowBytes = 0
+	a.maxJitter = 0
+	return a.stats
+}
@@ -0,0 +1,209 @@
+package tsmonitor
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2025, 3, 14, 6, 0, 0, 0, time.UTC)
+
+// packet builds a transport stream packet. A nil adaptation field or
+// payload leaves it out; the payload is padded with stuffing bytes.
+func packet(pid uint16, counter uint8, payloadStart bool, adaptation, payload []byte) []byte {
+	p := make([]byte, 4, packetSize)
+	p[0] = syncByte
+	p[1] = byte(pid>>8) & 0x1F
+	if payloadStart {
+		p[1] |= 0x40
+	}
+	p[2] = byte(pid)
+	p[3] = counter & 0x0F
+	if adaptation != nil {
+		p[3] |= 0x20
+		p = append(p, byte(len(adaptation)))
+		p = append(p, adaptation...)
+	}
+	if payload != nil {
+		p[3] |= 0x10
+		p = append(p, payload...)
+	}
+	for len(p) < packetSize {
+		p = append(p, 0xFF)
+	}
+	return p[:packetSize]
+}
+
+// section wraps a PSI table in a section with its pointer field. The CRC is
+// not checked, so it is left zero.
+func section(tableID byte, tableIDExtension uint16, body []byte) []byte {
+	length := 5 + len(body) + 4
+	s := []byte{0x00, tableID, 0xB0 | byte(length>>8), byte(length),
+		byte(tableIDExtension >> 8), byte(tableIDExtension), 0xC1, 0x00, 0x00}
+	s = append(s, body...)
+	return append(s, 0, 0, 0, 0)
+}
+
+// pat announces a PMT PID for each program number.
+func pat(programs map[uint16]uint16) []byte {
+	var body []byte
+	for program, pid := range programs {
+		body = append(body, byte(program>>8), byte(program), 0xE0|byte(pid>>8), byte(pid))
+	}
+	return section(0x00, 1, body)
+}
+
+// pmt announces the PCR PID of a program without listing its streams.
+func pmt(program, pcrPID uint16) []byte {
+	return section(0x02, program, []byte{0xE0 | byte(pcrPID>>8), byte(pcrPID), 0xF0, 0x00})
+}
+
+// pcrField returns an adaptation field carrying a PCR of value 27 MHz ticks.
+func pcrField(value uint64, discontinuity bool) []byte {
+	base, extension := value/300, value%300
+	flags := byte(0x10)
+	if discontinuity {
+		flags |= 0x80
+	}
+	return []byte{flags,
+		byte(base >> 25), byte(base >> 17), byte(base >> 9), byte(base >> 1),
+		byte(base<<7) | 0x7E | byte(extension>>8), byte(extension)}
+}
+
+func TestContinuity(t *testing.T) {
+	a := NewAnalyzer()
+	payload := []byte{0x00}
+	for _, p := range [][]byte{
+		packet(0x100, 14, false, nil, payload),
+		packet(0x100, 15, false, nil, payload),
+		packet(0x100, 15, false, nil, payload), // Sent twice
+		packet(0x100, 0, false, nil, payload),  // Wraps
+		packet(0x100, 0, false, []byte{0x00}, nil),
+		packet(0x100, 2, false, nil, payload),          // Lost 1
+		packet(0x100, 7, false, []byte{0x80}, payload), // Discontinuity
+		packet(0x101, 9, false, nil, payload),          // Other PIDs count apart
+		packet(nullPID, 3, false, nil, payload),        // Null packets are not counted
+		packet(0x100, 8, false, nil, payload),
+	} {
+		a.Write(p, t0)
+	}
+
+	stats := a.Snapshot(t0)
+	if stats.ContinuityErrors != 1 {
+		t.Errorf("continuity errors = %d, want 1", stats.ContinuityErrors)
+	}
+	if stats.Packets != 10 {
+		t.Errorf("packets = %d, want 10", stats.Packets)
+	}
+}
+
+func TestSyncErrors(t *testing.T) {
+	a := NewAnalyzer()
+	data := append([]byte{0x00}, packet(0x100, 0, false, nil, []byte{0x00})...)
+	data = append(data, packet(0x100, 1, false, nil, []byte{0x00})...)
+	a.Write(data, t0)
+
+	stats := a.Snapshot(t0)
+	if stats.SyncErrors != 1 || stats.Packets != 2 || stats.ContinuityErrors != 0 {
+		t.Errorf("stats = %+v, want 1 sync error and 2 packets in order", stats)
+	}
+}
+
+func TestAdaptationFieldBounds(t *testing.T) {
+	a := NewAnalyzer()
+
+	// Adaptation field filling the packet around a payload flag
+	full := packet(0x100, 0, true, bytes.Repeat([]byte{0xFF}, 183), nil)
+	full[3] |= 0x10
+
+	// Adaptation field longer than the packet
+	overlong := packet(0x100, 1, true, []byte{0x00}, []byte{0x00})
+	overlong[4] = 200
+
+	// PCR flag set in an adaptation field too short to carry one
+	short := packet(0x100, 2, false, []byte{0x10, 0x00}, nil)
+
+	for _, p := range [][]byte{full, overlong, short} {
+		a.Write(p, t0)
+	}
+	if stats := a.Snapshot(t0); stats.Packets != 3 {
+		t.Errorf("packets = %d, want 3", stats.Packets)
+	}
+}
+
+func TestPATAndPMT(t *testing.T) {
+	a := NewAnalyzer()
+
+	// A PMT is only recognised once the PAT has announced its PID
+	a.Write(packet(0x1000, 0, true, nil, pmt(1, 0x101)), t0)
+	if stats := a.Snapshot(t0); !stats.LastPMT.IsZero() {
+		t.Error("PMT recognised before the PAT")
+	}
+
+	a.Write(packet(patPID, 0, true, nil, pat(map[uint16]uint16{0: 0x10, 1: 0x1000})), t0.Add(time.Second))
+	a.Write(packet(0x1000, 1, true, nil, pmt(1, 0x101)), t0.Add(2*time.Second))
+	a.Write(packet(0x10, 0, true, nil, pmt(0, 0x102)), t0.Add(3*time.Second)) // The NIT, not a PMT
+
+	stats := a.Snapshot(t0.Add(3 * time.Second))
+	if !stats.LastPAT.Equal(t0.Add(time.Second)) || !stats.LastPMT.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("last PAT %v and PMT %v", stats.LastPAT, stats.LastPMT)
+	}
+	if stats.PCRPID != 0x101 {
+		t.Errorf("PCR PID = %#x, want 0x101", stats.PCRPID)
+	}
+
+	// A section running past the packet is ignored
+	if section := sectionData([]byte{0x00, 0x02, 0xB0, 0xFF, 0x00}, 0x02); section != nil {
+		t.Errorf("sectionData returned a truncated section: %x", section)
+	}
+}
+
+func TestPCRJitter(t *testing.T) {
+	a := NewAnalyzer()
+	a.Write(packet(patPID, 0, true, nil, pat(map[uint16]uint16{1: 0x1000})), t0)
+	a.Write(packet(0x1000, 0, true, nil, pmt(1, 0x101)), t0)
+
+	// The first PCR has an extension that carries into the base on the next
+	start := uint64(1)<<32*300 + 299
+	pcrs := []struct {
+		value         uint64
+		arrival       time.Duration
+		discontinuity bool
+	}{
+		{start, 0, false},
+		{start + 2700000, 100 * time.Millisecond, false},         // On time
+		{start + 5400000, 203 * time.Millisecond, false},         // 3 ms late
+		{start + 8100000 + 27000, 302 * time.Millisecond, false}, // 2 ms early
+		{start + 3600*pcrClock, 400 * time.Millisecond, true},    // New timeline
+		{start + 3600*pcrClock + 2700000, 500 * time.Millisecond, false},
+	}
+	for i, pcr := range pcrs {
+		a.Write(packet(0x101, uint8(i), false, pcrField(pcr.value, pcr.discontinuity), nil), t0.Add(pcr.arrival))
+	}
+
+	stats := a.Snapshot(t0.Add(time.Second))
+	if math.Abs(stats.PCRJitter-3) > 0.001 {
+		t.Errorf("PCR jitter = %.4f ms, want 3 ms", stats.PCRJitter)
+	}
+
+	// Jitter is measured per window
+	if stats := a.Snapshot(t0.Add(2 * time.Second)); stats.PCRJitter != 0 {
+		t.Errorf("PCR jitter in an empty window = %.4f ms", stats.PCRJitter)
+	}
+}
+
+func TestBitrate(t *testing.T) {
+	a := NewAnalyzer()
+	a.Snapshot(t0)
+
+	var datagram []byte
+	for i := 0; i < 7; i++ {
+		datagram = append(datagram, packet(0x100, uint8(i), false, nil, []byte{0x00})...)
+	}
+	a.Write(datagram, t0.Add(500*time.Millisecond))
+
+	if stats := a.Snapshot(t0.Add(time.Second)); stats.Bitrate != 7*packetSize*8 {
+		t.Errorf("bitrate = %.0f, want %d", stats.Bitrate, 7*packetSize*8)
+	}
+}